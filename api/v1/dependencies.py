@@ -1,23 +1,114 @@
+import json
 import os
+from datetime import datetime
+
 import duckdb
 from fastapi import HTTPException
 
 BASE_DATALAKE_DIR = os.path.join(os.path.expanduser("~"), ".local", "share", "pipeterm_lake")
 
+# pipeterm records every committed file in the lake manifest. Anything else on
+# disk, such as staging directories or files waiting to be deleted, is not
+# part of the lake.
+MANIFEST_FILE = "_manifest.json"
+SNAPSHOT_COLUMN = "snapshot_ts"
+LATEST_SUFFIX = "_latest"
+
+
+def quote_ident(name):
+    return '"' + name.replace('"', '""') + '"'
+
+
+def quote_literal(value):
+    return "'" + value.replace("'", "''") + "'"
+
+
+def timestamp_literal(value):
+    # The manifest holds RFC 3339 timestamps; snapshot_ts holds their wall time
+    ts = datetime.fromisoformat(value.replace("Z", "+00:00"))
+    return "TIMESTAMP " + quote_literal(ts.strftime("%Y-%m-%d %H:%M:%S.%f"))
+
+
+def load_manifest(data_lake_path):
+    manifest_path = os.path.join(data_lake_path, MANIFEST_FILE)
+    if not os.path.exists(manifest_path):
+        return {}
+    with open(manifest_path) as f:
+        return json.load(f)
+
+
+def files_select(data_lake_path, files):
+    # Files landed before rows were stamped get their snapshot from the manifest
+    stamped = []
+    parts = []
+    for f in files:
+        path = quote_literal(os.path.join(data_lake_path, f["path"]))
+        if any(column["name"] == SNAPSHOT_COLUMN for column in f.get("schema") or []):
+            stamped.append(path)
+            continue
+        parts.append(
+            f"SELECT *, {timestamp_literal(f['landed_at'])} AS {SNAPSHOT_COLUMN} "
+            f"FROM read_parquet({path}, hive_partitioning = true)"
+        )
+    if stamped:
+        parts.insert(0, f"SELECT * FROM read_parquet([{', '.join(stamped)}], hive_partitioning = true, union_by_name = true)")
+    return " UNION ALL BY NAME ".join(parts)
+
+
+def table_query(select, latest, meta):
+    # Mirrors the views pipeterm builds: full refresh tables show their latest
+    # snapshot, upsert tables the newest row per key and append tables every row
+    mode = meta.get("mode")
+    key = meta.get("primary_key") or []
+    if mode == "full_refresh":
+        return f"SELECT * FROM ({select}) WHERE {SNAPSHOT_COLUMN} = {latest}"
+    if mode == "upsert" and key:
+        partition = ", ".join(quote_ident(column) for column in key)
+        return (
+            f"SELECT * FROM ({select}) WHERE {SNAPSHOT_COLUMN} <= {latest} "
+            f"QUALIFY row_number() OVER (PARTITION BY {partition} ORDER BY {SNAPSHOT_COLUMN} DESC) = 1"
+        )
+    return f"SELECT * FROM ({select}) WHERE {SNAPSHOT_COLUMN} <= {latest}"
+
+
+def create_views(conn, data_lake_path):
+    manifest = load_manifest(data_lake_path)
+
+    tables = {}
+    for f in manifest.get("files") or []:
+        # Tables never start with an underscore; those directories are pipeterm's own
+        if f["table"].startswith("_") or f["path"].startswith("_"):
+            continue
+        tables.setdefault(f["table"], []).append(f)
+
+    latest_by_table = {}
+    for snapshot in manifest.get("snapshots") or []:
+        ts = datetime.fromisoformat(snapshot["timestamp"].replace("Z", "+00:00"))
+        if snapshot["table"] not in latest_by_table or ts > latest_by_table[snapshot["table"]][0]:
+            latest_by_table[snapshot["table"]] = (ts, snapshot["timestamp"])
+
+    for table, files in tables.items():
+        if table in latest_by_table:
+            latest = timestamp_literal(latest_by_table[table][1])
+        else:
+            latest = timestamp_literal(max(files, key=lambda f: datetime.fromisoformat(f["landed_at"].replace("Z", "+00:00")))["landed_at"])
+        select = files_select(data_lake_path, files)
+        meta = (manifest.get("tables") or {}).get(table, {})
+        conn.execute(f"CREATE VIEW {quote_ident(table)} AS {table_query(select, latest, meta)}")
+        conn.execute(
+            f"CREATE VIEW {quote_ident(table + LATEST_SUFFIX)} AS "
+            f"SELECT * FROM ({select}) WHERE {SNAPSHOT_COLUMN} = {latest}"
+        )
+
+
 def get_duckdb_connection(data_lake: str):
     data_lake_path = os.path.join(BASE_DATALAKE_DIR, data_lake)
-    if not os.path.isdir(data_lake_path):
+    if data_lake.startswith(".") or os.sep in data_lake or not os.path.isdir(data_lake_path):
         raise HTTPException(status_code=404, detail=f"Data lake '{data_lake}' does not exist")
 
     # Create an in-memory DuckDB connection
     conn = duckdb.connect()
-
-    for file_name in os.listdir(data_lake_path):
-        if file_name.endswith(".csv"):
-            table_name = os.path.splitext(file_name)[0]
-            file_path = os.path.join(data_lake_path, file_name)
-            create_view_query = f"CREATE VIEW {table_name} AS SELECT * FROM read_csv_auto('{file_path}')"
-            conn.execute(create_view_query)
+    create_views(conn, data_lake_path)
 
     ##This is where the connection is reuturned and closed after use
     try:
@@ -0,0 +1,252 @@
+package lake
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Table is a logical table in the catalog: the union of every snapshot
+// landed for it. Files and snapshots are ordered oldest first.
+type Table struct {
+	Name      string
+	Meta      TableMeta
+	Files     []FileEntry
+	Snapshots []Snapshot
+	Drift     []DriftEvent
+}
+
+// Latest returns the most recent snapshot of the table.
+func (t Table) Latest() Snapshot {
+	if len(t.Snapshots) == 0 {
+		return Snapshot{}
+	}
+	return t.Snapshots[len(t.Snapshots)-1]
+}
+
+// Rows returns the number of rows across every file of the table.
+func (t Table) Rows() int64 {
+	var n int64
+	for _, f := range t.Files {
+		n += f.Rows
+	}
+	return n
+}
+
+// Bytes returns the on-disk size of every file of the table.
+func (t Table) Bytes() int64 {
+	var n int64
+	for _, f := range t.Files {
+		n += f.Bytes
+	}
+	return n
+}
+
+// Schema returns the schema of the most recent snapshot. Snapshots
+// recorded before schemas were kept fall back to the newest file that was
+// not rewritten by compaction.
+func (t Table) Schema() []Column {
+	if schema := t.Latest().Schema; schema != nil {
+		return schema
+	}
+	for i := len(t.Files) - 1; i >= 0; i-- {
+		if t.Files[i].CompactedFrom == 0 {
+			return t.Files[i].Schema
+		}
+	}
+	if len(t.Files) == 0 {
+		return nil
+	}
+	return t.Files[len(t.Files)-1].Schema
+}
+
+// List returns the names of every data lake.
+func List() ([]string, error) {
+	root, err := Root()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var lakes []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			lakes = append(lakes, entry.Name())
+		}
+	}
+	return lakes, nil
+}
+
+// Tables groups the manifest by logical table, sorted by name.
+func (l *Lake) Tables() ([]Table, error) {
+	m, err := l.LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*Table)
+	var tables []*Table
+	for _, f := range m.Files {
+		t, ok := byName[f.Table]
+		if !ok {
+			t = &Table{Name: f.Table, Meta: TableMeta{Mode: Append}}
+			if meta, ok := m.Tables[f.Table]; ok {
+				t.Meta = meta
+			}
+			byName[f.Table] = t
+			tables = append(tables, t)
+		}
+		t.Files = append(t.Files, f)
+	}
+	for _, snap := range m.Snapshots {
+		if t, ok := byName[snap.Table]; ok {
+			t.Snapshots = append(t.Snapshots, snap)
+		}
+	}
+	for _, event := range m.Drift {
+		if t, ok := byName[event.Table]; ok {
+			t.Drift = append(t.Drift, event)
+		}
+	}
+
+	result := make([]Table, len(tables))
+	for i, t := range tables {
+		sort.SliceStable(t.Files, func(a, b int) bool {
+			return t.Files[a].LandedAt.Before(t.Files[b].LandedAt)
+		})
+		sort.SliceStable(t.Snapshots, func(a, b int) bool {
+			return t.Snapshots[a].Timestamp.Before(t.Snapshots[b].Timestamp)
+		})
+		result[i] = *t
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Name < result[b].Name })
+	return result, nil
+}
+
+// Path returns the absolute path of a catalog file.
+func (l *Lake) Path(f FileEntry) string {
+	return filepath.Join(l.Dir, f.Path)
+}
+
+// legacySuffix matches the timestamps older connectors appended to file
+// names, e.g. salesforce_report_20240918101500 or orders_2024-09-18_10-15-00.
+var legacySuffix = regexp.MustCompile(`_(\d{14}|\d{4}-\d{2}-\d{2}_\d{2}-\d{2}-\d{2})$`)
+
+// Adopt registers files written before the catalog existed: loose CSVs in
+// the lake root are landed through a Writer and moved to _legacy, and
+// partitioned Parquet files missing from the manifest are registered where
+// they are. It returns how many files were adopted.
+func (l *Lake) Adopt() (int, error) {
+	if err := l.CollectGarbage(); err != nil {
+		return 0, err
+	}
+
+	csvs, err := filepath.Glob(filepath.Join(l.Dir, "*.csv"))
+	if err != nil {
+		return 0, err
+	}
+	adopted := 0
+	for _, csvPath := range csvs {
+		if err := l.adoptCSV(csvPath); err != nil {
+			return adopted, err
+		}
+		adopted++
+	}
+
+	m, err := l.LoadManifest()
+	if err != nil {
+		return adopted, err
+	}
+	known := make(map[string]bool, len(m.Files))
+	for _, f := range m.Files {
+		known[f.Path] = true
+	}
+	for _, path := range m.Garbage {
+		known[path] = true
+	}
+
+	parquetFiles, err := filepath.Glob(filepath.Join(l.Dir, "*", "dt=*", "*.parquet"))
+	if err != nil {
+		return adopted, err
+	}
+	var orphans []string
+	for _, path := range parquetFiles {
+		rel, err := filepath.Rel(l.Dir, path)
+		if err != nil {
+			return adopted, err
+		}
+		if !known[rel] && !strings.HasPrefix(rel, "_") {
+			orphans = append(orphans, rel)
+		}
+	}
+	if len(orphans) == 0 {
+		return adopted, nil
+	}
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return adopted, err
+	}
+	defer db.Close()
+
+	var entries []FileEntry
+	for _, rel := range orphans {
+		path := filepath.Join(l.Dir, rel)
+		entry, err := inspectFile(db, path)
+		if err != nil {
+			return adopted, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return adopted, err
+		}
+		entry.Table = strings.SplitN(rel, string(filepath.Separator), 2)[0]
+		entry.Path = rel
+		entry.RunID = NewRunID(info.ModTime())
+		entry.LandedAt = info.ModTime().Truncate(time.Microsecond)
+		entries = append(entries, entry)
+	}
+	if err := l.register(commit{files: entries}); err != nil {
+		return adopted, err
+	}
+	return adopted + len(entries), nil
+}
+
+func (l *Lake) adoptCSV(csvPath string) error {
+	info, err := os.Stat(csvPath)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(filepath.Base(csvPath), filepath.Ext(csvPath))
+	table := legacySuffix.ReplaceAllString(name, "")
+
+	w, err := l.NewWriter("", NewRunID(info.ModTime()))
+	if err != nil {
+		return err
+	}
+	w.startedAt = info.ModTime().Truncate(time.Microsecond)
+	data, err := os.ReadFile(csvPath)
+	if err != nil {
+		w.Abort()
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(w.StagingDir(), table+".csv"), data, 0644); err != nil {
+		w.Abort()
+		return err
+	}
+	if _, err := w.Commit(); err != nil {
+		return err
+	}
+
+	legacyDir := filepath.Join(l.Dir, "_legacy")
+	if err := os.MkdirAll(legacyDir, 0755); err != nil {
+		return err
+	}
+	return os.Rename(csvPath, filepath.Join(legacyDir, filepath.Base(csvPath)))
+}
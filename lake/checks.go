@@ -0,0 +1,306 @@
+package lake
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckKind is the kind of expectation a check tests.
+type CheckKind string
+
+const (
+	CheckNotNull        CheckKind = "not_null"
+	CheckUnique         CheckKind = "unique"
+	CheckAcceptedValues CheckKind = "accepted_values"
+	CheckRowCount       CheckKind = "row_count"
+	CheckFreshness      CheckKind = "freshness"
+	CheckCustomSQL      CheckKind = "custom_sql"
+)
+
+// Check is a data quality expectation evaluated against the output of a
+// pipeline run. Checks are written one per line:
+//
+//	not_null <table> <column>
+//	unique <table> <column>
+//	accepted_values <table> <column> <value>,<value>,...
+//	row_count <table> <min> [max]
+//	freshness <table> <column> <max age, e.g. 90m, 24h or 7d>
+//	custom_sql <query returning the offending rows>
+//
+// A custom SQL check passes when its query returns no rows. It can refer
+// to every table the run wrote by name.
+type Check struct {
+	Kind   CheckKind `json:"kind"`
+	Table  string    `json:"table,omitempty"`
+	Column string    `json:"column,omitempty"`
+	Values []string  `json:"values,omitempty"`
+	Min    int64     `json:"min,omitempty"`
+	// Max of zero means the row count has no upper bound.
+	Max    int64  `json:"max,omitempty"`
+	MaxAge string `json:"max_age,omitempty"`
+	SQL    string `json:"sql,omitempty"`
+}
+
+// String formats the check in the form accepted by ParseCheck.
+func (c Check) String() string {
+	switch c.Kind {
+	case CheckAcceptedValues:
+		return fmt.Sprintf("%s %s %s %s", c.Kind, c.Table, c.Column, strings.Join(c.Values, ","))
+	case CheckRowCount:
+		if c.Max > 0 {
+			return fmt.Sprintf("%s %s %d %d", c.Kind, c.Table, c.Min, c.Max)
+		}
+		return fmt.Sprintf("%s %s %d", c.Kind, c.Table, c.Min)
+	case CheckFreshness:
+		return fmt.Sprintf("%s %s %s %s", c.Kind, c.Table, c.Column, c.MaxAge)
+	case CheckCustomSQL:
+		return fmt.Sprintf("%s %s", c.Kind, c.SQL)
+	}
+	return fmt.Sprintf("%s %s %s", c.Kind, c.Table, c.Column)
+}
+
+// ParseCheck parses a single check line.
+func ParseCheck(line string) (Check, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return Check{}, fmt.Errorf("empty check")
+	}
+	c := Check{Kind: CheckKind(fields[0])}
+	args := fields[1:]
+	usage := func(format string) (Check, error) {
+		return Check{}, fmt.Errorf("usage: %s %s", c.Kind, format)
+	}
+
+	switch c.Kind {
+	case CheckNotNull, CheckUnique:
+		if len(args) != 2 {
+			return usage("<table> <column>")
+		}
+		c.Table, c.Column = args[0], args[1]
+	case CheckAcceptedValues:
+		if len(args) != 3 {
+			return usage("<table> <column> <value>,<value>,...")
+		}
+		c.Table, c.Column = args[0], args[1]
+		c.Values = strings.Split(args[2], ",")
+	case CheckRowCount:
+		if len(args) != 2 && len(args) != 3 {
+			return usage("<table> <min> [max]")
+		}
+		c.Table = args[0]
+		var err error
+		if c.Min, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return usage("<table> <min> [max]")
+		}
+		if len(args) == 3 {
+			if c.Max, err = strconv.ParseInt(args[2], 10, 64); err != nil || c.Max < c.Min {
+				return usage("<table> <min> [max]")
+			}
+		}
+	case CheckFreshness:
+		if len(args) != 3 {
+			return usage("<table> <column> <max age>")
+		}
+		c.Table, c.Column, c.MaxAge = args[0], args[1], args[2]
+		if _, err := parseAge(c.MaxAge); err != nil {
+			return Check{}, err
+		}
+	case CheckCustomSQL:
+		c.SQL = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), string(CheckCustomSQL)))
+		c.SQL = strings.TrimSpace(strings.TrimSuffix(c.SQL, ";"))
+		if c.SQL == "" {
+			return usage("<query>")
+		}
+	default:
+		return Check{}, fmt.Errorf("unknown check %q", fields[0])
+	}
+	return c, nil
+}
+
+// parseAge parses a duration, also accepting whole days such as 7d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// CheckResult is the outcome of one check for one run.
+type CheckResult struct {
+	Check  Check  `json:"check"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (r CheckResult) String() string {
+	if r.Passed {
+		return fmt.Sprintf("%s: passed", r.Check)
+	}
+	return fmt.Sprintf("%s: %s", r.Check, r.Detail)
+}
+
+// RunChecks evaluates checks against the files landed by a run. Every table
+// the run wrote is visible to the checks under its own name, holding only
+// that run's rows. A check that cannot be evaluated fails with the error.
+func (l *Lake) RunChecks(files []FileEntry, checks []Check, now time.Time) []CheckResult {
+	results := make([]CheckResult, len(checks))
+	for i, c := range checks {
+		results[i] = CheckResult{Check: c}
+	}
+	if len(checks) == 0 {
+		return results
+	}
+
+	fail := func(err error) []CheckResult {
+		for i := range results {
+			results[i].Detail = err.Error()
+		}
+		return results
+	}
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return fail(err)
+	}
+	defer db.Close()
+
+	byTable := make(map[string][]FileEntry)
+	for _, f := range files {
+		byTable[f.Table] = append(byTable[f.Table], f)
+	}
+	for table, tableFiles := range byTable {
+		createViewQuery := fmt.Sprintf("CREATE VIEW %s AS %s;", QuoteIdent(table), l.filesSelect(tableFiles, false))
+		if _, err := db.Exec(createViewQuery); err != nil {
+			return fail(fmt.Errorf("creating view %s: %w", table, err))
+		}
+	}
+
+	for i, c := range checks {
+		if _, ok := byTable[c.Table]; !ok && c.Kind != CheckCustomSQL {
+			results[i].Detail = fmt.Sprintf("table %s was not written by this run", c.Table)
+			continue
+		}
+		detail, err := evaluate(db, c, now)
+		if err != nil {
+			detail = "error: " + err.Error()
+		}
+		results[i].Passed = detail == ""
+		results[i].Detail = detail
+	}
+	return results
+}
+
+// evaluate runs a single check and describes the failure, or returns an
+// empty string when the check passes.
+func evaluate(db *sql.DB, c Check, now time.Time) (string, error) {
+	table := QuoteIdent(c.Table)
+	column := QuoteIdent(c.Column)
+
+	switch c.Kind {
+	case CheckNotNull:
+		var nulls, rows int64
+		query := fmt.Sprintf("SELECT count(*) FILTER (WHERE %s IS NULL), count(*) FROM %s", column, table)
+		if err := db.QueryRow(query).Scan(&nulls, &rows); err != nil {
+			return "", err
+		}
+		if nulls > 0 {
+			return fmt.Sprintf("%d of %d rows have a NULL %s", nulls, rows, c.Column), nil
+		}
+
+	case CheckUnique:
+		var duplicates int64
+		query := fmt.Sprintf("SELECT count(%s) - count(DISTINCT %s) FROM %s", column, column, table)
+		if err := db.QueryRow(query).Scan(&duplicates); err != nil {
+			return "", err
+		}
+		if duplicates > 0 {
+			return fmt.Sprintf("%d rows repeat a value of %s", duplicates, c.Column), nil
+		}
+
+	case CheckAcceptedValues:
+		accepted := make([]string, len(c.Values))
+		for i, v := range c.Values {
+			accepted[i] = QuoteLiteral(v)
+		}
+		query := fmt.Sprintf("SELECT CAST(%s AS VARCHAR) AS v, count(*) FROM %s WHERE %s IS NOT NULL AND CAST(%s AS VARCHAR) NOT IN (%s) GROUP BY v ORDER BY count(*) DESC",
+			column, table, column, column, strings.Join(accepted, ", "))
+		rows, err := db.Query(query)
+		if err != nil {
+			return "", err
+		}
+		defer rows.Close()
+		var unexpected []string
+		var total int64
+		for rows.Next() {
+			var value string
+			var n int64
+			if err := rows.Scan(&value, &n); err != nil {
+				return "", err
+			}
+			if len(unexpected) < 5 {
+				unexpected = append(unexpected, value)
+			}
+			total += n
+		}
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		if total > 0 {
+			return fmt.Sprintf("%d rows have an unexpected %s, e.g. %s", total, c.Column, strings.Join(unexpected, ", ")), nil
+		}
+
+	case CheckRowCount:
+		n, err := countRows(db, table)
+		if err != nil {
+			return "", err
+		}
+		if n < c.Min || (c.Max > 0 && n > c.Max) {
+			if c.Max > 0 {
+				return fmt.Sprintf("%d rows, expected between %d and %d", n, c.Min, c.Max), nil
+			}
+			return fmt.Sprintf("%d rows, expected at least %d", n, c.Min), nil
+		}
+
+	case CheckFreshness:
+		maxAge, err := parseAge(c.MaxAge)
+		if err != nil {
+			return "", err
+		}
+		var newest sql.NullTime
+		query := fmt.Sprintf("SELECT max(CAST(%s AS TIMESTAMP)) FROM %s", column, table)
+		if err := db.QueryRow(query).Scan(&newest); err != nil {
+			return "", err
+		}
+		if !newest.Valid {
+			return fmt.Sprintf("%s has no values", c.Column), nil
+		}
+		// DuckDB timestamps carry no zone; compare them with the local wall clock
+		wallNow := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), time.UTC)
+		if age := wallNow.Sub(newest.Time); age > maxAge {
+			return fmt.Sprintf("newest %s is %s old, expected at most %s", c.Column, age.Round(time.Second), c.MaxAge), nil
+		}
+
+	case CheckCustomSQL:
+		n, err := countRows(db, "("+c.SQL+")")
+		if err != nil {
+			return "", err
+		}
+		if n > 0 {
+			return fmt.Sprintf("query returned %d rows", n), nil
+		}
+
+	default:
+		return "", fmt.Errorf("unknown check %q", c.Kind)
+	}
+	return "", nil
+}
@@ -0,0 +1,168 @@
+package lake
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCheck(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    Check
+		wantErr string
+	}{
+		{line: "not_null orders id", want: Check{Kind: CheckNotNull, Table: "orders", Column: "id"}},
+		{line: "  unique   orders  id  ", want: Check{Kind: CheckUnique, Table: "orders", Column: "id"}},
+		{
+			line: "accepted_values orders status shipped,pending",
+			want: Check{Kind: CheckAcceptedValues, Table: "orders", Column: "status", Values: []string{"shipped", "pending"}},
+		},
+		{line: "row_count orders 10", want: Check{Kind: CheckRowCount, Table: "orders", Min: 10}},
+		{line: "row_count orders 10 20", want: Check{Kind: CheckRowCount, Table: "orders", Min: 10, Max: 20}},
+		{line: "row_count orders 10 10", want: Check{Kind: CheckRowCount, Table: "orders", Min: 10, Max: 10}},
+		{line: "row_count orders 20 10", wantErr: "usage: row_count"},
+		{line: "row_count orders ten", wantErr: "usage: row_count"},
+		{line: "row_count orders 1 many", wantErr: "usage: row_count"},
+		{line: "row_count orders", wantErr: "usage: row_count"},
+		{line: "freshness orders updated_at 7d", want: Check{Kind: CheckFreshness, Table: "orders", Column: "updated_at", MaxAge: "7d"}},
+		{line: "freshness orders updated_at 90m", want: Check{Kind: CheckFreshness, Table: "orders", Column: "updated_at", MaxAge: "90m"}},
+		{line: "freshness orders updated_at 1.5d", wantErr: `invalid age "1.5d"`},
+		{line: "freshness orders updated_at soon", wantErr: `invalid age "soon"`},
+		{
+			line: "custom_sql SELECT * FROM orders WHERE total < 0;",
+			want: Check{Kind: CheckCustomSQL, SQL: "SELECT * FROM orders WHERE total < 0"},
+		},
+		{line: "custom_sql ;", wantErr: "usage: custom_sql"},
+		{line: "not_null orders", wantErr: "usage: not_null"},
+		{line: "accepted_values orders status", wantErr: "usage: accepted_values"},
+		{line: "positive orders total", wantErr: `unknown check "positive"`},
+		{line: "   ", wantErr: "empty check"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := ParseCheck(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCheck = %+v, want %+v", got, tt.want)
+			}
+			if again, err := ParseCheck(got.String()); err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("String() = %q does not parse back: %+v, %v", got.String(), again, err)
+			}
+		})
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		age  string
+		want time.Duration
+	}{
+		{"7d", 7 * 24 * time.Hour},
+		{"0d", 0},
+		{"90m", 90 * time.Minute},
+		{"24h", 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got, err := parseAge(tt.age); err != nil || got != tt.want {
+			t.Errorf("parseAge(%q) = %v, %v, want %v", tt.age, got, err, tt.want)
+		}
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	l := newTestLake(t, "checks")
+	w, err := l.NewWriter("test", NewRunID(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.WriteRows("orders", []string{"id", "status", "email", "updated_at"}, [][]string{
+		{"1", "shipped", "a@example.com", "2026-01-02 03:00:00"},
+		{"2", "pending", "", "2026-01-01 00:00:00"},
+		{"3", "lost", "a@example.com", "2026-01-01 12:00:00"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := w.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The newest update is two hours old
+	now := time.Date(2026, 1, 2, 5, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		check      string
+		wantPassed bool
+		wantDetail string
+	}{
+		{"not_null orders id", true, ""},
+		{"not_null orders email", false, "1 of 3 rows have a NULL email"},
+		{"unique orders id", true, ""},
+		{"unique orders email", false, "1 rows repeat a value of email"},
+		{"accepted_values orders status shipped,pending,lost", true, ""},
+		{"accepted_values orders status shipped,pending", false, "1 rows have an unexpected status, e.g. lost"},
+		{"row_count orders 3", true, ""},
+		{"row_count orders 1 3", true, ""},
+		{"row_count orders 4", false, "3 rows, expected at least 4"},
+		{"row_count orders 1 2", false, "3 rows, expected between 1 and 2"},
+		{"freshness orders updated_at 3h", true, ""},
+		{"freshness orders updated_at 1d", true, ""},
+		{"freshness orders updated_at 90m", false, "newest updated_at is 2h0m0s old, expected at most 90m"},
+		{"custom_sql SELECT * FROM orders WHERE id > 10", true, ""},
+		{"custom_sql SELECT * FROM orders WHERE status = 'lost'", false, "query returned 1 rows"},
+		{"not_null customers id", false, "table customers was not written by this run"},
+		{"not_null orders missing", false, "error: "},
+	}
+	checks := make([]Check, len(tests))
+	for i, tt := range tests {
+		if checks[i], err = ParseCheck(tt.check); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := l.RunChecks(files, checks, now)
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results for %d checks", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		r := results[i]
+		if r.Check.String() != checks[i].String() {
+			t.Errorf("result %d is for %s, want %s", i, r.Check, checks[i])
+		}
+		if r.Passed != tt.wantPassed || !strings.HasPrefix(r.Detail, tt.wantDetail) || (tt.wantDetail == "" && r.Detail != "") {
+			t.Errorf("%s: passed = %v, detail %q; want %v, %q", tt.check, r.Passed, r.Detail, tt.wantPassed, tt.wantDetail)
+		}
+	}
+}
+
+func TestRunChecksSeesOnlyTheRun(t *testing.T) {
+	l := newTestLake(t, "checks")
+	writeTestRun(t, l, "orders", time.Now().Add(-time.Hour), []string{"id"}, [][]string{{"1"}, {"1"}})
+	w, err := l.NewWriter("test", NewRunID(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRows("orders", []string{"id"}, [][]string{{"1"}, {"2"}}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := w.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, err := ParseCheck("unique orders id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := l.RunChecks(files, []Check{check}, time.Now())[0]; !r.Passed {
+		t.Errorf("check saw rows of an earlier run: %s", r)
+	}
+}
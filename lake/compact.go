@@ -0,0 +1,242 @@
+package lake
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultSmallFileBytes = 32 << 20
+	defaultTargetBytes    = 128 << 20
+)
+
+// CompactionSettings controls which files compaction merges and how large
+// the merged files grow. Zero sizes use the defaults.
+type CompactionSettings struct {
+	// Schedule is a cron expression for background compaction. Empty
+	// means compaction only runs when asked.
+	Schedule string `json:"schedule,omitempty"`
+	// SmallFileBytes is the size below which a file is merged.
+	SmallFileBytes int64 `json:"small_file_bytes,omitempty"`
+	// TargetBytes caps the combined size of the files merged into one.
+	TargetBytes int64 `json:"target_bytes,omitempty"`
+}
+
+func (s CompactionSettings) withDefaults() CompactionSettings {
+	if s.SmallFileBytes <= 0 {
+		s.SmallFileBytes = defaultSmallFileBytes
+	}
+	if s.TargetBytes <= 0 {
+		s.TargetBytes = defaultTargetBytes
+	}
+	return s
+}
+
+// CompactionReport describes what compacting one table changed.
+type CompactionReport struct {
+	Table       string
+	FilesBefore int
+	FilesAfter  int
+	BytesBefore int64
+	BytesAfter  int64
+}
+
+func (r CompactionReport) String() string {
+	return fmt.Sprintf("%s: %d files (%d bytes) -> %d files (%d bytes)",
+		r.Table, r.FilesBefore, r.BytesBefore, r.FilesAfter, r.BytesAfter)
+}
+
+// Compact compacts every table of the lake.
+func (l *Lake) Compact(settings CompactionSettings) ([]CompactionReport, error) {
+	tables, err := l.Tables()
+	if err != nil {
+		return nil, err
+	}
+	var reports []CompactionReport
+	for _, t := range tables {
+		report, err := l.CompactTable(t.Name, settings)
+		if err != nil {
+			return reports, fmt.Errorf("compacting %s: %w", t.Name, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// CompactTable merges the small files of each partition of a table into
+// files of up to the target size. Rows keep their snapshot timestamps, so
+// every view and AS OF query returns the same result afterwards. The
+// merged files replace the originals in a single manifest update and the
+// originals are deleted once the manifest no longer refers to them.
+func (l *Lake) CompactTable(table string, settings CompactionSettings) (CompactionReport, error) {
+	settings = settings.withDefaults()
+	report := CompactionReport{Table: table}
+
+	tables, err := l.Tables()
+	if err != nil {
+		return report, err
+	}
+	var t Table
+	for _, candidate := range tables {
+		if candidate.Name == table {
+			t = candidate
+		}
+	}
+	if t.Name == "" {
+		return report, fmt.Errorf("unknown table %s", table)
+	}
+	report.FilesBefore = len(t.Files)
+	report.BytesBefore = t.Bytes()
+	report.FilesAfter = report.FilesBefore
+	report.BytesAfter = report.BytesBefore
+
+	batches := compactionBatches(t.Files, settings)
+	if len(batches) == 0 {
+		return report, nil
+	}
+
+	compactionID := NewRunID(time.Now())
+	staging := filepath.Join(l.Dir, stagingDir, "compact-"+compactionID)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return report, err
+	}
+	defer os.RemoveAll(staging)
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return report, err
+	}
+	defer db.Close()
+
+	var staged []stagedFile
+	var oldPaths []string
+	var lineage []Edge
+	for i, batch := range batches {
+		name := fmt.Sprintf("%s_compacted_%s_%d.parquet", table, compactionID, i)
+		out := filepath.Join(staging, name)
+		entry, err := l.mergeFiles(db, batch, out)
+		if err != nil {
+			return report, err
+		}
+		entry.Table = table
+		entry.RunID = compactionID
+		entry.Path = filepath.Join(filepath.Dir(batch[0].Path), name)
+		staged = append(staged, stagedFile{entry: entry, src: out, dest: filepath.Join(l.Dir, entry.Path)})
+		lineage = append(lineage, rewriteLineage(batch, entry)...)
+		for _, f := range batch {
+			oldPaths = append(oldPaths, f.Path)
+		}
+	}
+
+	var landed []stagedFile
+	for _, s := range staged {
+		if err := os.Rename(s.src, s.dest); err != nil {
+			removeLanded(landed)
+			return report, err
+		}
+		landed = append(landed, s)
+	}
+
+	entries := make([]FileEntry, len(landed))
+	for i, s := range landed {
+		entries[i] = s.entry
+	}
+	if err := l.replaceFiles(oldPaths, entries, nil); err != nil {
+		removeLanded(landed)
+		return report, err
+	}
+	l.recordLineage(lineage, oldPaths)
+	if err := l.CollectGarbage(); err != nil {
+		return report, err
+	}
+
+	report.FilesAfter = report.FilesBefore - len(oldPaths) + len(entries)
+	report.BytesAfter = report.BytesBefore
+	for _, path := range oldPaths {
+		for _, f := range t.Files {
+			if f.Path == path {
+				report.BytesAfter -= f.Bytes
+			}
+		}
+	}
+	for _, entry := range entries {
+		report.BytesAfter += entry.Bytes
+	}
+	l.LogMaintenance("compacted %s", report)
+	return report, nil
+}
+
+// compactionBatches groups the small files of each partition, oldest first,
+// into batches no larger than the target size. Batches of a single file are
+// left alone.
+func compactionBatches(files []FileEntry, settings CompactionSettings) [][]FileEntry {
+	var partitions []string
+	small := make(map[string][]FileEntry)
+	for _, f := range files {
+		if f.Bytes >= settings.SmallFileBytes {
+			continue
+		}
+		partition := filepath.Dir(f.Path)
+		if _, ok := small[partition]; !ok {
+			partitions = append(partitions, partition)
+		}
+		small[partition] = append(small[partition], f)
+	}
+
+	var batches [][]FileEntry
+	for _, partition := range partitions {
+		var batch []FileEntry
+		var size int64
+		for _, f := range small[partition] {
+			if len(batch) > 0 && size+f.Bytes > settings.TargetBytes {
+				if len(batch) > 1 {
+					batches = append(batches, batch)
+				}
+				batch, size = nil, 0
+			}
+			batch = append(batch, f)
+			size += f.Bytes
+		}
+		if len(batch) > 1 {
+			batches = append(batches, batch)
+		}
+	}
+	return batches
+}
+
+// mergeFiles writes the rows of files to out as a single compressed Parquet
+// file ordered by snapshot, and checks no rows were lost.
+func (l *Lake) mergeFiles(db *sql.DB, files []FileEntry, out string) (FileEntry, error) {
+	copyQuery := fmt.Sprintf("COPY (SELECT * FROM (%s) ORDER BY %s) TO %s (FORMAT PARQUET, COMPRESSION ZSTD);",
+		l.filesSelect(files, false), SnapshotColumn, QuoteLiteral(out))
+	if _, err := db.Exec(copyQuery); err != nil {
+		return FileEntry{}, err
+	}
+
+	entry, err := inspectFile(db, out)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	var rows int64
+	for _, f := range files {
+		rows += f.Rows
+		if entry.LandedAt.Before(f.LandedAt) {
+			entry.LandedAt = f.LandedAt
+		}
+	}
+	if rows != entry.Rows {
+		return FileEntry{}, fmt.Errorf("row count mismatch: merged %d, wrote %d", rows, entry.Rows)
+	}
+
+	entry.Pipeline = files[0].Pipeline
+	for _, f := range files {
+		if f.Pipeline != entry.Pipeline {
+			entry.Pipeline = ""
+		}
+		entry.CompactedFrom += max(f.CompactedFrom, 1)
+	}
+	return entry, nil
+}
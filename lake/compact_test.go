@@ -0,0 +1,140 @@
+package lake
+
+import (
+	"database/sql"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCompactTable(t *testing.T) {
+	l := newTestLake(t, "a")
+	day := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		writeTestRun(t, l, "orders", day.Add(time.Duration(i)*time.Minute), []string{"id"}, [][]string{{strconv.Itoa(i)}})
+	}
+	// Alone in its partition, so left as it is
+	writeTestRun(t, l, "orders", day.AddDate(0, 0, 1), []string{"id"}, [][]string{{"9"}})
+
+	before, err := l.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRows := snapshotRows(t, l, before[0])
+
+	report, err := l.CompactTable("orders", CompactionSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesBefore != 4 || report.FilesAfter != 2 {
+		t.Errorf("compaction went from %d to %d files, want 4 to 2", report.FilesBefore, report.FilesAfter)
+	}
+
+	after, err := l.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := after[0]
+	if len(table.Files) != 2 || table.Files[0].CompactedFrom != 3 || table.Files[1].Path != before[0].Files[3].Path {
+		t.Fatalf("compacted files = %+v", table.Files)
+	}
+	if table.Rows() != before[0].Rows() {
+		t.Errorf("table has %d rows after compaction, want %d", table.Rows(), before[0].Rows())
+	}
+	if got := snapshotRows(t, l, table); !reflect.DeepEqual(got, wantRows) {
+		t.Errorf("rows per snapshot changed from %v to %v", wantRows, got)
+	}
+
+	m, err := l.LoadManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Garbage) != 0 {
+		t.Errorf("garbage left in the manifest: %v", m.Garbage)
+	}
+	for _, f := range before[0].Files[:3] {
+		if _, err := os.Stat(l.Path(f)); !os.IsNotExist(err) {
+			t.Errorf("compacted file %s still on disk: %v", f.Path, err)
+		}
+	}
+
+	// Nothing left to merge
+	report, err = l.CompactTable("orders", CompactionSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesAfter != report.FilesBefore {
+		t.Errorf("second compaction went from %d to %d files", report.FilesBefore, report.FilesAfter)
+	}
+}
+
+// snapshotRows counts the rows of the table as of each of its snapshots
+func snapshotRows(t *testing.T, l *Lake, table Table) map[string]int64 {
+	t.Helper()
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows := make(map[string]int64)
+	for _, snap := range table.Snapshots {
+		var n int64
+		if err := db.QueryRow("SELECT count(*) FROM (" + l.TableQuery(table, &snap) + ")").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		rows[snap.RunID] = n
+	}
+	return rows
+}
+
+func TestCompactionBatches(t *testing.T) {
+	file := func(path string, bytes int64) FileEntry { return FileEntry{Path: path, Bytes: bytes} }
+	settings := CompactionSettings{SmallFileBytes: 100, TargetBytes: 150}
+	tests := []struct {
+		name  string
+		files []FileEntry
+		want  [][]string
+	}{
+		{
+			name:  "merges small files of a partition",
+			files: []FileEntry{file("t/dt=1/a", 10), file("t/dt=1/b", 20), file("t/dt=1/c", 30)},
+			want:  [][]string{{"t/dt=1/a", "t/dt=1/b", "t/dt=1/c"}},
+		},
+		{
+			name:  "keeps partitions apart",
+			files: []FileEntry{file("t/dt=1/a", 10), file("t/dt=2/b", 20), file("t/dt=1/c", 30), file("t/dt=2/d", 30)},
+			want:  [][]string{{"t/dt=1/a", "t/dt=1/c"}, {"t/dt=2/b", "t/dt=2/d"}},
+		},
+		{
+			name:  "skips large files",
+			files: []FileEntry{file("t/dt=1/a", 10), file("t/dt=1/b", 100), file("t/dt=1/c", 30)},
+			want:  [][]string{{"t/dt=1/a", "t/dt=1/c"}},
+		},
+		{
+			name:  "splits at the target size",
+			files: []FileEntry{file("t/dt=1/a", 80), file("t/dt=1/b", 60), file("t/dt=1/c", 90), file("t/dt=1/d", 50)},
+			want:  [][]string{{"t/dt=1/a", "t/dt=1/b"}, {"t/dt=1/c", "t/dt=1/d"}},
+		},
+		{
+			name:  "leaves single files alone",
+			files: []FileEntry{file("t/dt=1/a", 90), file("t/dt=1/b", 90), file("t/dt=2/c", 10)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			for _, batch := range compactionBatches(tt.files, settings) {
+				var paths []string
+				for _, f := range batch {
+					paths = append(paths, f.Path)
+				}
+				got = append(got, paths)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compactionBatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package lake
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	dbFile = "_lake.duckdb"
+	// StateTable records what the objects in the lake database were built
+	// from, so they are only rebuilt when the catalog changes. Lake tables
+	// never start with an underscore, so none can share its name.
+	StateTable = "_pipeterm_catalog"
+)
+
+// lakeDB is an open lake database. DuckDB allows a single read-write handle
+// per file, so every caller in the process shares one.
+type lakeDB struct {
+	db *sql.DB
+	// mu serializes refreshes of the catalog objects
+	mu sync.Mutex
+}
+
+var (
+	dbMu sync.Mutex
+	dbs  = make(map[string]*lakeDB)
+)
+
+// tableState is a row of the state table.
+type tableState struct {
+	fingerprint  string
+	mode         LoadMode
+	materialized bool
+	files        []string
+}
+
+// DB returns the lake's persistent DuckDB database with a view for every
+// catalog table, brought up to date with the manifest first. The database
+// lives in _lake.duckdb inside the lake and is refreshed by these rules:
+//
+//   - A table whose files, load mode, primary key, latest snapshot,
+//     materialization and lake directory are unchanged is left alone.
+//   - A materialized append table that only gained files, none of which
+//     add columns, has just the new files' rows inserted.
+//   - Any other change rebuilds the table's objects. That covers new
+//     snapshots of full refresh and upsert tables, compaction, retention,
+//     schema drift and switching materialization on or off.
+//   - Objects missing from the database are rebuilt and objects of tables
+//     no longer in the catalog are dropped.
+//
+// Each table is refreshed in its own transaction, so queries running at
+// the same time never see a half-built table.
+func (l *Lake) DB(ctx context.Context) (*sql.DB, error) {
+	h, err := l.openDB()
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := l.refreshDB(ctx, h.db); err != nil {
+		return nil, fmt.Errorf("refreshing lake database: %w", err)
+	}
+	return h.db, nil
+}
+
+func (l *Lake) openDB() (*lakeDB, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if h, ok := dbs[l.Dir]; ok {
+		return h, nil
+	}
+	db, err := sql.Open("duckdb", filepath.Join(l.Dir, dbFile))
+	if err != nil {
+		return nil, err
+	}
+	createStateQuery := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		table_name VARCHAR PRIMARY KEY,
+		fingerprint VARCHAR,
+		mode VARCHAR,
+		materialized BOOLEAN,
+		files VARCHAR
+	);`, StateTable)
+	if _, err := db.Exec(createStateQuery); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening lake database: %w", err)
+	}
+	h := &lakeDB{db: db}
+	dbs[l.Dir] = h
+	return h, nil
+}
+
+// closeDB closes the shared database of the lake in dir, if open.
+func closeDB(dir string) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if h, ok := dbs[dir]; ok {
+		h.mu.Lock()
+		h.db.Close()
+		h.mu.Unlock()
+		delete(dbs, dir)
+	}
+}
+
+func (l *Lake) refreshDB(ctx context.Context, db *sql.DB) error {
+	tables, err := l.Tables()
+	if err != nil {
+		return err
+	}
+	settings, err := l.LoadSettings()
+	if err != nil {
+		return err
+	}
+	materialized := make(map[string]bool, len(settings.Materialized))
+	for _, name := range settings.Materialized {
+		materialized[name] = true
+	}
+	states, err := loadTableStates(ctx, db)
+	if err != nil {
+		return err
+	}
+	objects, err := loadObjects(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	inCatalog := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		inCatalog[t.Name] = true
+		mat := materialized[t.Name]
+		fingerprint := l.tableFingerprint(t, mat)
+		state, ok := states[t.Name]
+
+		wantKind := "view"
+		if mat {
+			wantKind = "table"
+		}
+		present := objects[t.Name] == wantKind && objects[t.Name+LatestSuffix] == "view"
+		if ok && present && state.fingerprint == fingerprint {
+			continue
+		}
+
+		newFiles, appendOnly := appendedFiles(state, t)
+		if ok && present && mat && state.materialized && appendOnly {
+			err = l.insertFiles(ctx, db, t, newFiles, fingerprint)
+		} else {
+			err = l.rebuild(ctx, db, t, mat, fingerprint, objects)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+	}
+
+	for name := range states {
+		if inCatalog[name] {
+			continue
+		}
+		if err := dropTable(ctx, db, name, objects); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// appendedFiles returns the files t gained since state was recorded. It
+// reports false unless both are append tables and every previously loaded
+// file is still in the catalog.
+func appendedFiles(state tableState, t Table) ([]FileEntry, bool) {
+	if state.mode != Append || t.Meta.Mode != Append {
+		return nil, false
+	}
+	loaded := make(map[string]bool, len(state.files))
+	for _, path := range state.files {
+		loaded[path] = true
+	}
+	var newFiles []FileEntry
+	for _, f := range t.Files {
+		if loaded[f.Path] {
+			delete(loaded, f.Path)
+			continue
+		}
+		newFiles = append(newFiles, f)
+	}
+	return newFiles, len(loaded) == 0 && len(newFiles) > 0
+}
+
+// insertFiles appends the rows of newly landed files to a materialized
+// table. Files that add columns need a rebuild instead.
+func (l *Lake) insertFiles(ctx context.Context, db *sql.DB, t Table, newFiles []FileEntry, fingerprint string) error {
+	columns, err := describe(db, QuoteIdent(t.Name))
+	if err != nil {
+		return err
+	}
+	for _, f := range newFiles {
+		for _, c := range f.Schema {
+			if !hasColumn(columns, c.Name) {
+				objects, err := loadObjects(ctx, db)
+				if err != nil {
+					return err
+				}
+				return l.rebuild(ctx, db, t, true, fingerprint, objects)
+			}
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insertQuery := fmt.Sprintf("INSERT INTO %s BY NAME %s;", QuoteIdent(t.Name), l.filesSelect(newFiles, true))
+	if _, err := tx.ExecContext(ctx, insertQuery); err != nil {
+		return err
+	}
+	latestViewQuery := fmt.Sprintf("CREATE OR REPLACE VIEW %s AS SELECT * FROM (%s) WHERE %s = %s;",
+		QuoteIdent(t.Name+LatestSuffix), l.tableSelect(t), SnapshotColumn, timestampLiteral(t.Latest().Timestamp))
+	if _, err := tx.ExecContext(ctx, latestViewQuery); err != nil {
+		return err
+	}
+	if err := saveTableState(ctx, tx, t, true, fingerprint); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebuild drops and recreates every object of a table.
+func (l *Lake) rebuild(ctx context.Context, db *sql.DB, t Table, materialize bool, fingerprint string, objects map[string]string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, name := range []string{t.Name, t.Name + LatestSuffix} {
+		if err := dropObject(ctx, tx, name, objects[name]); err != nil {
+			return err
+		}
+	}
+	if err := l.createViews(ctx, tx, t, materialize); err != nil {
+		return err
+	}
+	if err := saveTableState(ctx, tx, t, materialize, fingerprint); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// dropTable removes the objects and state of a table that left the catalog.
+func dropTable(ctx context.Context, db *sql.DB, name string, objects map[string]string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, object := range []string{name, name + LatestSuffix} {
+		if err := dropObject(ctx, tx, object, objects[object]); err != nil {
+			return err
+		}
+	}
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE table_name = ?;", StateTable)
+	if _, err := tx.ExecContext(ctx, deleteQuery, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// dropObject drops a view or table. kind is empty if it does not exist.
+func dropObject(ctx context.Context, db Execer, name, kind string) error {
+	if kind == "" {
+		return nil
+	}
+	dropQuery := fmt.Sprintf("DROP %s IF EXISTS %s;", kind, QuoteIdent(name))
+	_, err := db.ExecContext(ctx, dropQuery)
+	return err
+}
+
+// loadObjects returns the kind, "table" or "view", of every user object in
+// the main schema of the lake database.
+func loadObjects(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT table_name, 'table' FROM duckdb_tables()
+		WHERE schema_name = 'main' AND database_name = current_database()
+		UNION ALL
+		SELECT view_name, 'view' FROM duckdb_views()
+		WHERE schema_name = 'main' AND database_name = current_database() AND NOT internal`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	objects := make(map[string]string)
+	for rows.Next() {
+		var name, kind string
+		if err := rows.Scan(&name, &kind); err != nil {
+			return nil, err
+		}
+		objects[name] = kind
+	}
+	return objects, rows.Err()
+}
+
+func loadTableStates(ctx context.Context, db *sql.DB) (map[string]tableState, error) {
+	query := fmt.Sprintf("SELECT table_name, fingerprint, mode, materialized, files FROM %s", StateTable)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string]tableState)
+	for rows.Next() {
+		var name, mode, files string
+		var state tableState
+		if err := rows.Scan(&name, &state.fingerprint, &mode, &state.materialized, &files); err != nil {
+			return nil, err
+		}
+		state.mode = LoadMode(mode)
+		if err := json.Unmarshal([]byte(files), &state.files); err != nil {
+			return nil, err
+		}
+		states[name] = state
+	}
+	return states, rows.Err()
+}
+
+func saveTableState(ctx context.Context, db Execer, t Table, materialized bool, fingerprint string) error {
+	paths := make([]string, len(t.Files))
+	for i, f := range t.Files {
+		paths[i] = f.Path
+	}
+	files, err := json.Marshal(paths)
+	if err != nil {
+		return err
+	}
+	saveQuery := fmt.Sprintf("INSERT OR REPLACE INTO %s VALUES (?, ?, ?, ?, ?);", StateTable)
+	_, err = db.ExecContext(ctx, saveQuery, t.Name, fingerprint, string(t.Meta.Mode), materialized, string(files))
+	return err
+}
+
+// tableFingerprint hashes everything the objects of a table are built from.
+// The views read the lake files by absolute path, so the lake's directory is
+// part of it and a renamed lake rebuilds its views.
+func (l *Lake) tableFingerprint(t Table, materialized bool) string {
+	files := make([]string, len(t.Files))
+	for i, f := range t.Files {
+		files[i] = f.Path + "@" + f.Checksum
+	}
+	data, _ := json.Marshal(struct {
+		Dir          string
+		Meta         TableMeta
+		Files        []string
+		Latest       string
+		Materialized bool
+	}{l.Dir, t.Meta, files, timestampLiteral(t.Latest().Timestamp), materialized})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,130 @@
+package lake
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DriftKind describes how a column changed between two snapshots.
+type DriftKind string
+
+const (
+	DriftAdded       DriftKind = "added"
+	DriftRemoved     DriftKind = "removed"
+	DriftRenamed     DriftKind = "renamed"
+	DriftTypeChanged DriftKind = "type_changed"
+)
+
+// DriftPolicy decides what happens to a run whose schema drifted.
+type DriftPolicy string
+
+const (
+	// DriftAllow lands the run and records the drift.
+	DriftAllow DriftPolicy = "allow"
+	// DriftWarn lands the run, records the drift and reports it to the pipeline.
+	DriftWarn DriftPolicy = "warn"
+	// DriftFail rejects the run and lands nothing.
+	DriftFail DriftPolicy = "fail"
+)
+
+// DriftPolicies lists every policy in the order the UI cycles through them.
+var DriftPolicies = []DriftPolicy{DriftWarn, DriftAllow, DriftFail}
+
+// DriftEvent records a single column change detected when a run landed.
+type DriftEvent struct {
+	Table      string    `json:"table"`
+	RunID      string    `json:"run_id"`
+	Kind       DriftKind `json:"kind"`
+	Column     string    `json:"column"`
+	NewColumn  string    `json:"new_column,omitempty"`
+	OldType    string    `json:"old_type,omitempty"`
+	NewType    string    `json:"new_type,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+func (e DriftEvent) String() string {
+	switch e.Kind {
+	case DriftAdded:
+		return fmt.Sprintf("%s: column %s added (%s)", e.Table, e.Column, e.NewType)
+	case DriftRemoved:
+		return fmt.Sprintf("%s: column %s removed", e.Table, e.Column)
+	case DriftRenamed:
+		return fmt.Sprintf("%s: column %s renamed to %s", e.Table, e.Column, e.NewColumn)
+	case DriftTypeChanged:
+		return fmt.Sprintf("%s: column %s changed type from %s to %s", e.Table, e.Column, e.OldType, e.NewType)
+	}
+	return fmt.Sprintf("%s: column %s %s", e.Table, e.Column, e.Kind)
+}
+
+// DriftError is returned by Commit when the DriftFail policy rejects a run.
+type DriftError struct {
+	Events []DriftEvent
+}
+
+func (e *DriftError) Error() string {
+	changes := make([]string, len(e.Events))
+	for i, event := range e.Events {
+		changes[i] = event.String()
+	}
+	return "schema drift: " + strings.Join(changes, "; ")
+}
+
+// DiffSchema compares the schema of a new snapshot with the previous one.
+// A column removed and another added at the same position with the same
+// type is reported as a rename. The snapshot column is ignored.
+func DiffSchema(previous, current []Column) []DriftEvent {
+	previous = withoutSnapshotColumn(previous)
+	current = withoutSnapshotColumn(current)
+
+	prevByName := make(map[string]int, len(previous))
+	for i, c := range previous {
+		prevByName[c.Name] = i
+	}
+	curByName := make(map[string]int, len(current))
+	for i, c := range current {
+		curByName[c.Name] = i
+	}
+
+	var events []DriftEvent
+	removed := make(map[int]Column)
+	for i, c := range previous {
+		j, ok := curByName[c.Name]
+		if !ok {
+			removed[i] = c
+			continue
+		}
+		if current[j].Type != c.Type {
+			events = append(events, DriftEvent{Kind: DriftTypeChanged, Column: c.Name, OldType: c.Type, NewType: current[j].Type})
+		}
+	}
+
+	for i, c := range current {
+		if _, ok := prevByName[c.Name]; ok {
+			continue
+		}
+		if old, ok := removed[i]; ok && old.Type == c.Type {
+			events = append(events, DriftEvent{Kind: DriftRenamed, Column: old.Name, NewColumn: c.Name, OldType: old.Type, NewType: c.Type})
+			delete(removed, i)
+			continue
+		}
+		events = append(events, DriftEvent{Kind: DriftAdded, Column: c.Name, NewType: c.Type})
+	}
+
+	for i, c := range previous {
+		if old, ok := removed[i]; ok {
+			events = append(events, DriftEvent{Kind: DriftRemoved, Column: old.Name, OldType: c.Type})
+		}
+	}
+	return events
+}
+
+func withoutSnapshotColumn(schema []Column) []Column {
+	var columns []Column
+	for _, c := range schema {
+		if c.Name != SnapshotColumn {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
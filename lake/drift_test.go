@@ -0,0 +1,164 @@
+package lake
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestDiffSchema(t *testing.T) {
+	col := func(name, typ string) Column { return Column{Name: name, Type: typ} }
+	tests := []struct {
+		name     string
+		previous []Column
+		current  []Column
+		want     []DriftEvent
+	}{
+		{
+			name:     "unchanged",
+			previous: []Column{col("id", "BIGINT"), col("name", "VARCHAR")},
+			current:  []Column{col("id", "BIGINT"), col("name", "VARCHAR")},
+		},
+		{
+			name:     "snapshot column ignored",
+			previous: []Column{col("id", "BIGINT")},
+			current:  []Column{col("id", "BIGINT"), col(SnapshotColumn, "TIMESTAMP")},
+		},
+		{
+			name:     "added",
+			previous: []Column{col("id", "BIGINT")},
+			current:  []Column{col("id", "BIGINT"), col("email", "VARCHAR")},
+			want:     []DriftEvent{{Kind: DriftAdded, Column: "email", NewType: "VARCHAR"}},
+		},
+		{
+			name:     "removed",
+			previous: []Column{col("id", "BIGINT"), col("email", "VARCHAR")},
+			current:  []Column{col("id", "BIGINT")},
+			want:     []DriftEvent{{Kind: DriftRemoved, Column: "email", OldType: "VARCHAR"}},
+		},
+		{
+			name:     "type changed",
+			previous: []Column{col("id", "BIGINT"), col("total", "BIGINT")},
+			current:  []Column{col("id", "BIGINT"), col("total", "DOUBLE")},
+			want:     []DriftEvent{{Kind: DriftTypeChanged, Column: "total", OldType: "BIGINT", NewType: "DOUBLE"}},
+		},
+		{
+			name:     "renamed in place",
+			previous: []Column{col("id", "BIGINT"), col("mail", "VARCHAR")},
+			current:  []Column{col("id", "BIGINT"), col("email", "VARCHAR")},
+			want:     []DriftEvent{{Kind: DriftRenamed, Column: "mail", NewColumn: "email", OldType: "VARCHAR", NewType: "VARCHAR"}},
+		},
+		{
+			name:     "replaced with another type",
+			previous: []Column{col("id", "BIGINT"), col("mail", "VARCHAR")},
+			current:  []Column{col("id", "BIGINT"), col("age", "BIGINT")},
+			want: []DriftEvent{
+				{Kind: DriftAdded, Column: "age", NewType: "BIGINT"},
+				{Kind: DriftRemoved, Column: "mail", OldType: "VARCHAR"},
+			},
+		},
+		{
+			name:     "moved",
+			previous: []Column{col("id", "BIGINT"), col("name", "VARCHAR")},
+			current:  []Column{col("name", "VARCHAR"), col("id", "BIGINT")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffSchema(tt.previous, tt.current)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffSchema() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitDriftPolicy(t *testing.T) {
+	tests := []struct {
+		policy    DriftPolicy
+		wantLands bool
+	}{
+		{policy: DriftAllow, wantLands: true},
+		{policy: DriftWarn, wantLands: true},
+		{policy: DriftFail, wantLands: false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			l := newTestLake(t, "a")
+			first := time.Now().Add(-time.Hour)
+			writeTestRun(t, l, "orders", first, []string{"id"}, [][]string{{"1"}})
+			manifestBefore, err := os.ReadFile(filepath.Join(l.Dir, manifestFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			filesBefore := lakeFiles(t, l)
+
+			w, err := l.NewWriter("test", NewRunID(time.Now()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.SetDriftPolicy(tt.policy)
+			if err := w.WriteRows("orders", []string{"id", "email"}, [][]string{{"2", "a@example.com"}}); err != nil {
+				t.Fatal(err)
+			}
+			_, err = w.Commit()
+			want := []DriftEvent{{Table: "orders", RunID: w.runID, Kind: DriftAdded, Column: "email", NewType: "VARCHAR", DetectedAt: w.startedAt}}
+			if !reflect.DeepEqual(w.Drift(), want) {
+				t.Errorf("Drift() = %+v, want %+v", w.Drift(), want)
+			}
+
+			if tt.wantLands {
+				if err != nil {
+					t.Fatal(err)
+				}
+				tables, err := l.Tables()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(tables[0].Snapshots) != 2 || len(tables[0].Drift) != 1 {
+					t.Errorf("orders has %d snapshots and %d drift events, want 2 and 1",
+						len(tables[0].Snapshots), len(tables[0].Drift))
+				}
+				return
+			}
+
+			var driftErr *DriftError
+			if !errors.As(err, &driftErr) {
+				t.Fatalf("Commit() error = %v, want a DriftError", err)
+			}
+			manifestAfter, err := os.ReadFile(filepath.Join(l.Dir, manifestFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(manifestAfter) != string(manifestBefore) {
+				t.Errorf("rejected run changed the manifest:\n%s", manifestAfter)
+			}
+			if filesAfter := lakeFiles(t, l); !reflect.DeepEqual(filesAfter, filesBefore) {
+				t.Errorf("rejected run changed the lake files from %v to %v", filesBefore, filesAfter)
+			}
+		})
+	}
+}
+
+// lakeFiles lists every file of the lake, relative to its directory
+func lakeFiles(t *testing.T, l *Lake) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(l.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(l.Dir, path)
+		files = append(files, rel)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
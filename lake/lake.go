@@ -0,0 +1,145 @@
+// Package lake owns every write into the pipeterm data lakes. Connector
+// scripts stage their output and the lake validates it, moves it into the
+// Hive-style table layout and records it in the lake manifest.
+package lake
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Lake is a single named directory under the pipeterm lake root.
+type Lake struct {
+	Name string
+	Dir  string
+}
+
+// Root returns the directory holding every data lake.
+func Root() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share", "pipeterm_lake"), nil
+}
+
+// Open returns the named lake, creating its directory if needed.
+func Open(name string) (*Lake, error) {
+	dir, err := lakeDir(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Lake{Name: name, Dir: dir}, nil
+}
+
+// OpenExisting returns the named lake without creating it. It fails if the
+// lake is gone, e.g. because it was renamed or deleted after being listed.
+func OpenExisting(name string) (*Lake, error) {
+	dir, err := lakeDir(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("lake %s does not exist", name)
+	}
+	return &Lake{Name: name, Dir: dir}, nil
+}
+
+// Create makes a new, empty lake. It fails if the lake already exists.
+func Create(name string) (*Lake, error) {
+	dir, err := lakeDir(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(dir, 0755); err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("lake %s already exists", name)
+		}
+		return nil, err
+	}
+	return &Lake{Name: name, Dir: dir}, nil
+}
+
+// Rename moves a lake and everything in it to a new name. Catalog paths are
+// relative to the lake, so the manifest needs no rewrite. The views of the
+// lake database read files by absolute path; their fingerprints include the
+// lake's directory, so they are rebuilt the next time the lake database is used.
+func Rename(oldName, newName string) error {
+	oldDir, err := lakeDir(oldName)
+	if err != nil {
+		return err
+	}
+	newDir, err := lakeDir(newName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(oldDir); err != nil {
+		return fmt.Errorf("lake %s does not exist", oldName)
+	}
+	if _, err := os.Stat(newDir); err == nil {
+		return fmt.Errorf("lake %s already exists", newName)
+	}
+	closeDB(oldDir)
+	return os.Rename(oldDir, newDir)
+}
+
+// Delete removes a lake and every file in it.
+func Delete(name string) error {
+	dir, err := lakeDir(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("lake %s does not exist", name)
+	}
+	closeDB(dir)
+	return os.RemoveAll(dir)
+}
+
+// DiskUsage returns the size of every file in the lake, including staging
+// leftovers and files not yet in the catalog.
+func (l *Lake) DiskUsage() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(l.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// lakeDir returns the directory of the named lake. Names are single path
+// elements that List would show, so they cannot escape the lake root.
+func lakeDir(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid lake name %q", name)
+	}
+	root, err := Root()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, name), nil
+}
+
+// NewRunID returns an identifier for a pipeline run started at t.
+func NewRunID(t time.Time) string {
+	return fmt.Sprintf("%s-%03d", t.Format("20060102-150405"), t.Nanosecond()/int(time.Millisecond))
+}
@@ -0,0 +1,86 @@
+package lake
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestLake creates a lake under a temporary home directory
+func newTestLake(t *testing.T, name string) *Lake {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	l, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// writeTestRun lands rows as a run of table
+func writeTestRun(t *testing.T, l *Lake, table string, at time.Time, columns []string, rows [][]string) {
+	t.Helper()
+	w, err := l.NewWriter("test", NewRunID(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.startedAt = at.Truncate(time.Microsecond)
+	if err := w.WriteRows(table, columns, rows); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenameRebuildsViews(t *testing.T) {
+	l := newTestLake(t, "a")
+	writeTestRun(t, l, "orders", time.Now(), []string{"id"}, [][]string{{"1"}, {"2"}})
+
+	ctx := context.Background()
+	count := func(l *Lake, table string) int64 {
+		t.Helper()
+		db, err := l.DB(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int64
+		if err := db.QueryRowContext(ctx, "SELECT count(*) FROM "+QuoteIdent(table)).Scan(&n); err != nil {
+			t.Fatalf("querying %s of lake %s: %v", table, l.Name, err)
+		}
+		return n
+	}
+	if n := count(l, "orders"); n != 2 {
+		t.Fatalf("orders has %d rows before the rename, want 2", n)
+	}
+
+	if err := Rename("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := Open("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"orders", "orders" + LatestSuffix} {
+		if n := count(renamed, table); n != 2 {
+			t.Errorf("%s has %d rows after the rename, want 2", table, n)
+		}
+	}
+}
+
+func TestOpenExistingDoesNotCreate(t *testing.T) {
+	l := newTestLake(t, "a")
+	if _, err := OpenExisting("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenExisting("a"); err == nil {
+		t.Error("opened a deleted lake")
+	}
+	if _, err := os.Stat(l.Dir); !os.IsNotExist(err) {
+		t.Errorf("deleted lake directory is back: %v", err)
+	}
+}
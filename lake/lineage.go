@@ -0,0 +1,441 @@
+package lake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	lineageFile = "_lineage.json"
+	// maxLineageQueries is how many distinct queries lineage remembers
+	maxLineageQueries = 200
+)
+
+// lineageMu serialises lineage updates within the process.
+var lineageMu sync.Mutex
+
+// NodeKind is the kind of object a lineage node stands for.
+type NodeKind string
+
+const (
+	NodePipeline NodeKind = "pipeline"
+	NodeRun      NodeKind = "run"
+	NodeFile     NodeKind = "file"
+	NodeTable    NodeKind = "table"
+	// NodeDerived is a table or view created by a query in the lake
+	// database, such as CREATE TABLE ... AS SELECT.
+	NodeDerived NodeKind = "derived"
+	NodeQuery   NodeKind = "query"
+)
+
+// Node is an object in the lineage graph. Runs are named by run ID, files
+// by their path in the lake and queries by their whitespace-normalized text.
+type Node struct {
+	Kind NodeKind `json:"kind"`
+	Name string   `json:"name"`
+}
+
+func (n Node) String() string {
+	return fmt.Sprintf("%s %s", n.Kind, n.Name)
+}
+
+// Edge records that To was produced from, or reads, From.
+type Edge struct {
+	From Node      `json:"from"`
+	To   Node      `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// Lineage is the lineage graph of a lake: pipelines produce runs, runs land
+// files, files make up tables, and tables feed derived tables and queries.
+type Lineage struct {
+	Edges []Edge `json:"edges"`
+	// live holds the nodes that still exist in the catalog. Runs expired
+	// by retention and files replaced by compaction stay in the graph.
+	live map[Node]bool
+}
+
+// Lineage returns the lineage graph of the lake. Edges recorded when runs
+// landed, files were rewritten and queries ran are combined with the edges
+// implied by the manifest, so lakes that predate lineage tracking still have
+// the pipeline, run, file and table edges of their current files.
+func (l *Lake) Lineage() (*Lineage, error) {
+	g, err := l.loadLineage()
+	if err != nil {
+		return nil, err
+	}
+	m, err := l.LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := make(map[Node]bool)
+	for _, e := range g.Edges {
+		recorded[e.From] = true
+	}
+	g.live = make(map[Node]bool)
+	var implied []Edge
+	landed := make(map[string]bool)
+	for _, f := range m.Files {
+		file := Node{NodeFile, f.Path}
+		table := Node{NodeTable, f.Table}
+		g.live[file] = true
+		g.live[table] = true
+		implied = append(implied, Edge{file, table, f.LandedAt})
+		if f.CompactedFrom == 0 {
+			implied = append(implied, Edge{Node{NodeRun, f.RunID}, file, f.LandedAt})
+			landed[f.Table+"\x00"+f.RunID] = true
+		}
+	}
+	for _, snap := range m.Snapshots {
+		run := Node{NodeRun, snap.RunID}
+		g.live[run] = true
+		if snap.Pipeline != "" {
+			pipeline := Node{NodePipeline, snap.Pipeline}
+			g.live[pipeline] = true
+			implied = append(implied, Edge{pipeline, run, snap.Timestamp})
+		}
+		if !landed[snap.Table+"\x00"+snap.RunID] && !recorded[run] {
+			// The run's files have been compacted
+			implied = append(implied, Edge{run, Node{NodeTable, snap.Table}, snap.Timestamp})
+		}
+	}
+	g.add(implied...)
+	for _, e := range g.Edges {
+		if e.From.Kind == NodeDerived || e.From.Kind == NodeQuery {
+			g.live[e.From] = true
+		}
+		if e.To.Kind == NodeDerived || e.To.Kind == NodeQuery {
+			g.live[e.To] = true
+		}
+	}
+	return g, nil
+}
+
+func (l *Lake) loadLineage() (*Lineage, error) {
+	data, err := os.ReadFile(filepath.Join(l.Dir, lineageFile))
+	if os.IsNotExist(err) {
+		return &Lineage{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var g Lineage
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("reading lineage: %w", err)
+	}
+	return &g, nil
+}
+
+// add appends edges, keeping only the latest time of an edge seen twice.
+func (g *Lineage) add(edges ...Edge) {
+	index := make(map[[2]Node]int, len(g.Edges))
+	for i, e := range g.Edges {
+		index[[2]Node{e.From, e.To}] = i
+	}
+	for _, e := range edges {
+		key := [2]Node{e.From, e.To}
+		if i, ok := index[key]; ok {
+			if e.At.After(g.Edges[i].At) {
+				g.Edges[i].At = e.At
+			}
+			continue
+		}
+		index[key] = len(g.Edges)
+		g.Edges = append(g.Edges, e)
+	}
+}
+
+// recordLineage adds edges to the lineage file. Files at the replaced paths
+// were rewritten into new files, so their own edges to their table are
+// dropped. Lineage is bookkeeping, so a failure to record it is logged rather
+// than failing the operation that produced the edges.
+func (l *Lake) recordLineage(edges []Edge, replaced []string) {
+	if err := l.saveLineage(edges, replaced); err != nil {
+		l.LogMaintenance("recording lineage failed: %v", err)
+	}
+}
+
+func (l *Lake) saveLineage(edges []Edge, replaced []string) error {
+	lineageMu.Lock()
+	defer lineageMu.Unlock()
+
+	g, err := l.loadLineage()
+	if err != nil {
+		return err
+	}
+	if len(replaced) > 0 {
+		gone := make(map[Node]bool, len(replaced))
+		for _, path := range replaced {
+			gone[Node{NodeFile, path}] = true
+		}
+		var kept []Edge
+		for _, e := range g.Edges {
+			if !gone[e.From] || e.To.Kind != NodeTable {
+				kept = append(kept, e)
+			}
+		}
+		g.Edges = kept
+	}
+	g.add(edges...)
+	g.trimQueries()
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(l.Dir, lineageFile), data)
+}
+
+// trimQueries forgets the edges of all but the most recent queries.
+func (g *Lineage) trimQueries() {
+	lastRun := make(map[Node]time.Time)
+	for _, e := range g.Edges {
+		if e.To.Kind == NodeQuery && e.At.After(lastRun[e.To]) {
+			lastRun[e.To] = e.At
+		}
+	}
+	if len(lastRun) <= maxLineageQueries {
+		return
+	}
+	queries := make([]Node, 0, len(lastRun))
+	for q := range lastRun {
+		queries = append(queries, q)
+	}
+	sort.Slice(queries, func(i, j int) bool { return lastRun[queries[i]].After(lastRun[queries[j]]) })
+	forget := make(map[Node]bool)
+	for _, q := range queries[maxLineageQueries:] {
+		forget[q] = true
+	}
+	var edges []Edge
+	for _, e := range g.Edges {
+		if !forget[e.To] {
+			edges = append(edges, e)
+		}
+	}
+	g.Edges = edges
+}
+
+// Upstream returns the nodes n was directly produced from.
+func (g *Lineage) Upstream(n Node) []Node {
+	var nodes []Node
+	for _, e := range g.Edges {
+		if e.To == n {
+			nodes = append(nodes, e.From)
+		}
+	}
+	return sortNodes(nodes)
+}
+
+// Downstream returns the nodes directly produced from n.
+func (g *Lineage) Downstream(n Node) []Node {
+	var nodes []Node
+	for _, e := range g.Edges {
+		if e.From == n {
+			nodes = append(nodes, e.To)
+		}
+	}
+	return sortNodes(nodes)
+}
+
+// Ancestors returns every node of the given kind n transitively depends on.
+func (g *Lineage) Ancestors(n Node, kind NodeKind) []Node {
+	return g.walk(n, kind, g.Upstream)
+}
+
+// Descendants returns every node of the given kind that transitively
+// depends on n, i.e. what breaks when n stops being updated.
+func (g *Lineage) Descendants(n Node, kind NodeKind) []Node {
+	return g.walk(n, kind, g.Downstream)
+}
+
+func (g *Lineage) walk(start Node, kind NodeKind, next func(Node) []Node) []Node {
+	seen := map[Node]bool{start: true}
+	queue := []Node{start}
+	var found []Node
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, m := range next(n) {
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+			queue = append(queue, m)
+			if m.Kind == kind {
+				found = append(found, m)
+			}
+		}
+	}
+	return sortNodes(found)
+}
+
+// Live reports whether a node still exists. Runs dropped by retention and
+// files rewritten by compaction are kept in the graph as history.
+func (g *Lineage) Live(n Node) bool {
+	return g.live[n]
+}
+
+// Edge returns the edge between two nodes, if any.
+func (g *Lineage) Edge(from, to Node) (Edge, bool) {
+	for _, e := range g.Edges {
+		if e.From == from && e.To == to {
+			return e, true
+		}
+	}
+	return Edge{}, false
+}
+
+func sortNodes(nodes []Node) []Node {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Kind != nodes[j].Kind {
+			return nodes[i].Kind < nodes[j].Kind
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
+
+// runLineage returns the edges of the files a run landed.
+func runLineage(pipeline, runID string, files []FileEntry) []Edge {
+	run := Node{NodeRun, runID}
+	edges := []Edge{{Node{NodePipeline, pipeline}, run, time.Now()}}
+	for _, f := range files {
+		file := Node{NodeFile, f.Path}
+		edges = append(edges, Edge{run, file, f.LandedAt}, Edge{file, Node{NodeTable, f.Table}, f.LandedAt})
+	}
+	return edges
+}
+
+// rewriteLineage returns the edges from the files merged or rewritten into
+// a new file, so its rows can be traced back to the runs that landed them.
+func rewriteLineage(sources []FileEntry, rewritten FileEntry) []Edge {
+	file := Node{NodeFile, rewritten.Path}
+	edges := []Edge{{file, Node{NodeTable, rewritten.Table}, time.Now()}}
+	for _, f := range sources {
+		edges = append(edges, Edge{Node{NodeFile, f.Path}, file, time.Now()})
+	}
+	return edges
+}
+
+// createPattern matches the object created by CREATE TABLE/VIEW ... AS.
+var createPattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(?:TEMP(?:ORARY)?\s+)?(?:TABLE|VIEW)\s+(?:IF\s+NOT\s+EXISTS\s+)?("(?:[^"]|"")+"|[A-Za-z_][A-Za-z0-9_]*)`)
+
+// RecordQuery records the lineage of a query run against the lake database:
+// an edge from every table or derived table it reads to the query or, for
+// CREATE TABLE and CREATE VIEW, to the derived table it creates. Queries
+// that read no lake table are not recorded.
+func (l *Lake) RecordQuery(query string, at time.Time) error {
+	tables, err := l.Tables()
+	if err != nil {
+		return err
+	}
+	g, err := l.loadLineage()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]Node)
+	for _, e := range g.Edges {
+		if e.To.Kind == NodeDerived {
+			known[strings.ToLower(e.To.Name)] = e.To
+		}
+	}
+	for _, t := range tables {
+		known[strings.ToLower(t.Name)] = Node{NodeTable, t.Name}
+	}
+
+	target := Node{NodeQuery, strings.Join(strings.Fields(query), " ")}
+	if m := createPattern.FindStringSubmatch(query); m != nil {
+		target = Node{NodeDerived, unquoteIdent(m[1])}
+	}
+
+	var edges []Edge
+	seen := make(map[Node]bool)
+	for _, ident := range identifiers(query) {
+		name := strings.ToLower(ident)
+		if i := strings.Index(name, "@"); i > 0 {
+			// A time-travel view such as "orders@<run id>"
+			name = name[:i]
+		}
+		n, ok := known[name]
+		if !ok {
+			n, ok = known[strings.TrimSuffix(name, LatestSuffix)]
+		}
+		if !ok || seen[n] || n == target {
+			continue
+		}
+		seen[n] = true
+		edges = append(edges, Edge{n, target, at})
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	return l.saveLineage(edges, nil)
+}
+
+// identifiers returns the bare and double-quoted identifiers of a query,
+// skipping string literals and comments.
+func identifiers(query string) []string {
+	var idents []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			i++
+			for i < len(query) {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case c == '"':
+			var sb strings.Builder
+			i++
+			for i < len(query) {
+				if query[i] == '"' {
+					if i+1 < len(query) && query[i+1] == '"' {
+						sb.WriteByte('"')
+						i += 2
+						continue
+					}
+					break
+				}
+				sb.WriteByte(query[i])
+				i++
+			}
+			i++
+			idents = append(idents, sb.String())
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return idents
+			}
+			i += end + 4
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			start := i
+			for i < len(query) && (query[i] == '_' || query[i] >= 'A' && query[i] <= 'Z' ||
+				query[i] >= 'a' && query[i] <= 'z' || query[i] >= '0' && query[i] <= '9') {
+				i++
+			}
+			idents = append(idents, query[start:i])
+		default:
+			i++
+		}
+	}
+	return idents
+}
@@ -0,0 +1,203 @@
+package lake
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func loadTestLineage(t *testing.T, l *Lake) *Lineage {
+	t.Helper()
+	g, err := l.Lineage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestRunLineage(t *testing.T) {
+	l := newTestLake(t, "a")
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	writeTestRun(t, l, "orders", at, []string{"id"}, [][]string{{"1"}})
+	tables, err := l.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline := Node{NodePipeline, "test"}
+	run := Node{NodeRun, NewRunID(at)}
+	file := Node{NodeFile, tables[0].Files[0].Path}
+	table := Node{NodeTable, "orders"}
+
+	g := loadTestLineage(t, l)
+	tests := []struct {
+		name string
+		got  []Node
+		want []Node
+	}{
+		{"pipeline downstream", g.Downstream(pipeline), []Node{run}},
+		{"run downstream", g.Downstream(run), []Node{file}},
+		{"table upstream", g.Upstream(table), []Node{file}},
+		{"tables of the pipeline", g.Descendants(pipeline, NodeTable), []Node{table}},
+		{"pipelines of the table", g.Ancestors(table, NodePipeline), []Node{pipeline}},
+		{"runs of the table", g.Ancestors(table, NodeRun), []Node{run}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	for _, n := range []Node{pipeline, run, file, table} {
+		if !g.Live(n) {
+			t.Errorf("%s is not live", n)
+		}
+	}
+}
+
+func TestRecordQuery(t *testing.T) {
+	l := newTestLake(t, "a")
+	writeTestRun(t, l, "orders", time.Now(), []string{"id"}, [][]string{{"1"}})
+	writeTestRun(t, l, "customers", time.Now(), []string{"id"}, [][]string{{"1"}})
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+
+	orders := Node{NodeTable, "orders"}
+	customers := Node{NodeTable, "customers"}
+	summary := Node{NodeDerived, "summary"}
+	tests := []struct {
+		name  string
+		query string
+		// target is the node the query is recorded as, if any, and want
+		// what it reads
+		target Node
+		want   []Node
+	}{
+		{
+			name:   "create table",
+			query:  `CREATE TABLE summary AS SELECT * FROM Orders JOIN "customers" USING (id)`,
+			target: summary,
+			want:   []Node{customers, orders},
+		},
+		{
+			name:   "query of a derived table",
+			query:  "SELECT *\n  FROM summary",
+			target: Node{NodeQuery, "SELECT * FROM summary"},
+			want:   []Node{summary},
+		},
+		{
+			name:   "latest view",
+			query:  "SELECT * FROM orders_latest",
+			target: Node{NodeQuery, "SELECT * FROM orders_latest"},
+			want:   []Node{orders},
+		},
+		{
+			name:   "time travel",
+			query:  `SELECT * FROM "orders@20240301T100000"`,
+			target: Node{NodeQuery, `SELECT * FROM "orders@20240301T100000"`},
+			want:   []Node{orders},
+		},
+		{
+			name:   "strings and comments",
+			query:  "SELECT 'customers' FROM orders -- customers\n/* customers */",
+			target: Node{NodeQuery, "SELECT 'customers' FROM orders -- customers /* customers */"},
+			want:   []Node{orders},
+		},
+		{name: "no lake table", query: "SELECT 42 AS answer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := l.RecordQuery(tt.query, at); err != nil {
+				t.Fatal(err)
+			}
+			g := loadTestLineage(t, l)
+			if tt.target == (Node{}) {
+				for _, e := range g.Edges {
+					if e.To.Kind == NodeQuery && e.To.Name == tt.query {
+						t.Errorf("recorded %v", e)
+					}
+				}
+				return
+			}
+			if got := g.Upstream(tt.target); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s reads %v, want %v", tt.target, got, tt.want)
+			}
+			if e, ok := g.Edge(tt.want[0], tt.target); !ok || !e.At.Equal(at) {
+				t.Errorf("edge to %s = %+v, %v", tt.target, e, ok)
+			}
+		})
+	}
+
+	g := loadTestLineage(t, l)
+	want := []Node{{NodeQuery, "SELECT * FROM summary"}}
+	if got := g.Descendants(customers, NodeQuery); !reflect.DeepEqual(got, want) {
+		t.Errorf("queries depending on customers = %v, want %v", got, want)
+	}
+}
+
+func TestRewriteLineage(t *testing.T) {
+	l := newTestLake(t, "a")
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+	var runs []Node
+	for i := 0; i < 4; i++ {
+		at := now.AddDate(0, 0, -5).Add(time.Duration(i) * time.Minute)
+		writeTestRun(t, l, "orders", at, []string{"id"}, [][]string{{strconv.Itoa(i)}})
+		runs = append(runs, Node{NodeRun, NewRunID(at)})
+	}
+	table := Node{NodeTable, "orders"}
+	landed, err := l.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.CompactTable("orders", CompactionSettings{}); err != nil {
+		t.Fatal(err)
+	}
+	compacted, err := l.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := Node{NodeFile, compacted[0].Files[0].Path}
+	g := loadTestLineage(t, l)
+	if got := g.Upstream(table); !reflect.DeepEqual(got, []Node{merged}) {
+		t.Errorf("after compaction orders is made of %v, want %v", got, merged)
+	}
+	if got := g.Upstream(merged); len(got) != len(landed[0].Files) {
+		t.Errorf("compacted file comes from %v, want the %d landed files", got, len(landed[0].Files))
+	}
+	for _, f := range landed[0].Files {
+		if n := (Node{NodeFile, f.Path}); g.Live(n) {
+			t.Errorf("compacted file %s is still live", f.Path)
+		}
+	}
+	if got := g.Ancestors(table, NodeRun); !reflect.DeepEqual(got, runs) {
+		t.Errorf("runs of orders after compaction = %v, want %v", got, runs)
+	}
+
+	settings := RetentionSettings{Tables: map[string]RetentionPolicy{"orders": {KeepLast: 2}}}
+	plans, err := l.PlanRetention(settings, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.ApplyRetention(plans); err != nil {
+		t.Fatal(err)
+	}
+	kept, err := l.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewritten := Node{NodeFile, kept[0].Files[0].Path}
+	g = loadTestLineage(t, l)
+	if got := g.Upstream(rewritten); !reflect.DeepEqual(got, []Node{merged}) {
+		t.Errorf("rewritten file comes from %v, want %v", got, merged)
+	}
+	if _, ok := g.Edge(merged, table); ok || g.Live(merged) {
+		t.Error("the file replaced by retention is still part of orders")
+	}
+	if got := g.Ancestors(table, NodeRun); !reflect.DeepEqual(got, runs) {
+		t.Errorf("runs of orders after retention = %v, want %v", got, runs)
+	}
+	for _, run := range runs[:2] {
+		if g.Live(run) {
+			t.Errorf("expired %s is still live", run)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package lake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const manifestFile = "_manifest.json"
+
+// manifestMu serialises manifest updates between pipelines running in the
+// same process, e.g. two cron triggers firing at once.
+var manifestMu sync.Mutex
+
+// Column is a single column of a file's schema.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// FileEntry describes one data file landed in the lake.
+type FileEntry struct {
+	Table    string    `json:"table"`
+	Path     string    `json:"path"`
+	Pipeline string    `json:"pipeline"`
+	RunID    string    `json:"run_id"`
+	Schema   []Column  `json:"schema"`
+	Rows     int64     `json:"rows"`
+	Bytes    int64     `json:"bytes"`
+	Checksum string    `json:"checksum"`
+	LandedAt time.Time `json:"landed_at"`
+	// CompactedFrom is the number of files merged into this one by
+	// compaction. Compacted files can hold rows from many snapshots.
+	CompactedFrom int `json:"compacted_from,omitempty"`
+}
+
+// Snapshot is the output of one pipeline run for one table. Every row
+// landed by the run carries the snapshot timestamp in its snapshot_ts column.
+type Snapshot struct {
+	Table     string    `json:"table"`
+	RunID     string    `json:"run_id"`
+	Pipeline  string    `json:"pipeline"`
+	Timestamp time.Time `json:"timestamp"`
+	Rows      int64     `json:"rows"`
+	Schema    []Column  `json:"schema"`
+}
+
+// Manifest lists every file that has been committed to a lake, the
+// snapshot history of each table and every schema change between snapshots.
+type Manifest struct {
+	Files     []FileEntry          `json:"files"`
+	Snapshots []Snapshot           `json:"snapshots"`
+	Drift     []DriftEvent         `json:"drift"`
+	Tables    map[string]TableMeta `json:"tables"`
+	// Garbage lists files dropped from the catalog that may still be on
+	// disk. They are never queried or adopted and are deleted on cleanup.
+	Garbage []string `json:"garbage,omitempty"`
+}
+
+// commit is everything a single Writer.Commit adds to the manifest.
+type commit struct {
+	files []FileEntry
+	drift []DriftEvent
+	meta  TableMeta
+}
+
+// LoadManifest reads the lake manifest. A lake without one has no files.
+func (l *Lake) LoadManifest() (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(l.Dir, manifestFile))
+	if os.IsNotExist(err) {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	m.backfillSnapshots()
+	return &m, nil
+}
+
+// backfillSnapshots derives snapshots for files registered before snapshot
+// history was recorded.
+func (m *Manifest) backfillSnapshots() {
+	known := make(map[string]bool, len(m.Snapshots))
+	for _, s := range m.Snapshots {
+		known[s.Table+"\x00"+s.RunID] = true
+	}
+	for _, f := range m.Files {
+		key := f.Table + "\x00" + f.RunID
+		if known[key] || f.CompactedFrom > 0 {
+			continue
+		}
+		known[key] = true
+		m.Snapshots = append(m.Snapshots, snapshotOf(f))
+	}
+}
+
+func snapshotOf(f FileEntry) Snapshot {
+	return Snapshot{
+		Table:     f.Table,
+		RunID:     f.RunID,
+		Pipeline:  f.Pipeline,
+		Timestamp: f.LandedAt,
+		Rows:      f.Rows,
+		Schema:    f.Schema,
+	}
+}
+
+// saveManifest replaces the manifest atomically so readers never see a
+// half-written file.
+func (l *Lake) saveManifest(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(l.Dir, manifestFile), data)
+}
+
+// register appends a commit to the manifest. A commit with a load mode also
+// sets the load semantics of every table it wrote.
+func (l *Lake) register(c commit) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	m, err := l.LoadManifest()
+	if err != nil {
+		return err
+	}
+	m.Files = append(m.Files, c.files...)
+	for _, f := range c.files {
+		m.Snapshots = append(m.Snapshots, snapshotOf(f))
+		if c.meta.Mode != "" {
+			if m.Tables == nil {
+				m.Tables = make(map[string]TableMeta)
+			}
+			m.Tables[f.Table] = c.meta
+		}
+	}
+	m.Drift = append(m.Drift, c.drift...)
+	return l.saveManifest(m)
+}
+
+// replaceFiles swaps the files at the old paths for the new entries and
+// drops the expired snapshots in a single manifest write. It fails without
+// changing anything if another process already removed one of the old files
+// from the catalog.
+func (l *Lake) replaceFiles(oldPaths []string, entries []FileEntry, expired []Snapshot) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	m, err := l.LoadManifest()
+	if err != nil {
+		return err
+	}
+	replaced := make(map[string]bool, len(oldPaths))
+	for _, path := range oldPaths {
+		replaced[path] = true
+	}
+
+	var files []FileEntry
+	for _, f := range m.Files {
+		if replaced[f.Path] {
+			delete(replaced, f.Path)
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(replaced) > 0 {
+		return fmt.Errorf("catalog changed while rewriting files")
+	}
+	m.Files = append(files, entries...)
+	m.Garbage = append(m.Garbage, oldPaths...)
+
+	dropped := make(map[string]bool, len(expired))
+	for _, snap := range expired {
+		dropped[snap.Table+"\x00"+snap.RunID] = true
+	}
+	var snapshots []Snapshot
+	for _, snap := range m.Snapshots {
+		if !dropped[snap.Table+"\x00"+snap.RunID] {
+			snapshots = append(snapshots, snap)
+		}
+	}
+	m.Snapshots = snapshots
+	return l.saveManifest(m)
+}
+
+// CollectGarbage deletes files that have been dropped from the catalog.
+func (l *Lake) CollectGarbage() error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	m, err := l.LoadManifest()
+	if err != nil {
+		return err
+	}
+	if len(m.Garbage) == 0 {
+		return nil
+	}
+	for _, path := range m.Garbage {
+		if err := os.Remove(filepath.Join(l.Dir, path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	m.Garbage = nil
+	return l.saveManifest(m)
+}
+
+// WriteFileAtomic replaces the file at path with data through a temporary
+// file in the same directory, so a crash never leaves it half-written.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,57 @@
+package lake
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// LoadMode decides how successive snapshots of a table combine.
+type LoadMode string
+
+const (
+	// FullRefresh replaces the table: only the latest snapshot is visible.
+	FullRefresh LoadMode = "full_refresh"
+	// Append keeps every row of every snapshot.
+	Append LoadMode = "append"
+	// Upsert keeps the most recent version of each primary key.
+	Upsert LoadMode = "upsert"
+)
+
+// TableMeta holds the load semantics of a table, set by the pipeline that
+// last wrote to it.
+type TableMeta struct {
+	Mode       LoadMode `json:"mode"`
+	PrimaryKey []string `json:"primary_key,omitempty"`
+}
+
+// validateKey checks a staged upsert batch has the primary key columns and
+// that every key is present and unique within the batch.
+func validateKey(db *sql.DB, source string, schema []Column, key []string) error {
+	if len(key) == 0 {
+		return fmt.Errorf("upsert needs a primary key")
+	}
+	quoted := make([]string, len(key))
+	var notNull []string
+	for i, column := range key {
+		if !hasColumn(schema, column) {
+			return fmt.Errorf("primary key column %s is missing", column)
+		}
+		quoted[i] = QuoteIdent(column)
+		notNull = append(notNull, quoted[i]+" IS NULL")
+	}
+
+	var nulls, duplicates int64
+	query := fmt.Sprintf("SELECT count(*) FILTER (WHERE %s), count(*) - count(DISTINCT (%s)) FROM %s",
+		strings.Join(notNull, " OR "), strings.Join(quoted, ", "), source)
+	if err := db.QueryRow(query).Scan(&nulls, &duplicates); err != nil {
+		return err
+	}
+	if nulls > 0 {
+		return fmt.Errorf("%d rows have a NULL primary key", nulls)
+	}
+	if duplicates > 0 {
+		return fmt.Errorf("%d rows repeat a primary key", duplicates)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package lake
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTableQueryLoadModes(t *testing.T) {
+	tests := []struct {
+		mode LoadMode
+		key  []string
+		// want is every id=value row of the table, ordered by id and value
+		want string
+	}{
+		{mode: Append, want: "1=a 1=c 2=b 3=d"},
+		{mode: FullRefresh, want: "1=c 3=d"},
+		{mode: Upsert, key: []string{"id"}, want: "1=c 2=b 3=d"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			l := newTestLake(t, "a")
+			start := time.Now().Add(-time.Hour)
+			for i, rows := range [][][]string{
+				{{"1", "a"}, {"2", "b"}},
+				{{"1", "c"}, {"3", "d"}},
+			} {
+				at := start.Add(time.Duration(i) * time.Minute)
+				w, err := l.NewWriter("test", NewRunID(at))
+				if err != nil {
+					t.Fatal(err)
+				}
+				w.startedAt = at.Truncate(time.Microsecond)
+				w.SetLoadMode(tt.mode, tt.key)
+				if err := w.WriteRows("orders", []string{"id", "value"}, rows); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Commit(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tables, err := l.Tables()
+			if err != nil {
+				t.Fatal(err)
+			}
+			db, err := sql.Open("duckdb", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			query := "SELECT string_agg(id || '=' || value, ' ' ORDER BY id, value) FROM (" + l.TableQuery(tables[0], nil) + ")"
+			var got string
+			if err := db.QueryRowContext(context.Background(), query).Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("%s table holds %q, want %q", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpsertKeyValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     []string
+		rows    [][]string
+		wantErr string
+	}{
+		{name: "valid key", key: []string{"id"}, rows: [][]string{{"1", "a"}, {"2", "b"}}},
+		{name: "compound key", key: []string{"id", "value"}, rows: [][]string{{"1", "a"}, {"1", "b"}}},
+		{name: "empty key", rows: [][]string{{"1", "a"}}, wantErr: "upsert needs a primary key"},
+		{name: "missing column", key: []string{"email"}, rows: [][]string{{"1", "a"}}, wantErr: "primary key column email is missing"},
+		{name: "NULL key", key: []string{"id"}, rows: [][]string{{"1", "a"}, {"", "b"}}, wantErr: "1 rows have a NULL primary key"},
+		{name: "repeated key", key: []string{"id"}, rows: [][]string{{"1", "a"}, {"1", "b"}}, wantErr: "1 rows repeat a primary key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLake(t, "a")
+			w, err := l.NewWriter("test", NewRunID(time.Now()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.SetLoadMode(Upsert, tt.key)
+			if err := w.WriteRows("orders", []string{"id", "value"}, tt.rows); err != nil {
+				t.Fatal(err)
+			}
+			_, err = w.Commit()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Commit() error = %v, want %q", err, tt.wantErr)
+			}
+			assertLakeEmpty(t, l)
+		})
+	}
+}
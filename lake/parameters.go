@@ -0,0 +1,116 @@
+package lake
+
+import (
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// parameterAt matches the name of a parameter after its colon
+	parameterAt = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+	// valueEnd matches the last character of a value or identifier
+	valueEnd    = regexp.MustCompile(`[A-Za-z0-9_\])'"]`)
+	numberValue = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+)
+
+// parameters finds the named parameters of a query, such as :start_date,
+// outside strings, quoted identifiers and comments. Casts such as x::DATE
+// and slices such as list[a:b] are not parameters. It returns the byte
+// offset of every parameter's colon and its name.
+func parameters(query string) (offsets []int, names []string) {
+	for i := 0; i < len(query); i++ {
+		if end, ok := SkipQuoted(query, i); ok {
+			i = end
+			continue
+		}
+		if query[i] != ':' {
+			continue
+		}
+		if strings.HasPrefix(query[i:], "::") {
+			i++
+			continue
+		}
+		// A colon right after a value separates, as in list[a:b] or
+		// {'key':value}
+		if i > 0 && valueEnd.MatchString(query[i-1:i]) {
+			continue
+		}
+		if name := parameterAt.FindString(query[i+1:]); name != "" {
+			offsets = append(offsets, i)
+			names = append(names, name)
+			i += len(name)
+		}
+	}
+	return offsets, names
+}
+
+// QueryParameters lists the named parameters of a query in the order they
+// first appear, each once.
+func QueryParameters(query string) []string {
+	_, names := parameters(query)
+	var distinct []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			distinct = append(distinct, name)
+		}
+	}
+	return distinct
+}
+
+// BindParameters rewrites the named parameters of a query into DuckDB's
+// $name form and returns the values to bind to them, so values never become
+// part of the SQL text. The rewritten query has the same length, so error
+// positions DuckDB reports still point into the original.
+func BindParameters(query string, values map[string]string) (string, []any) {
+	offsets, names := parameters(query)
+	if len(offsets) == 0 {
+		return query, nil
+	}
+	b := []byte(query)
+	var args []any
+	bound := make(map[string]bool)
+	for i, offset := range offsets {
+		b[offset] = '$'
+		if name := names[i]; !bound[name] {
+			bound[name] = true
+			args = append(args, sql.Named(name, ParameterValue(values[name])))
+		}
+	}
+	return string(b), args
+}
+
+// ParameterValue turns the text entered for a parameter into the value bound
+// to it. Numbers, true and false, dates and timestamps are bound as such so
+// they compare with columns of those types; anything else, and any text in
+// single quotes, is bound as text. NULL binds NULL.
+func ParameterValue(text string) any {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && strings.HasPrefix(text, "'") && strings.HasSuffix(text, "'") {
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'")
+	}
+	if strings.EqualFold(text, "null") {
+		return nil
+	}
+	if numberValue.MatchString(text) {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	}
+	if strings.EqualFold(text, "true") || strings.EqualFold(text, "false") {
+		return strings.EqualFold(text, "true")
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05", "2006-01-02T15:04:05", time.RFC3339} {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t
+		}
+	}
+	return text
+}
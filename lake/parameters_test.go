@@ -0,0 +1,129 @@
+package lake
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueryParameters(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "SELECT 1"},
+		{query: "SELECT * FROM t WHERE d >= :start AND d < :end", want: []string{"start", "end"}},
+		{query: "SELECT :a, :b, :a", want: []string{"a", "b"}},
+		{query: "SELECT x::DATE, :when::TIMESTAMP", want: []string{"when"}},
+		{query: "SELECT list[1:2], list[i:j], {'k':v}, (:n)", want: []string{"n"}},
+		{query: "SELECT ':in_string', \":in_ident\" FROM t", want: nil},
+		{query: "SELECT 1 -- :in_comment\n, /* :in_block */ :real", want: []string{"real"}},
+		{query: "SELECT :_private1", want: []string{"_private1"}},
+		{query: "SELECT : spaced, :1", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := QueryParameters(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("QueryParameters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParameterValue(t *testing.T) {
+	tests := []struct {
+		text string
+		want any
+	}{
+		{text: "42", want: int64(42)},
+		{text: " -7 ", want: int64(-7)},
+		{text: "1.5", want: 1.5},
+		{text: "1e3", want: 1000.0},
+		{text: "TRUE", want: true},
+		{text: "false", want: false},
+		{text: "null", want: nil},
+		{text: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{text: "2024-03-01 10:30:00", want: time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{text: "'42'", want: "42"},
+		{text: "'it''s'", want: "it's"},
+		{text: "Robert'); DROP TABLE t;--", want: "Robert'); DROP TABLE t;--"},
+		{text: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := ParameterValue(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParameterValue(%q) = %#v, want %#v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindParameters(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name      string
+		query     string
+		values    map[string]string
+		wantQuery string
+		want      string
+	}{
+		{
+			name:      "no parameters",
+			query:     "SELECT 'a'",
+			wantQuery: "SELECT 'a'",
+			want:      "a",
+		},
+		{
+			name:      "repeated parameter",
+			query:     "SELECT (:n + :n)::VARCHAR",
+			values:    map[string]string{"n": "2"},
+			wantQuery: "SELECT ($n + $n)::VARCHAR",
+			want:      "4",
+		},
+		{
+			name:      "date compares with dates",
+			query:     "SELECT (DATE '2024-03-02' > :d)::VARCHAR",
+			values:    map[string]string{"d": "2024-03-01"},
+			wantQuery: "SELECT (DATE '2024-03-02' > $d)::VARCHAR",
+			want:      "true",
+		},
+		{
+			name:      "value stays out of the SQL",
+			query:     "SELECT :s",
+			values:    map[string]string{"s": "x'; SELECT 'injected"},
+			wantQuery: "SELECT $s",
+			want:      "x'; SELECT 'injected",
+		},
+		{
+			name:      "strings and comments untouched",
+			query:     "SELECT ':s' || :s -- :s",
+			values:    map[string]string{"s": "'b'"},
+			wantQuery: "SELECT ':s' || $s -- :s",
+			want:      ":sb",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := BindParameters(tt.query, tt.values)
+			if query != tt.wantQuery {
+				t.Errorf("BindParameters() query = %q, want %q", query, tt.wantQuery)
+			}
+			if len(query) != len(tt.query) {
+				t.Errorf("bound query is %d bytes, the original %d", len(query), len(tt.query))
+			}
+			var got string
+			if err := db.QueryRow(query, args...).Scan(&got); err != nil {
+				t.Fatalf("running %q: %v", query, err)
+			}
+			if got != tt.want {
+				t.Errorf("%q returned %q, want %q", query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package lake
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	profilesDir      = "_profiles"
+	profileTopValues = 5
+	profileBuckets   = 10
+	// profileVersion changes with how profiles are computed, so profiles
+	// cached by an earlier version are computed again.
+	profileVersion = 1
+)
+
+// Profile summarizes every column of a table as of its latest snapshot.
+type Profile struct {
+	Table       string          `json:"table"`
+	RunID       string          `json:"run_id"`
+	Fingerprint string          `json:"fingerprint"`
+	Version     int             `json:"version"`
+	Rows        int64           `json:"rows"`
+	ProfiledAt  time.Time       `json:"profiled_at"`
+	Columns     []ColumnProfile `json:"columns"`
+}
+
+// ColumnProfile holds the statistics of a single column. Distinct is the
+// exact number of distinct non-NULL values. Histogram is only computed for
+// numeric and temporal columns.
+type ColumnProfile struct {
+	Name      string       `json:"name"`
+	Type      string       `json:"type"`
+	NullPct   float64      `json:"null_pct"`
+	Distinct  int64        `json:"distinct"`
+	Min       string       `json:"min"`
+	Max       string       `json:"max"`
+	TopValues []ValueCount `json:"top_values"`
+	Histogram []Bucket     `json:"histogram,omitempty"`
+}
+
+// ValueCount is a value and how many rows hold it.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Bucket is one equal-width range of a histogram, starting at Start.
+type Bucket struct {
+	Start string `json:"start"`
+	Count int64  `json:"count"`
+}
+
+// Profile returns the column profile of a table. Profiles are computed with
+// DuckDB's SUMMARIZE over the table's query view and cached per snapshot in
+// _profiles, so reopening a profile is instant until the next run lands.
+// A cached profile is also recomputed when compaction or retention changed
+// the table's files. With useCache false the profile is always recomputed.
+func (l *Lake) Profile(ctx context.Context, table string, useCache bool) (Profile, error) {
+	tables, err := l.Tables()
+	if err != nil {
+		return Profile{}, err
+	}
+	var t Table
+	for _, candidate := range tables {
+		if candidate.Name == table {
+			t = candidate
+		}
+	}
+	if t.Name == "" {
+		return Profile{}, fmt.Errorf("unknown table %s", table)
+	}
+
+	runID := t.Latest().RunID
+	fingerprint := l.tableFingerprint(t, false)
+	cachePath := filepath.Join(l.Dir, profilesDir, table, runID+".json")
+	if useCache {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			var cached Profile
+			if json.Unmarshal(data, &cached) == nil && cached.Fingerprint == fingerprint && cached.Version == profileVersion {
+				return cached, nil
+			}
+		}
+	}
+
+	db, err := l.DB(ctx)
+	if err != nil {
+		return Profile{}, err
+	}
+	p, err := profileTable(ctx, db, QuoteIdent(table))
+	if err != nil {
+		return Profile{}, err
+	}
+	p.Table = table
+	p.RunID = runID
+	p.Fingerprint = fingerprint
+	p.Version = profileVersion
+	p.ProfiledAt = time.Now()
+
+	// Snapshots of a table are profiled once, so older cache files are stale
+	if err := os.RemoveAll(filepath.Dir(cachePath)); err != nil {
+		return p, err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return p, err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return p, err
+	}
+	return p, WriteFileAtomic(cachePath, data)
+}
+
+func profileTable(ctx context.Context, db *sql.DB, source string) (Profile, error) {
+	summarizeQuery := fmt.Sprintf(`SELECT column_name, column_type, CAST(min AS VARCHAR), CAST(max AS VARCHAR),
+		CAST(null_percentage AS DOUBLE), count FROM (SUMMARIZE SELECT * FROM %s)`, source)
+	rows, err := db.QueryContext(ctx, summarizeQuery)
+	if err != nil {
+		return Profile{}, err
+	}
+	defer rows.Close()
+
+	var p Profile
+	for rows.Next() {
+		var c ColumnProfile
+		var min, max sql.NullString
+		var nullPct sql.NullFloat64
+		if err := rows.Scan(&c.Name, &c.Type, &min, &max, &nullPct, &p.Rows); err != nil {
+			return Profile{}, err
+		}
+		c.Min, c.Max, c.NullPct = min.String, max.String, nullPct.Float64
+		p.Columns = append(p.Columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return Profile{}, err
+	}
+	if err := countDistinct(ctx, db, source, p.Columns); err != nil {
+		return Profile{}, err
+	}
+
+	for i := range p.Columns {
+		c := &p.Columns[i]
+		if c.TopValues, err = topValues(ctx, db, source, c.Name); err != nil {
+			return Profile{}, fmt.Errorf("profiling %s: %w", c.Name, err)
+		}
+		if c.Histogram, err = histogram(ctx, db, source, *c); err != nil {
+			return Profile{}, fmt.Errorf("profiling %s: %w", c.Name, err)
+		}
+	}
+	return p, nil
+}
+
+// countDistinct counts the distinct values of every column exactly in a
+// single scan. SUMMARIZE only estimates them.
+func countDistinct(ctx context.Context, db *sql.DB, source string, columns []ColumnProfile) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	counts := make([]string, len(columns))
+	dest := make([]any, len(columns))
+	for i := range columns {
+		counts[i] = fmt.Sprintf("count(DISTINCT %s)", QuoteIdent(columns[i].Name))
+		dest[i] = &columns[i].Distinct
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(counts, ", "), source)
+	return db.QueryRowContext(ctx, query).Scan(dest...)
+}
+
+func topValues(ctx context.Context, db *sql.DB, source, column string) ([]ValueCount, error) {
+	query := fmt.Sprintf("SELECT coalesce(CAST(%s AS VARCHAR), 'NULL') AS v, count(*) AS n FROM %s GROUP BY v ORDER BY n DESC, v LIMIT %d",
+		QuoteIdent(column), source, profileTopValues)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []ValueCount
+	for rows.Next() {
+		var v ValueCount
+		if err := rows.Scan(&v.Value, &v.Count); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// histogram counts the values of a numeric or temporal column in equal-width
+// buckets between its min and max. Other columns get no histogram.
+func histogram(ctx context.Context, db *sql.DB, source string, c ColumnProfile) ([]Bucket, error) {
+	var value string
+	temporal := false
+	switch {
+	case isNumericType(c.Type):
+		value = fmt.Sprintf("CAST(%s AS DOUBLE)", QuoteIdent(c.Name))
+	case c.Type == "DATE" || strings.HasPrefix(c.Type, "TIMESTAMP"):
+		value = fmt.Sprintf("epoch(%s)", QuoteIdent(c.Name))
+		temporal = true
+	default:
+		return nil, nil
+	}
+
+	var lo, hi sql.NullFloat64
+	boundsQuery := fmt.Sprintf("SELECT min(%s), max(%s) FROM %s", value, value, source)
+	if err := db.QueryRowContext(ctx, boundsQuery).Scan(&lo, &hi); err != nil {
+		return nil, err
+	}
+	if !lo.Valid || !hi.Valid {
+		return nil, nil
+	}
+	width := (hi.Float64 - lo.Float64) / profileBuckets
+	if width == 0 {
+		width = 1
+	}
+
+	counts := make([]int64, profileBuckets)
+	bucketQuery := fmt.Sprintf("SELECT least(CAST(floor((%s - %v) / %v) AS BIGINT), %d) AS b, count(*) FROM %s WHERE %s IS NOT NULL GROUP BY b",
+		value, lo.Float64, width, profileBuckets-1, source, value)
+	rows, err := db.QueryContext(ctx, bucketQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b, n int64
+		if err := rows.Scan(&b, &n); err != nil {
+			return nil, err
+		}
+		counts[b] += n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	buckets := make([]Bucket, profileBuckets)
+	for i := range buckets {
+		start := lo.Float64 + float64(i)*width
+		if temporal {
+			sec, frac := math.Modf(start)
+			buckets[i].Start = time.Unix(int64(sec), int64(frac*1e9)).UTC().Format("2006-01-02 15:04")
+		} else {
+			buckets[i].Start = fmt.Sprintf("%.6g", start)
+		}
+		buckets[i].Count = counts[i]
+	}
+	return buckets, nil
+}
+
+func isNumericType(t string) bool {
+	switch t {
+	case "TINYINT", "SMALLINT", "INTEGER", "BIGINT", "HUGEINT",
+		"UTINYINT", "USMALLINT", "UINTEGER", "UBIGINT", "UHUGEINT", "FLOAT", "DOUBLE":
+		return true
+	}
+	return strings.HasPrefix(t, "DECIMAL")
+}
@@ -0,0 +1,67 @@
+package lake
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestProfileCountsDistinctValues(t *testing.T) {
+	l := newTestLake(t, "a")
+	var rows [][]string
+	for i := 0; i < 20000; i++ {
+		group := ""
+		if i%4 != 0 {
+			group = strconv.Itoa(i % 3)
+		}
+		rows = append(rows, []string{strconv.Itoa(i), group})
+	}
+	writeTestRun(t, l, "events", time.Now(), []string{"id", "grp"}, rows)
+
+	ctx := context.Background()
+	p, err := l.Profile(ctx, "events", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"id": 20000, "grp": 3}
+	distinct := func(p Profile) map[string]int64 {
+		got := make(map[string]int64)
+		for _, c := range p.Columns {
+			if _, ok := want[c.Name]; ok {
+				got[c.Name] = c.Distinct
+			}
+		}
+		return got
+	}
+	if got := distinct(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("distinct values = %v, want %v", got, want)
+	}
+
+	// A profile cached before distinct values were counted exactly is
+	// computed again
+	cachePath := filepath.Join(l.Dir, profilesDir, "events", p.RunID+".json")
+	stale := p
+	stale.Version = 0
+	stale.Columns = append([]ColumnProfile(nil), p.Columns...)
+	for i := range stale.Columns {
+		stale.Columns[i].Distinct = 1
+	}
+	data, err := json.Marshal(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = l.Profile(ctx, "events", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := distinct(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("a stale cached profile was used: %v", got)
+	}
+}
@@ -0,0 +1,233 @@
+package lake
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// queriesDir holds the saved queries of a lake, one .sql file per query.
+const queriesDir = "_queries"
+
+// SavedQuery is a named query kept in a lake's query library. It is stored
+// as a .sql file whose leading comments hold its name, description and tags,
+// so the files can be shared and edited outside pipeterm:
+//
+//	-- name: Open opportunities
+//	-- description: Opportunities not yet closed, by owner
+//	-- tags: sales, weekly
+//	SELECT ...
+type SavedQuery struct {
+	Name        string
+	Description string
+	Tags        []string
+	SQL         string
+	// UpdatedAt is when the file was last written.
+	UpdatedAt time.Time
+	// file is the path the query was read from, which for files written
+	// by hand need not match FileName.
+	file string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// FileName is the name of the file the query is stored in.
+func (q SavedQuery) FileName() string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(strings.ToLower(q.Name), "_"), "_")
+	if name == "" {
+		name = "query"
+	}
+	return name + ".sql"
+}
+
+// Format renders the query as the contents of its .sql file.
+func (q SavedQuery) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- name: %s\n", q.Name)
+	if q.Description != "" {
+		fmt.Fprintf(&b, "-- description: %s\n", q.Description)
+	}
+	if len(q.Tags) > 0 {
+		fmt.Fprintf(&b, "-- tags: %s\n", strings.Join(q.Tags, ", "))
+	}
+	b.WriteString(strings.TrimSpace(q.SQL) + "\n")
+	return b.String()
+}
+
+// ParseSavedQuery reads a .sql file's contents. Files without a name comment
+// are named after the file.
+func ParseSavedQuery(fileName, contents string) SavedQuery {
+	q := SavedQuery{Name: strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))}
+	var sql []string
+	header := true
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if header {
+			if key, value, ok := headerComment(line); ok {
+				switch key {
+				case "name":
+					q.Name = value
+				case "description":
+					q.Description = value
+				case "tags":
+					q.Tags = ParseTags(value)
+				}
+				continue
+			}
+			header = false
+		}
+		sql = append(sql, line)
+	}
+	q.SQL = strings.TrimSpace(strings.Join(sql, "\n"))
+	return q
+}
+
+// headerComment parses a "-- key: value" line.
+func headerComment(line string) (key, value string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(line), "--")
+	if !found {
+		return "", "", false
+	}
+	key, value, found = strings.Cut(rest, ":")
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !found || (key != "name" && key != "description" && key != "tags") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// ParseTags splits a comma separated list of tags.
+func ParseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// SavedQueries lists the lake's saved queries by name.
+func (l *Lake) SavedQueries() ([]SavedQuery, error) {
+	return readQueries(filepath.Join(l.Dir, queriesDir))
+}
+
+// readQueries reads the .sql files of a directory.
+func readQueries(dir string) ([]SavedQuery, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	var queries []SavedQuery
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		q := ParseSavedQuery(path, string(data))
+		q.file = path
+		if info, err := os.Stat(path); err == nil {
+			q.UpdatedAt = info.ModTime()
+		}
+		queries = append(queries, q)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		return strings.ToLower(queries[i].Name) < strings.ToLower(queries[j].Name)
+	})
+	return queries, nil
+}
+
+// SaveQuery adds a query to the library, replacing a saved query of the same
+// name. previous is the name the query was saved under before, if it is
+// being renamed.
+func (l *Lake) SaveQuery(q SavedQuery, previous string) error {
+	q.Name = strings.TrimSpace(q.Name)
+	if q.Name == "" {
+		return fmt.Errorf("a saved query needs a name")
+	}
+	dir := filepath.Join(l.Dir, queriesDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	existing, err := l.SavedQueries()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, q.FileName())
+	var replaced []string
+	for _, other := range existing {
+		switch {
+		case other.Name == q.Name || (previous != "" && other.Name == previous):
+			if other.file != path {
+				replaced = append(replaced, other.file)
+			}
+		case other.file == path:
+			return fmt.Errorf("the name %q is too close to the saved query %q", q.Name, other.Name)
+		}
+	}
+	if err := os.WriteFile(path, []byte(q.Format()), 0644); err != nil {
+		return err
+	}
+	for _, file := range replaced {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteQuery removes a query from the library.
+func (l *Lake) DeleteQuery(name string) error {
+	queries, err := l.SavedQueries()
+	if err != nil {
+		return err
+	}
+	for _, q := range queries {
+		if q.Name == name {
+			if err := os.Remove(q.file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExportQueries writes the lake's saved queries as .sql files to dir, so
+// they can be shared, and returns how many were written.
+func (l *Lake) ExportQueries(dir string) (int, error) {
+	queries, err := l.SavedQueries()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	for i, q := range queries {
+		if err := os.WriteFile(filepath.Join(dir, q.FileName()), []byte(q.Format()), 0644); err != nil {
+			return i, err
+		}
+	}
+	return len(queries), nil
+}
+
+// ImportQueries adds the .sql files of dir to the lake's library, replacing
+// saved queries of the same name, and returns how many were imported.
+func (l *Lake) ImportQueries(dir string) (int, error) {
+	queries, err := readQueries(dir)
+	if err != nil {
+		return 0, err
+	}
+	for i, q := range queries {
+		if err := l.SaveQuery(q, ""); err != nil {
+			return i, fmt.Errorf("importing %s: %w", filepath.Base(q.file), err)
+		}
+	}
+	return len(queries), nil
+}
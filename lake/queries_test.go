@@ -0,0 +1,178 @@
+package lake
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// queryNames lists the names of the lake's saved queries and their files
+func queryNames(t *testing.T, l *Lake) map[string]string {
+	t.Helper()
+	queries, err := l.SavedQueries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]string)
+	for _, q := range queries {
+		names[q.Name] = filepath.Base(q.file)
+	}
+	return names
+}
+
+func TestSaveQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		query    string
+		previous string
+		want     map[string]string
+		wantErr  string
+	}{
+		{
+			name:  "new query",
+			query: "Open opportunities",
+			want:  map[string]string{"Open opportunities": "open_opportunities.sql"},
+		},
+		{
+			name:     "replace the same name",
+			existing: []string{"Open opportunities"},
+			query:    "Open opportunities",
+			want:     map[string]string{"Open opportunities": "open_opportunities.sql"},
+		},
+		{
+			name:     "rename",
+			existing: []string{"Open opportunities", "Closed deals"},
+			query:    "Pipeline by owner",
+			previous: "Open opportunities",
+			want: map[string]string{
+				"Pipeline by owner": "pipeline_by_owner.sql",
+				"Closed deals":      "closed_deals.sql",
+			},
+		},
+		{
+			name:     "rename keeping the file name",
+			existing: []string{"Open opportunities"},
+			query:    "open opportunities!",
+			previous: "Open opportunities",
+			want:     map[string]string{"open opportunities!": "open_opportunities.sql"},
+		},
+		{
+			name:     "name too close to another",
+			existing: []string{"Open opportunities"},
+			query:    "open-opportunities",
+			wantErr:  `too close to the saved query "Open opportunities"`,
+		},
+		{
+			name:     "rename onto a close name",
+			existing: []string{"Open opportunities", "Closed deals"},
+			query:    "OPEN opportunities",
+			previous: "Closed deals",
+			wantErr:  "too close",
+		},
+		{name: "no name", query: "  ", wantErr: "needs a name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLake(t, "a")
+			for _, name := range tt.existing {
+				if err := l.SaveQuery(SavedQuery{Name: name, SQL: "SELECT 1"}, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := queryNames(t, l)
+			err := l.SaveQuery(SavedQuery{Name: tt.query, SQL: "SELECT 2"}, tt.previous)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				if got := queryNames(t, l); !reflect.DeepEqual(got, before) {
+					t.Errorf("a rejected save changed the library from %v to %v", before, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := queryNames(t, l); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("library = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSavedQueryRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		query SavedQuery
+	}{
+		{"sql only", SavedQuery{Name: "Count", SQL: "SELECT count(*) FROM orders"}},
+		{"every field", SavedQuery{
+			Name:        "Open opportunities",
+			Description: "Opportunities not yet closed, by owner",
+			Tags:        []string{"sales", "weekly"},
+			SQL:         "-- not a header: the query starts above\nSELECT *\nFROM opportunities\nWHERE NOT closed",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseSavedQuery("elsewhere.sql", tt.query.Format())
+			if !reflect.DeepEqual(got, tt.query) {
+				t.Errorf("ParseSavedQuery(Format()) = %+v, want %+v", got, tt.query)
+			}
+		})
+	}
+
+	if got := ParseSavedQuery("dir/weekly_report.sql", "SELECT 1\n"); got.Name != "weekly_report" || got.SQL != "SELECT 1" {
+		t.Errorf("file without a header parsed as %+v", got)
+	}
+}
+
+func TestExportImportQueries(t *testing.T) {
+	l := newTestLake(t, "a")
+	saved := []SavedQuery{
+		{Name: "Closed deals", SQL: "SELECT * FROM deals WHERE closed"},
+		{Name: "Open opportunities", Description: "Not yet closed", Tags: []string{"sales"}, SQL: "SELECT * FROM opportunities"},
+	}
+	for _, q := range saved {
+		if err := l.SaveQuery(q, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(t.TempDir(), "shared")
+	if n, err := l.ExportQueries(dir); err != nil || n != len(saved) {
+		t.Fatalf("exported %d queries, %v", n, err)
+	}
+
+	other, err := Create("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An imported query replaces the saved query of the same name
+	if err := other.SaveQuery(SavedQuery{Name: "Closed deals", SQL: "SELECT 1"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.sql"), []byte("SELECT 'by hand'"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := other.ImportQueries(dir); err != nil || n != len(saved)+1 {
+		t.Fatalf("imported %d queries, %v", n, err)
+	}
+	imported, err := other.SavedQueries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sorted by name, ignoring case
+	want := []SavedQuery{saved[0], {Name: "notes", SQL: "SELECT 'by hand'"}, saved[1]}
+	if len(imported) != len(want) {
+		t.Fatalf("imported %+v, want %+v", imported, want)
+	}
+	for i, q := range imported {
+		q.UpdatedAt, q.file = want[i].UpdatedAt, ""
+		if !reflect.DeepEqual(q, want[i]) {
+			t.Errorf("imported %+v, want %+v", q, want[i])
+		}
+	}
+}
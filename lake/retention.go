@@ -0,0 +1,313 @@
+package lake
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy decides which snapshots of a table are kept. A snapshot
+// is kept when any rule keeps it, and the latest snapshot is always kept.
+// A policy with no rules keeps everything.
+type RetentionPolicy struct {
+	// KeepLast keeps the most recent N snapshots.
+	KeepLast int `json:"keep_last,omitempty"`
+	// KeepDays keeps every snapshot from the last N days.
+	KeepDays int `json:"keep_days,omitempty"`
+	// MonthlyAfterDays keeps every snapshot from the last N days and, before
+	// that, the newest snapshot of each month.
+	MonthlyAfterDays int `json:"monthly_after_days,omitempty"`
+}
+
+// IsZero reports whether the policy keeps everything.
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepLast <= 0 && p.KeepDays <= 0 && p.MonthlyAfterDays <= 0
+}
+
+// String formats the policy in the form accepted by ParseRetentionPolicy.
+func (p RetentionPolicy) String() string {
+	var rules []string
+	if p.KeepLast > 0 {
+		rules = append(rules, fmt.Sprintf("last=%d", p.KeepLast))
+	}
+	if p.KeepDays > 0 {
+		rules = append(rules, fmt.Sprintf("days=%d", p.KeepDays))
+	}
+	if p.MonthlyAfterDays > 0 {
+		rules = append(rules, fmt.Sprintf("monthly=%d", p.MonthlyAfterDays))
+	}
+	return strings.Join(rules, " ")
+}
+
+// ParseRetentionPolicy parses rules such as "last=10 days=30 monthly=90".
+// An empty string is the policy that keeps everything.
+func ParseRetentionPolicy(s string) (RetentionPolicy, error) {
+	var p RetentionPolicy
+	for _, rule := range strings.Fields(s) {
+		key, value, ok := strings.Cut(rule, "=")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil || n < 0 {
+			return p, fmt.Errorf("invalid retention rule %q", rule)
+		}
+		switch key {
+		case "last":
+			p.KeepLast = n
+		case "days":
+			p.KeepDays = n
+		case "monthly":
+			p.MonthlyAfterDays = n
+		default:
+			return p, fmt.Errorf("unknown retention rule %q", key)
+		}
+	}
+	return p, nil
+}
+
+// RetentionSettings holds the lake-wide policy, per-table overrides and the
+// schedule of the background retention job.
+type RetentionSettings struct {
+	Schedule string                     `json:"schedule,omitempty"`
+	Default  RetentionPolicy            `json:"default"`
+	Tables   map[string]RetentionPolicy `json:"tables,omitempty"`
+}
+
+// PolicyFor returns the policy that applies to a table.
+func (s RetentionSettings) PolicyFor(table string) RetentionPolicy {
+	if p, ok := s.Tables[table]; ok {
+		return p
+	}
+	return s.Default
+}
+
+// RetentionPlan lists what retention would remove from one table.
+type RetentionPlan struct {
+	Table   string
+	Policy  RetentionPolicy
+	Expired []Snapshot
+	// Files are deleted outright. Compacted files holding expired rows are
+	// rewritten without them instead.
+	Files     []FileEntry
+	Rewritten []FileEntry
+}
+
+// Rows returns the number of rows in the expired snapshots.
+func (p RetentionPlan) Rows() int64 {
+	var n int64
+	for _, snap := range p.Expired {
+		n += snap.Rows
+	}
+	return n
+}
+
+// Bytes returns the size of the files that would be deleted.
+func (p RetentionPlan) Bytes() int64 {
+	var n int64
+	for _, f := range p.Files {
+		n += f.Bytes
+	}
+	return n
+}
+
+// PlanRetention works out which snapshots of each table have expired as of
+// now without deleting anything. Tables with nothing to remove are left out.
+func (l *Lake) PlanRetention(settings RetentionSettings, now time.Time) ([]RetentionPlan, error) {
+	tables, err := l.Tables()
+	if err != nil {
+		return nil, err
+	}
+	var plans []RetentionPlan
+	for _, t := range tables {
+		policy := settings.PolicyFor(t.Name)
+		expired := expiredSnapshots(t.Snapshots, policy, now)
+		if len(expired) == 0 {
+			continue
+		}
+		plan := RetentionPlan{Table: t.Name, Policy: policy, Expired: expired}
+		runs := make(map[string]bool, len(expired))
+		for _, snap := range expired {
+			runs[snap.RunID] = true
+		}
+		for _, f := range t.Files {
+			if f.CompactedFrom > 0 {
+				plan.Rewritten = append(plan.Rewritten, f)
+			} else if runs[f.RunID] {
+				plan.Files = append(plan.Files, f)
+			}
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// expiredSnapshots returns the snapshots, ordered oldest first, that no rule
+// of the policy keeps.
+func expiredSnapshots(snapshots []Snapshot, p RetentionPolicy, now time.Time) []Snapshot {
+	if p.IsZero() || len(snapshots) == 0 {
+		return nil
+	}
+
+	keep := make([]bool, len(snapshots))
+	keep[len(snapshots)-1] = true
+	for i := len(snapshots) - p.KeepLast; i < len(snapshots); i++ {
+		if i >= 0 {
+			keep[i] = true
+		}
+	}
+	if p.KeepDays > 0 {
+		cutoff := now.AddDate(0, 0, -p.KeepDays)
+		for i, snap := range snapshots {
+			if snap.Timestamp.After(cutoff) {
+				keep[i] = true
+			}
+		}
+	}
+	if p.MonthlyAfterDays > 0 {
+		cutoff := now.AddDate(0, 0, -p.MonthlyAfterDays)
+		newestOfMonth := make(map[string]int)
+		for i, snap := range snapshots {
+			if snap.Timestamp.After(cutoff) {
+				keep[i] = true
+				continue
+			}
+			newestOfMonth[snap.Timestamp.Format("2006-01")] = i
+		}
+		for _, i := range newestOfMonth {
+			keep[i] = true
+		}
+	}
+
+	var expired []Snapshot
+	for i, snap := range snapshots {
+		if !keep[i] {
+			expired = append(expired, snap)
+		}
+	}
+	return expired
+}
+
+// ApplyRetention removes the expired snapshots of every plan. Files holding
+// only expired snapshots are deleted and compacted files are rewritten
+// without the expired rows. Each table's catalog changes in one manifest
+// update and every deletion is written to the maintenance log. Removing
+// snapshots of an upsert table can drop keys whose only versions expired.
+func (l *Lake) ApplyRetention(plans []RetentionPlan) error {
+	for _, plan := range plans {
+		if err := l.applyRetentionPlan(plan); err != nil {
+			return fmt.Errorf("retention of %s: %w", plan.Table, err)
+		}
+	}
+	return nil
+}
+
+func (l *Lake) applyRetentionPlan(plan RetentionPlan) error {
+	var oldPaths []string
+	for _, f := range plan.Files {
+		oldPaths = append(oldPaths, f.Path)
+	}
+
+	var landed []stagedFile
+	var entries []FileEntry
+	var lineage []Edge
+	if len(plan.Rewritten) > 0 {
+		rewriteID := NewRunID(time.Now())
+		staging := filepath.Join(l.Dir, stagingDir, "retention-"+rewriteID)
+		if err := os.MkdirAll(staging, 0755); err != nil {
+			return err
+		}
+		defer os.RemoveAll(staging)
+
+		db, err := sql.Open("duckdb", "")
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		var staged []stagedFile
+		for i, f := range plan.Rewritten {
+			out := filepath.Join(staging, filepath.Base(f.Path))
+			entry, removed, err := l.withoutSnapshots(db, f, plan.Expired, out)
+			if err != nil {
+				return err
+			}
+			if !removed {
+				continue
+			}
+			oldPaths = append(oldPaths, f.Path)
+			if entry.Rows == 0 {
+				continue
+			}
+			entry.Path = filepath.Join(filepath.Dir(f.Path), fmt.Sprintf("%s_compacted_%s_%d.parquet", f.Table, rewriteID, i))
+			staged = append(staged, stagedFile{entry: entry, src: out, dest: filepath.Join(l.Dir, entry.Path)})
+			lineage = append(lineage, rewriteLineage([]FileEntry{f}, entry)...)
+		}
+		for _, s := range staged {
+			if err := os.Rename(s.src, s.dest); err != nil {
+				removeLanded(landed)
+				return err
+			}
+			landed = append(landed, s)
+			entries = append(entries, s.entry)
+		}
+	}
+
+	if err := l.replaceFiles(oldPaths, entries, plan.Expired); err != nil {
+		removeLanded(landed)
+		return err
+	}
+	l.recordLineage(lineage, oldPaths)
+	for _, snap := range plan.Expired {
+		l.LogMaintenance("retention (%s) deleted snapshot %s/%s: %d rows taken %s",
+			plan.Policy, plan.Table, snap.RunID, snap.Rows, snap.Timestamp.Format(time.RFC3339))
+	}
+	for _, path := range oldPaths {
+		l.LogMaintenance("retention deleted file %s", path)
+	}
+	return l.CollectGarbage()
+}
+
+// withoutSnapshots writes the rows of a compacted file that do not belong
+// to an expired snapshot to out. It reports false when the file held no
+// expired rows and so needs no rewrite.
+func (l *Lake) withoutSnapshots(db *sql.DB, f FileEntry, expired []Snapshot, out string) (FileEntry, bool, error) {
+	stamps := make([]string, len(expired))
+	for i, snap := range expired {
+		stamps[i] = timestampLiteral(snap.Timestamp)
+	}
+	reader := fmt.Sprintf("read_parquet(%s, hive_partitioning = false)", QuoteLiteral(l.Path(f)))
+	filter := fmt.Sprintf("%s IN (%s)", SnapshotColumn, strings.Join(stamps, ", "))
+
+	var rows, snapshots int64
+	countQuery := fmt.Sprintf("SELECT count(*), count(DISTINCT %s) FROM %s WHERE %s", SnapshotColumn, reader, filter)
+	if err := db.QueryRow(countQuery).Scan(&rows, &snapshots); err != nil {
+		return FileEntry{}, false, err
+	}
+	if rows == 0 {
+		return FileEntry{}, false, nil
+	}
+	if rows == f.Rows {
+		return FileEntry{Rows: 0}, true, nil
+	}
+
+	copyQuery := fmt.Sprintf("COPY (SELECT * FROM %s WHERE NOT (%s)) TO %s (FORMAT PARQUET, COMPRESSION ZSTD);",
+		reader, filter, QuoteLiteral(out))
+	if _, err := db.Exec(copyQuery); err != nil {
+		return FileEntry{}, false, err
+	}
+	entry, err := inspectFile(db, out)
+	if err != nil {
+		return FileEntry{}, false, err
+	}
+	if entry.Rows != f.Rows-rows {
+		return FileEntry{}, false, fmt.Errorf("row count mismatch: kept %d, wrote %d", f.Rows-rows, entry.Rows)
+	}
+	entry.Table = f.Table
+	entry.Pipeline = f.Pipeline
+	entry.RunID = f.RunID
+	entry.LandedAt = f.LandedAt
+	entry.CompactedFrom = max(f.CompactedFrom-int(snapshots), 1)
+	return entry, true, nil
+}
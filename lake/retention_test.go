@@ -0,0 +1,204 @@
+package lake
+
+import (
+	"database/sql"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpiredSnapshots(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	// One snapshot every ten days, oldest first: run0 on 2024-02-06 through
+	// run12 on 2024-06-05
+	var snapshots []Snapshot
+	for i := 12; i >= 0; i-- {
+		snapshots = append(snapshots, Snapshot{
+			RunID:     "run" + strconv.Itoa(12-i),
+			Timestamp: now.AddDate(0, 0, -10*(i+1)),
+		})
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		// want lists the run IDs that expire
+		want []string
+	}{
+		{name: "no rules", policy: RetentionPolicy{}},
+		{
+			name:   "last",
+			policy: RetentionPolicy{KeepLast: 10},
+			want:   []string{"run0", "run1", "run2"},
+		},
+		{
+			name:   "days",
+			policy: RetentionPolicy{KeepDays: 95},
+			want:   []string{"run0", "run1", "run2", "run3"},
+		},
+		{
+			// Before the cutoff only the newest snapshot of February (run2)
+			// and of March (run5) stay
+			name:   "monthly",
+			policy: RetentionPolicy{MonthlyAfterDays: 75},
+			want:   []string{"run0", "run1", "run3", "run4"},
+		},
+		{
+			name:   "any rule keeps a snapshot",
+			policy: RetentionPolicy{KeepLast: 10, MonthlyAfterDays: 75},
+			want:   []string{"run0", "run1"},
+		},
+		{
+			name:   "latest is always kept",
+			policy: RetentionPolicy{KeepDays: 1},
+			want:   []string{"run0", "run1", "run2", "run3", "run4", "run5", "run6", "run7", "run8", "run9", "run10", "run11"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, snap := range expiredSnapshots(snapshots, tt.policy, now) {
+				got = append(got, snap.RunID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expiredSnapshots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetentionPolicy(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    RetentionPolicy
+		wantErr bool
+	}{
+		{text: ""},
+		{text: "last=10 days=30 monthly=90", want: RetentionPolicy{KeepLast: 10, KeepDays: 30, MonthlyAfterDays: 90}},
+		{text: "days=7", want: RetentionPolicy{KeepDays: 7}},
+		{text: "last=-1", wantErr: true},
+		{text: "last", wantErr: true},
+		{text: "weeks=2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseRetentionPolicy(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRetentionPolicy(%q) error = %v, want error %t", tt.text, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseRetentionPolicy(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+			if !tt.wantErr && got.String() != tt.text {
+				t.Errorf("%+v formats as %q, want %q", got, got.String(), tt.text)
+			}
+		})
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		compact bool
+		policy  RetentionPolicy
+		// want lists the ids left in the table; run i landed id i
+		want []string
+	}{
+		{name: "deletes expired files", policy: RetentionPolicy{KeepLast: 2}, want: []string{"2", "3"}},
+		{name: "keeps the latest snapshot", policy: RetentionPolicy{KeepDays: 1}, want: []string{"3"}},
+		{name: "rewrites compacted files", compact: true, policy: RetentionPolicy{KeepLast: 2}, want: []string{"2", "3"}},
+		{name: "rewrites compacted files down to the latest snapshot", compact: true, policy: RetentionPolicy{KeepDays: 1}, want: []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLake(t, "a")
+			now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+			for i := 0; i < 4; i++ {
+				// Every run lands in the same partition, a few days ago
+				at := now.AddDate(0, 0, -5).Add(time.Duration(i) * time.Minute)
+				writeTestRun(t, l, "orders", at, []string{"id"}, [][]string{{strconv.Itoa(i)}})
+			}
+			if tt.compact {
+				if _, err := l.CompactTable("orders", CompactionSettings{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before, err := l.Tables()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			settings := RetentionSettings{Tables: map[string]RetentionPolicy{"orders": tt.policy}}
+			plans, err := l.PlanRetention(settings, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plans) != 1 || int(plans[0].Rows()) != 4-len(tt.want) {
+				t.Fatalf("planned %+v, want %d expired rows of orders", plans, 4-len(tt.want))
+			}
+			if err := l.ApplyRetention(plans); err != nil {
+				t.Fatal(err)
+			}
+
+			after, err := l.Tables()
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := after[0]
+			if latest := before[0].Latest().RunID; table.Latest().RunID != latest {
+				t.Errorf("latest snapshot is %s, want %s", table.Latest().RunID, latest)
+			}
+			if len(table.Snapshots) != len(tt.want) {
+				t.Errorf("%d snapshots left, want %d", len(table.Snapshots), len(tt.want))
+			}
+			if got := tableIDs(t, l, table); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ids left = %v, want %v", got, tt.want)
+			}
+
+			kept := make(map[string]bool)
+			for _, f := range table.Files {
+				kept[f.Path] = true
+				if _, err := os.Stat(l.Path(f)); err != nil {
+					t.Errorf("catalog file %s: %v", f.Path, err)
+				}
+				if tt.compact && f.CompactedFrom != len(tt.want) {
+					t.Errorf("%s is compacted from %d snapshots, want %d", f.Path, f.CompactedFrom, len(tt.want))
+				}
+			}
+			for _, f := range before[0].Files {
+				if _, err := os.Stat(l.Path(f)); !kept[f.Path] && !os.IsNotExist(err) {
+					t.Errorf("dropped file %s still on disk: %v", f.Path, err)
+				}
+			}
+		})
+	}
+}
+
+// tableIDs returns the id column of every row of the table, in order
+func tableIDs(t *testing.T, l *Lake, table Table) []string {
+	t.Helper()
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT id::VARCHAR FROM (" + l.TableQuery(table, nil) + ") ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
@@ -0,0 +1,92 @@
+package lake
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	settingsFile    = "_settings.json"
+	maintenanceFile = "_maintenance.log"
+)
+
+// DefaultQueryTimeout bounds editor queries on lakes without a configured
+// query timeout.
+const DefaultQueryTimeout = 5 * time.Minute
+
+// Settings holds the maintenance and query configuration of a lake.
+type Settings struct {
+	Compaction CompactionSettings `json:"compaction"`
+	Retention  RetentionSettings  `json:"retention"`
+	// Materialized lists the tables the lake database stores as tables
+	// rather than views over the lake files.
+	Materialized []string `json:"materialized,omitempty"`
+	// QueryTimeout is how long a query may run before it is interrupted,
+	// e.g. "30s" or "10m". "0" disables the timeout.
+	QueryTimeout string `json:"query_timeout,omitempty"`
+	// Parameters holds the value last entered for each named parameter,
+	// such as :start_date, of the lake's queries.
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// Timeout returns the query timeout of the lake, zero meaning none.
+func (s Settings) Timeout() (time.Duration, error) {
+	if s.QueryTimeout == "" {
+		return DefaultQueryTimeout, nil
+	}
+	return ParseTimeout(s.QueryTimeout)
+}
+
+// ParseTimeout parses a query timeout such as "90s" or "5m". "0" means no
+// timeout.
+func ParseTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q, expected e.g. 30s, 5m or 0 for none", s)
+	}
+	return d, nil
+}
+
+// LoadSettings reads the lake settings. A lake without a settings file uses
+// the defaults.
+func (l *Lake) LoadSettings() (Settings, error) {
+	var s Settings
+	data, err := os.ReadFile(filepath.Join(l.Dir, settingsFile))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("reading %s: %w", settingsFile, err)
+	}
+	return s, nil
+}
+
+// SaveSettings replaces the lake settings.
+func (l *Lake) SaveSettings(s Settings) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(l.Dir, settingsFile), data)
+}
+
+// LogMaintenance appends a timestamped line to the lake's maintenance log.
+func (l *Lake) LogMaintenance(format string, args ...any) error {
+	f, err := os.OpenFile(filepath.Join(l.Dir, maintenanceFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line := fmt.Sprintf("%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+	if _, err := f.WriteString(line); err != nil {
+		return err
+	}
+	return f.Sync()
+}
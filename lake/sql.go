@@ -0,0 +1,131 @@
+package lake
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+// QuoteLiteral quotes s as a SQL string literal.
+func QuoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// QuoteIdent quotes s as a SQL identifier so table names with dots, dashes
+// or spaces can still be queried.
+func QuoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// SkipQuoted reports whether a string literal, quoted identifier or comment
+// starts at byte i of query and if so returns the index of its last byte. One
+// left open runs to the end of the query.
+func SkipQuoted(query string, i int) (int, bool) {
+	// to returns the index of the last byte of a section ending in end
+	to := func(from int, end string) int {
+		if j := strings.Index(query[from:], end); j >= 0 {
+			return from + j + len(end) - 1
+		}
+		return len(query) - 1
+	}
+	switch {
+	case query[i] == '\'' || query[i] == '"':
+		return to(i+1, query[i:i+1]), true
+	case strings.HasPrefix(query[i:], "--"):
+		return to(i, "\n"), true
+	case strings.HasPrefix(query[i:], "/*"):
+		return to(i+2, "*/"), true
+	case strings.HasPrefix(query[i:], "$$"):
+		return to(i+2, "$$"), true
+	}
+	return i, false
+}
+
+// timestampLiteral formats t as a DuckDB TIMESTAMP literal.
+func timestampLiteral(t time.Time) string {
+	return "TIMESTAMP " + QuoteLiteral(t.Format("2006-01-02 15:04:05.000000"))
+}
+
+// Execer runs statements. It is satisfied by *sql.DB, *sql.Conn and
+// *sql.Tx, so temporary views can be created on the connection that will
+// run the query.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// readerFor returns the DuckDB table function that reads path.
+func readerFor(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".parquet":
+		return fmt.Sprintf("read_parquet(%s)", QuoteLiteral(path)), nil
+	case ".csv":
+		return fmt.Sprintf("read_csv(%s)", QuoteLiteral(path)), nil
+	default:
+		return "", fmt.Errorf("unsupported file type: %s", filepath.Base(path))
+	}
+}
+
+func countRows(db *sql.DB, source string) (int64, error) {
+	var n int64
+	err := db.QueryRow("SELECT count(*) FROM " + source).Scan(&n)
+	return n, err
+}
+
+func describe(db *sql.DB, source string) ([]Column, error) {
+	rows, err := db.Query("SELECT column_name, column_type FROM (DESCRIBE SELECT * FROM " + source + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var c Column
+		if err := rows.Scan(&c.Name, &c.Type); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// inspectFile reads the row count, schema, size and checksum of a Parquet
+// file.
+func inspectFile(db *sql.DB, path string) (FileEntry, error) {
+	reader := fmt.Sprintf("read_parquet(%s, hive_partitioning = false)", QuoteLiteral(path))
+	rows, err := countRows(db, reader)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	schema, err := describe(db, reader)
+	if err != nil {
+		return FileEntry{}, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	return FileEntry{
+		Schema:   schema,
+		Rows:     rows,
+		Bytes:    size,
+		Checksum: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
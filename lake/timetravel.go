@@ -0,0 +1,170 @@
+package lake
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// asOfPattern matches `<table> AS OF '<run id or timestamp>'` where the
+// table is either a bare or a double-quoted identifier.
+var asOfPattern = regexp.MustCompile(`(?i)("(?:[^"]|"")+"|[A-Za-z_][A-Za-z0-9_.]*)\s+AS\s+OF\s+'((?:[^']|'')*)'`)
+
+// timestampLayouts are the formats accepted for AS OF timestamps.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// SnapshotAsOf resolves ref against the table's snapshot history. ref is
+// either a run ID or a timestamp, in which case the last snapshot taken at or
+// before it is returned.
+func (t Table) SnapshotAsOf(ref string) (Snapshot, error) {
+	for _, snap := range t.Snapshots {
+		if snap.RunID == ref {
+			return snap, nil
+		}
+	}
+
+	var at time.Time
+	var err error
+	for _, layout := range timestampLayouts {
+		at, err = time.ParseInLocation(layout, ref, time.Local)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("%q is neither a run ID nor a timestamp of table %s", ref, t.Name)
+	}
+	if !strings.Contains(ref, ":") {
+		// A bare date means "as of the end of that day"
+		at = at.Add(24*time.Hour - time.Microsecond)
+	}
+
+	var found *Snapshot
+	for i := range t.Snapshots {
+		if t.Snapshots[i].Timestamp.After(at) {
+			break
+		}
+		found = &t.Snapshots[i]
+	}
+	if found == nil {
+		return Snapshot{}, fmt.Errorf("table %s has no snapshot as of %s", t.Name, ref)
+	}
+	return *found, nil
+}
+
+// AsOfViewName names the view holding a table as it was after a snapshot.
+func AsOfViewName(table string, snap Snapshot) string {
+	return table + "@" + snap.RunID
+}
+
+// ResolveTimeTravel rewrites every `<table> AS OF '<ref>'` in query into a
+// view of the table as it looked after the matching snapshot, creating those
+// temporary views on db. Queries without AS OF are returned unchanged.
+func (l *Lake) ResolveTimeTravel(ctx context.Context, db Execer, query string) (string, error) {
+	matches := asOfMatches(query)
+	if len(matches) == 0 {
+		return query, nil
+	}
+
+	tables, err := l.Tables()
+	if err != nil {
+		return "", err
+	}
+	byName := make(map[string]Table, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+
+	var b strings.Builder
+	created := make(map[string]bool)
+	last := 0
+	for _, m := range matches {
+		name := unquoteIdent(query[m[2]:m[3]])
+		ref := strings.ReplaceAll(query[m[4]:m[5]], "''", "'")
+
+		t, ok := byName[name]
+		if !ok {
+			// Unquoted identifiers are case-insensitive in DuckDB
+			for _, candidate := range tables {
+				if strings.EqualFold(candidate.Name, name) {
+					t, ok = candidate, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return "", fmt.Errorf("AS OF: unknown table %s", name)
+		}
+		snap, err := t.SnapshotAsOf(ref)
+		if err != nil {
+			return "", err
+		}
+
+		view := AsOfViewName(t.Name, snap)
+		if !created[view] {
+			createViewQuery := fmt.Sprintf("CREATE OR REPLACE TEMP VIEW %s AS %s;",
+				QuoteIdent(view), l.TableQuery(t, &snap))
+			if _, err := db.ExecContext(ctx, createViewQuery); err != nil {
+				return "", fmt.Errorf("creating view %s: %w", view, err)
+			}
+			created[view] = true
+		}
+
+		b.WriteString(query[last:m[0]])
+		b.WriteString(QuoteIdent(view))
+		last = m[1]
+	}
+	b.WriteString(query[last:])
+	return b.String(), nil
+}
+
+// asOfMatches finds the AS OF clauses of query outside string literals and
+// comments, as submatch indexes of asOfPattern.
+func asOfMatches(query string) [][]int {
+	// code marks where a clause can start: outside literals and comments,
+	// or at the opening quote of a quoted identifier
+	code := make([]bool, len(query))
+	for i := 0; i < len(query); i++ {
+		if end, ok := SkipQuoted(query, i); ok {
+			code[i] = query[i] == '"'
+			i = end
+			continue
+		}
+		code[i] = true
+	}
+
+	var matches [][]int
+	for from := 0; from < len(query); {
+		m := asOfPattern.FindStringSubmatchIndex(query[from:])
+		if m == nil {
+			break
+		}
+		for i := range m {
+			m[i] += from
+		}
+		if !code[m[0]] {
+			// Search again from inside the rejected match, which may hide
+			// a real clause
+			from = m[0] + 1
+			continue
+		}
+		matches = append(matches, m)
+		from = m[1]
+	}
+	return matches
+}
+
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+	}
+	return s
+}
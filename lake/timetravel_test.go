@@ -0,0 +1,125 @@
+package lake
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotAsOf(t *testing.T) {
+	at := func(s string) time.Time {
+		ts, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	table := Table{Name: "orders", Snapshots: []Snapshot{
+		{RunID: "run1", Timestamp: at("2024-03-01 10:00")},
+		{RunID: "run2", Timestamp: at("2024-03-05 10:00")},
+		{RunID: "run3", Timestamp: at("2024-03-05 18:00")},
+	}}
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{ref: "run2", want: "run2"},
+		{ref: "2024-03-01 10:00", want: "run1"},
+		{ref: "2024-03-05 09:59:59", want: "run1"},
+		{ref: "2024-03-05T12:00:00", want: "run2"},
+		{ref: "2024-03-05", want: "run3"},
+		{ref: "2024-04-01", want: "run3"},
+		{ref: "2024-02-28", wantErr: "no snapshot as of"},
+		{ref: "yesterday", wantErr: "neither a run ID nor a timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			snap, err := table.SnapshotAsOf(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SnapshotAsOf(%q) error = %v, want %q", tt.ref, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snap.RunID != tt.want {
+				t.Errorf("SnapshotAsOf(%q) = %s, want %s", tt.ref, snap.RunID, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTimeTravel(t *testing.T) {
+	l := newTestLake(t, "a")
+	first := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	writeTestRun(t, l, "orders", first, []string{"id"}, [][]string{{"1"}})
+	writeTestRun(t, l, "orders", first.AddDate(0, 0, 4), []string{"id"}, [][]string{{"2"}, {"3"}})
+
+	ctx := context.Background()
+	db, err := l.DB(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name    string
+		query   string
+		want    int64
+		wantErr string
+	}{
+		{name: "no AS OF", query: "SELECT count(*) FROM orders", want: 3},
+		{name: "before the second run", query: "SELECT count(*) FROM orders AS OF '2024-03-02'", want: 1},
+		{name: "after the second run", query: "SELECT count(*) FROM orders AS OF '2024-03-05 12:00'", want: 3},
+		{name: "run ID", query: "SELECT count(*) FROM orders AS OF '" + NewRunID(first) + "'", want: 1},
+		{name: "quoted identifier", query: `SELECT count(*) FROM "orders" as of '2024-03-02'`, want: 1},
+		{name: "case-insensitive table", query: "SELECT count(*) FROM ORDERS AS OF '2024-03-02'", want: 1},
+		{
+			name:  "two snapshots in one query",
+			query: "SELECT (SELECT count(*) FROM orders AS OF '2024-03-02') * 10 + (SELECT count(*) FROM orders AS OF '2024-03-06')",
+			want:  13,
+		},
+		{
+			name:  "AS OF in a string or comment",
+			query: "SELECT count(*) FROM orders WHERE 'orders AS OF ''2024-03-02''' <> '' -- orders AS OF '2024-03-02'",
+			want:  3,
+		},
+		{
+			name:  "AS OF after a block comment holding one",
+			query: "/* orders AS OF '2024-01-01' */ SELECT count(*) FROM orders AS OF '2024-03-02'",
+			want:  1,
+		},
+		{name: "unknown table", query: "SELECT * FROM missing AS OF '2024-03-02'", wantErr: "unknown table missing"},
+		{name: "before the first run", query: "SELECT * FROM orders AS OF '2024-01-01'", wantErr: "no snapshot as of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := l.ResolveTimeTravel(ctx, conn, tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveTimeTravel() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var n int64
+			if err := conn.QueryRowContext(ctx, query).Scan(&n); err != nil {
+				t.Fatalf("running %q: %v", query, err)
+			}
+			if n != tt.want {
+				t.Errorf("%q returned %d, want %d", query, n, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package lake
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SnapshotColumn is added to every landed row and records which run
+// produced it.
+const SnapshotColumn = "snapshot_ts"
+
+// LatestSuffix names the view holding only the most recent snapshot of a
+// table.
+const LatestSuffix = "_latest"
+
+// CreateViews creates two views per catalog table on db: one named after
+// the table that combines its snapshots according to the table's load mode,
+// and <table>_latest holding only the most recent snapshot. Only files
+// recorded in the manifest are visible, so anything half-written or left in
+// staging never shows up in a query.
+func (l *Lake) CreateViews(db *sql.DB) error {
+	tables, err := l.Tables()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tables {
+		if err := l.createViews(context.Background(), db, t, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createViews creates the query view and the _latest view of t. A
+// materialized table gets a table holding the view's rows instead.
+func (l *Lake) createViews(ctx context.Context, db Execer, t Table, materialize bool) error {
+	kind := "VIEW"
+	if materialize {
+		kind = "TABLE"
+	}
+	createQuery := fmt.Sprintf("CREATE %s %s AS %s;", kind, QuoteIdent(t.Name), l.TableQuery(t, nil))
+	if _, err := db.ExecContext(ctx, createQuery); err != nil {
+		return fmt.Errorf("creating %s %s: %w", strings.ToLower(kind), t.Name, err)
+	}
+
+	latestViewQuery := fmt.Sprintf("CREATE VIEW %s AS SELECT * FROM (%s) WHERE %s = %s;",
+		QuoteIdent(t.Name+LatestSuffix), l.tableSelect(t), SnapshotColumn, timestampLiteral(t.Latest().Timestamp))
+	if _, err := db.ExecContext(ctx, latestViewQuery); err != nil {
+		return fmt.Errorf("creating view %s: %w", t.Name+LatestSuffix, err)
+	}
+	return nil
+}
+
+// TableQuery returns the query behind a table's view. Full refresh tables
+// show their latest snapshot, append tables every snapshot and upsert tables
+// the newest row for each primary key. With asOf set, the table is built as
+// it stood right after that snapshot.
+func (l *Lake) TableQuery(t Table, asOf *Snapshot) string {
+	latest := t.Latest()
+	if asOf != nil {
+		latest = *asOf
+	}
+
+	switch t.Meta.Mode {
+	case FullRefresh:
+		return fmt.Sprintf("SELECT * FROM (%s) WHERE %s = %s",
+			l.tableSelect(t), SnapshotColumn, timestampLiteral(latest.Timestamp))
+	case Upsert:
+		if len(t.Meta.PrimaryKey) > 0 {
+			key := make([]string, len(t.Meta.PrimaryKey))
+			for i, column := range t.Meta.PrimaryKey {
+				key[i] = QuoteIdent(column)
+			}
+			return fmt.Sprintf("SELECT * FROM (%s) WHERE %s <= %s QUALIFY row_number() OVER (PARTITION BY %s ORDER BY %s DESC) = 1",
+				l.tableSelect(t), SnapshotColumn, timestampLiteral(latest.Timestamp), strings.Join(key, ", "), SnapshotColumn)
+		}
+	}
+	return fmt.Sprintf("SELECT * FROM (%s) WHERE %s <= %s",
+		l.tableSelect(t), SnapshotColumn, timestampLiteral(latest.Timestamp))
+}
+
+// tableSelect returns a query over every file of t with the Hive partition
+// columns included.
+func (l *Lake) tableSelect(t Table) string {
+	return l.filesSelect(t.Files, true)
+}
+
+// filesSelect returns a query over files. Files landed before rows were
+// stamped get their snapshot timestamp from the catalog instead.
+func (l *Lake) filesSelect(files []FileEntry, hivePartitioning bool) string {
+	var stamped []string
+	var parts []string
+	for _, f := range files {
+		if hasColumn(f.Schema, SnapshotColumn) {
+			stamped = append(stamped, QuoteLiteral(l.Path(f)))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("SELECT *, %s AS %s FROM read_parquet(%s, hive_partitioning = %t)",
+			timestampLiteral(f.LandedAt), SnapshotColumn, QuoteLiteral(l.Path(f)), hivePartitioning))
+	}
+	if len(stamped) > 0 {
+		parts = append([]string{fmt.Sprintf("SELECT * FROM read_parquet([%s], hive_partitioning = %t, union_by_name = true)",
+			strings.Join(stamped, ", "), hivePartitioning)}, parts...)
+	}
+	return strings.Join(parts, " UNION ALL BY NAME ")
+}
+
+func hasColumn(schema []Column, name string) bool {
+	for _, c := range schema {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
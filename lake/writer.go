@@ -0,0 +1,286 @@
+package lake
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const stagingDir = "_staging"
+
+// Writer lands the output of a single pipeline run. Files are staged in a
+// private directory and only become visible in the lake once Commit has
+// validated them, moved them into place and registered them in the manifest.
+type Writer struct {
+	lake        *Lake
+	pipeline    string
+	runID       string
+	staging     string
+	startedAt   time.Time
+	driftPolicy DriftPolicy
+	drift       []DriftEvent
+	meta        TableMeta
+}
+
+// NewWriter prepares a staging area for a run of the named pipeline.
+func (l *Lake) NewWriter(pipeline, runID string) (*Writer, error) {
+	staging := filepath.Join(l.Dir, stagingDir, runID)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		lake:        l,
+		pipeline:    pipeline,
+		runID:       runID,
+		staging:     staging,
+		startedAt:   time.Now().Truncate(time.Microsecond),
+		driftPolicy: DriftWarn,
+	}, nil
+}
+
+// SetDriftPolicy decides what Commit does when a table's schema differs
+// from its previous snapshot.
+func (w *Writer) SetDriftPolicy(policy DriftPolicy) {
+	w.driftPolicy = policy
+}
+
+// SetLoadMode sets how the tables written by this run combine with their
+// earlier snapshots. Upserts need the primary key columns.
+func (w *Writer) SetLoadMode(mode LoadMode, primaryKey []string) {
+	w.meta = TableMeta{Mode: mode, PrimaryKey: primaryKey}
+}
+
+// Drift returns the schema changes detected by the last Commit.
+func (w *Writer) Drift() []DriftEvent {
+	return w.drift
+}
+
+// StagingDir is where scripts drop their output. Each file becomes a table
+// named after the file, e.g. salesforce_report.parquet.
+func (w *Writer) StagingDir() string {
+	return w.staging
+}
+
+// WriteRows stages rows for table as a CSV file.
+func (w *Writer) WriteRows(table string, columns []string, rows [][]string) error {
+	f, err := os.Create(filepath.Join(w.staging, table+".csv"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cw := csv.NewWriter(f)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Abort discards everything staged for the run.
+func (w *Writer) Abort() error {
+	return os.RemoveAll(w.staging)
+}
+
+type stagedFile struct {
+	entry FileEntry
+	src   string
+	dest  string
+}
+
+// Commit converts every staged file to compressed Parquet, validates it and
+// atomically renames it into the table's partition directory. Nothing is
+// landed unless every staged file validates.
+func (w *Writer) Commit() ([]FileEntry, error) {
+	defer w.Abort()
+
+	sources, err := os.ReadDir(w.staging)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	outDir := filepath.Join(w.staging, ".out")
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+
+	var staged []stagedFile
+	for _, source := range sources {
+		if source.IsDir() || strings.HasPrefix(source.Name(), ".") {
+			continue
+		}
+		src := filepath.Join(w.staging, source.Name())
+		table := strings.TrimSuffix(source.Name(), filepath.Ext(source.Name()))
+		out := filepath.Join(outDir, table+".parquet")
+
+		entry, err := w.stage(db, table, src, out)
+		if err != nil {
+			return nil, fmt.Errorf("staging %s: %w", source.Name(), err)
+		}
+		entry.LandedAt = w.startedAt
+		entry.Path = filepath.Join(table, "dt="+w.startedAt.Format("2006-01-02"), table+"_"+w.runID+".parquet")
+		staged = append(staged, stagedFile{entry: entry, src: out, dest: filepath.Join(w.lake.Dir, entry.Path)})
+	}
+	if len(staged) == 0 {
+		return nil, fmt.Errorf("run %s staged no data", w.runID)
+	}
+
+	tables, err := w.lake.Tables()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTableNames(staged, tables); err != nil {
+		return nil, err
+	}
+	w.detectDrift(staged, tables)
+	if len(w.drift) > 0 && w.driftPolicy == DriftFail {
+		return nil, &DriftError{Events: w.drift}
+	}
+
+	var landed []stagedFile
+	for _, s := range staged {
+		if err := os.MkdirAll(filepath.Dir(s.dest), 0755); err != nil {
+			removeLanded(landed)
+			return nil, err
+		}
+		if err := os.Rename(s.src, s.dest); err != nil {
+			removeLanded(landed)
+			return nil, err
+		}
+		landed = append(landed, s)
+	}
+
+	entries := make([]FileEntry, len(landed))
+	for i, s := range landed {
+		entries[i] = s.entry
+	}
+	if err := w.lake.register(commit{files: entries, drift: w.drift, meta: w.meta}); err != nil {
+		removeLanded(landed)
+		return nil, err
+	}
+	w.lake.recordLineage(runLineage(w.pipeline, w.runID, entries), nil)
+	return entries, nil
+}
+
+// checkTableNames rejects staged tables whose objects in the lake database
+// would clash with those of another table. DuckDB matches names regardless
+// of case and every table also has a <table>_latest view. Names starting
+// with an underscore, like the database's state table, are rejected when
+// the file is staged.
+func checkTableNames(staged []stagedFile, tables []Table) error {
+	names := make(map[string]bool, len(tables)+len(staged))
+	for _, t := range tables {
+		names[t.Name] = true
+	}
+	for _, s := range staged {
+		names[s.entry.Table] = true
+	}
+	for _, s := range staged {
+		table := s.entry.Table
+		for name := range names {
+			switch {
+			case name == table:
+			case strings.EqualFold(name, table):
+				return fmt.Errorf("table %s clashes with table %s: names are not case-sensitive", table, name)
+			case strings.EqualFold(name, table+LatestSuffix):
+				return fmt.Errorf("view %s of table %s clashes with table %s", table+LatestSuffix, table, name)
+			case strings.EqualFold(name+LatestSuffix, table):
+				return fmt.Errorf("table %s clashes with view %s of table %s", table, name+LatestSuffix, name)
+			}
+		}
+	}
+	return nil
+}
+
+// detectDrift compares each staged file with the latest snapshot of its
+// table.
+func (w *Writer) detectDrift(staged []stagedFile, tables []Table) {
+	previous := make(map[string][]Column, len(tables))
+	for _, t := range tables {
+		previous[t.Name] = t.Schema()
+	}
+
+	w.drift = nil
+	for _, s := range staged {
+		schema, ok := previous[s.entry.Table]
+		if !ok {
+			continue
+		}
+		for _, event := range DiffSchema(schema, s.entry.Schema) {
+			event.Table = s.entry.Table
+			event.RunID = w.runID
+			event.DetectedAt = w.startedAt
+			w.drift = append(w.drift, event)
+		}
+	}
+}
+
+// stage rewrites src as compressed Parquet at out, stamping every row with
+// the snapshot timestamp, and checks the result holds the same rows as the
+// source.
+func (w *Writer) stage(db *sql.DB, table, src, out string) (FileEntry, error) {
+	if table == "" || strings.HasPrefix(table, "_") {
+		return FileEntry{}, fmt.Errorf("invalid table name %q", table)
+	}
+	reader, err := readerFor(src)
+	if err != nil {
+		return FileEntry{}, err
+	}
+
+	srcSchema, err := describe(db, reader)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	if hasColumn(srcSchema, SnapshotColumn) {
+		return FileEntry{}, fmt.Errorf("column %s is reserved", SnapshotColumn)
+	}
+
+	copyQuery := fmt.Sprintf("COPY (SELECT *, %s AS %s FROM %s) TO %s (FORMAT PARQUET, COMPRESSION ZSTD);",
+		timestampLiteral(w.startedAt), SnapshotColumn, reader, QuoteLiteral(out))
+	if _, err := db.Exec(copyQuery); err != nil {
+		return FileEntry{}, err
+	}
+
+	srcRows, err := countRows(db, reader)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	entry, err := inspectFile(db, out)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	if srcRows != entry.Rows {
+		return FileEntry{}, fmt.Errorf("row count mismatch: staged %d, wrote %d", srcRows, entry.Rows)
+	}
+	if len(srcSchema) == 0 {
+		return FileEntry{}, fmt.Errorf("no columns")
+	}
+	if w.meta.Mode == Upsert {
+		if err := validateKey(db, reader, srcSchema, w.meta.PrimaryKey); err != nil {
+			return FileEntry{}, err
+		}
+	}
+
+	entry.Table = table
+	entry.Pipeline = w.pipeline
+	entry.RunID = w.runID
+	return entry, nil
+}
+
+func removeLanded(landed []stagedFile) {
+	for _, s := range landed {
+		os.Remove(s.dest)
+	}
+}
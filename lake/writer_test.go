@@ -0,0 +1,168 @@
+package lake
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommit(t *testing.T) {
+	tests := []struct {
+		name string
+		// staged maps file names to their contents
+		staged  map[string]string
+		wantErr string
+		// wantRows is the row count of every table landed
+		wantRows map[string]int64
+	}{
+		{
+			name:     "lands every staged file",
+			staged:   map[string]string{"orders.csv": "id,total\n1,10\n2,20\n", "customers.csv": "id\n7\n"},
+			wantRows: map[string]int64{"orders": 2, "customers": 1},
+		},
+		{
+			name:    "nothing staged",
+			staged:  map[string]string{},
+			wantErr: "staged no data",
+		},
+		{
+			name:    "reserved table name",
+			staged:  map[string]string{"orders.csv": "id\n1\n", "_orders.csv": "id\n1\n"},
+			wantErr: "invalid table name",
+		},
+		{
+			name:    "reserved snapshot column",
+			staged:  map[string]string{"orders.csv": "id," + SnapshotColumn + "\n1,2024-01-01\n"},
+			wantErr: "is reserved",
+		},
+		{
+			// The staged name is a glob that also matches t1, so the
+			// Parquet written for it reads back more rows than it staged
+			name:    "row count mismatch",
+			staged:  map[string]string{"t1.csv": "id\n1\n2\n", "t?.csv": "id\n3\n"},
+			wantErr: "row count mismatch",
+		},
+		{
+			name:    "clashes with a latest view",
+			staged:  map[string]string{"orders.csv": "id\n1\n", "orders_latest.csv": "id\n1\n"},
+			wantErr: "view orders_latest of table orders",
+		},
+		{
+			name:    "differs only in case",
+			staged:  map[string]string{"orders.csv": "id\n1\n", "Orders.csv": "id\n1\n"},
+			wantErr: "names are not case-sensitive",
+		},
+		{
+			name:    "unsupported file type",
+			staged:  map[string]string{"orders.json": `{"id": 1}`},
+			wantErr: "unsupported file type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLake(t, "a")
+			w, err := l.NewWriter("test", NewRunID(time.Now()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, data := range tt.staged {
+				if err := os.WriteFile(filepath.Join(w.StagingDir(), name), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entries, err := w.Commit()
+			if _, statErr := os.Stat(w.StagingDir()); !os.IsNotExist(statErr) {
+				t.Errorf("staging directory left behind: %v", statErr)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Commit() error = %v, want %q", err, tt.wantErr)
+				}
+				assertLakeEmpty(t, l)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != len(tt.wantRows) {
+				t.Errorf("committed %d files, want %d", len(entries), len(tt.wantRows))
+			}
+			tables, err := l.Tables()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tables) != len(tt.wantRows) {
+				t.Fatalf("catalog has %d tables, want %d", len(tables), len(tt.wantRows))
+			}
+			for _, table := range tables {
+				if table.Rows() != tt.wantRows[table.Name] {
+					t.Errorf("%s has %d rows, want %d", table.Name, table.Rows(), tt.wantRows[table.Name])
+				}
+				if len(table.Snapshots) != 1 || table.Latest().RunID != w.runID {
+					t.Errorf("%s has snapshots %v, want one of run %s", table.Name, table.Snapshots, w.runID)
+				}
+				for _, f := range table.Files {
+					if !hasColumn(f.Schema, SnapshotColumn) {
+						t.Errorf("%s was landed without %s", f.Path, SnapshotColumn)
+					}
+					if _, err := os.Stat(l.Path(f)); err != nil {
+						t.Errorf("catalog file %s: %v", f.Path, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// assertLakeEmpty fails unless the lake has no catalog files and nothing
+// but bookkeeping on disk
+func assertLakeEmpty(t *testing.T, l *Lake) {
+	t.Helper()
+	m, err := l.LoadManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 0 || len(m.Snapshots) != 0 {
+		t.Errorf("manifest has %d files and %d snapshots, want none", len(m.Files), len(m.Snapshots))
+	}
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), "_") {
+			t.Errorf("table directory %s was landed", entry.Name())
+		}
+	}
+}
+
+func TestCommitRejectsNamesClashingWithLakeTables(t *testing.T) {
+	l := newTestLake(t, "a")
+	writeTestRun(t, l, "orders", time.Now().Add(-time.Hour), []string{"id"}, [][]string{{"1"}})
+	for table, wantErr := range map[string]string{
+		"orders_latest": "clashes with view orders_latest of table orders",
+		"ORDERS":        "names are not case-sensitive",
+	} {
+		w, err := l.NewWriter("test", NewRunID(time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteRows(table, []string{"id"}, [][]string{{"2"}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Commit(); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("committing %s: error = %v, want %q", table, err, wantErr)
+		}
+	}
+	tables, err := l.Tables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 {
+		t.Errorf("catalog has %d tables, want only orders", len(tables))
+	}
+}
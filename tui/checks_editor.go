@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// checksEditor edits a pipeline's data quality checks, one per line
+type checksEditor struct {
+	textarea textarea.Model
+	pipeline string
+	err      error
+}
+
+func newChecksEditor(p Pipeline, width, height int) *checksEditor {
+	ta := textarea.New()
+	ta.Placeholder = "not_null salesforce_report Id"
+	ta.CharLimit = 0
+	ta.ShowLineNumbers = true
+	ta.SetWidth(width)
+	ta.SetHeight(max(height-20, 5))
+	ta.Focus()
+
+	lines := make([]string, len(p.Checks))
+	for i, c := range p.Checks {
+		lines[i] = c.String()
+	}
+	ta.SetValue(strings.Join(lines, "\n"))
+	return &checksEditor{textarea: ta, pipeline: p.Name}
+}
+
+// Update handles a key press. It returns the parsed checks once the user
+// saves, and whether the editor should close.
+func (e *checksEditor) Update(msg tea.KeyMsg) ([]lake.Check, bool, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		return nil, true, nil
+	case tea.KeyCtrlS:
+		checks, err := parseChecks(e.textarea.Value())
+		if err != nil {
+			e.err = err
+			return nil, false, nil
+		}
+		return checks, true, nil
+	}
+	var cmd tea.Cmd
+	e.textarea, cmd = e.textarea.Update(msg)
+	return nil, false, cmd
+}
+
+// parseChecks parses one check per line, skipping blank lines and # comments
+func parseChecks(text string) ([]lake.Check, error) {
+	var checks []lake.Check
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		c, err := lake.ParseCheck(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+func (e *checksEditor) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	s := titleStyle.Render(fmt.Sprintf("Checks for pipeline: %s", e.pipeline)) + "\n\n"
+	s += "One check per line, evaluated against the output of every run:\n" +
+		"  not_null <table> <column>\n" +
+		"  unique <table> <column>\n" +
+		"  accepted_values <table> <column> <value>,<value>,...\n" +
+		"  row_count <table> <min> [max]\n" +
+		"  freshness <table> <column> <max age, e.g. 90m, 24h or 7d>\n" +
+		"  custom_sql <query returning the offending rows>\n\n"
+	s += e.textarea.View() + "\n"
+	if e.err != nil {
+		s += lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(e.err.Error()) + "\n"
+	}
+	s += footerStyle.Render("\nPress 'ctrl+s' to save, 'esc' to cancel")
+	return s
+}
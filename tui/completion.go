@@ -0,0 +1,404 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxCompletions is how many completions the popup shows at once
+const maxCompletions = 8
+
+var sqlKeywords = []string{
+	"ALL", "AND", "ANTI", "AS", "ASC", "ASOF", "BETWEEN", "BY", "CASE", "CAST", "COPY", "CREATE",
+	"CROSS", "DELETE", "DESC", "DESCRIBE", "DISTINCT", "DROP", "ELSE", "END", "EXCEPT", "EXCLUDE",
+	"EXISTS", "FILTER", "FIRST", "FROM", "FULL", "GROUP", "HAVING", "ILIKE", "IN", "INNER",
+	"INSERT", "INTERSECT", "INTO", "IS", "JOIN", "LAST", "LATERAL", "LEFT", "LIKE", "LIMIT",
+	"NOT", "NULL", "NULLS", "OF", "OFFSET", "ON", "OR", "ORDER", "OUTER", "OVER", "PARTITION",
+	"PIVOT", "POSITIONAL", "QUALIFY", "RANGE", "RECURSIVE", "REPLACE", "RIGHT", "ROWS", "SELECT",
+	"SEMI", "SET", "SUMMARIZE", "TABLE", "TEMP", "THEN", "TO", "TRY_CAST", "UNION", "UNPIVOT",
+	"UPDATE", "USING", "VALUES", "VIEW", "WHEN", "WHERE", "WINDOW", "WITH",
+}
+
+// tableKeywords are followed by a table name
+var tableKeywords = map[string]bool{
+	"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true,
+	"DESCRIBE": true, "SUMMARIZE": true,
+}
+
+// columnKeywords are followed by an expression over the columns of the
+// query's tables
+var columnKeywords = map[string]bool{
+	"SELECT": true, "WHERE": true, "BY": true, "ON": true, "AND": true, "OR": true,
+	"HAVING": true, "SET": true, "QUALIFY": true, "WHEN": true, "THEN": true, "ELSE": true,
+	"NOT": true, "DISTINCT": true, "USING": true, "EXCLUDE": true,
+}
+
+// completionSource is what the editor completes: the tables and views of the
+// lake with their columns, and DuckDB's functions
+type completionSource struct {
+	tables    map[string][]lake.Column
+	functions []string
+}
+
+// loadCompletions reads the lake's catalog. The lake database also knows
+// the _latest views and tables derived by queries, and DuckDB's functions;
+// without it the catalog's tables are still completed.
+func loadCompletions(dataLake string) (*completionSource, error) {
+	l, err := lake.OpenExisting(dataLake)
+	if err != nil {
+		return nil, err
+	}
+	src := &completionSource{tables: make(map[string][]lake.Column)}
+	tables, err := l.Tables()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		src.tables[t.Name] = t.Schema()
+	}
+
+	ctx := context.Background()
+	db, err := l.DB(ctx)
+	if err != nil {
+		return src, nil
+	}
+	rows, err := db.QueryContext(ctx, `SELECT table_name, column_name, data_type FROM information_schema.columns
+		WHERE table_schema = 'main' AND table_name <> ? ORDER BY table_name, ordinal_position`, lake.StateTable)
+	if err == nil {
+		fromDB := make(map[string][]lake.Column)
+		for rows.Next() {
+			var table string
+			var c lake.Column
+			if rows.Scan(&table, &c.Name, &c.Type) == nil {
+				fromDB[table] = append(fromDB[table], c)
+			}
+		}
+		rows.Close()
+		for table, columns := range fromDB {
+			src.tables[table] = columns
+		}
+	}
+	rows, err = db.QueryContext(ctx, `SELECT DISTINCT function_name FROM duckdb_functions()
+		WHERE function_type IN ('scalar', 'aggregate', 'table', 'macro', 'table_macro')`)
+	if err == nil {
+		for rows.Next() {
+			var name string
+			// Leave out operators and DuckDB's internal helpers
+			if rows.Scan(&name) == nil && plainIdent.MatchString(name) && !strings.HasPrefix(name, "_") {
+				src.functions = append(src.functions, name)
+			}
+		}
+		rows.Close()
+		sort.Strings(src.functions)
+	}
+	return src, nil
+}
+
+type completionsLoadedMsg struct {
+	src *completionSource
+	err error
+	// gen is the completion source the load is for and explicit whether the
+	// user asked for completions
+	gen      int
+	explicit bool
+}
+
+// loadCompletionsCmd reads the lake's catalog in the background, since
+// opening the lake database may rebuild its tables
+func loadCompletionsCmd(dataLake string, gen int, explicit bool) tea.Cmd {
+	return func() tea.Msg {
+		src, err := loadCompletions(dataLake)
+		return completionsLoadedMsg{src: src, err: err, gen: gen, explicit: explicit}
+	}
+}
+
+type completionItem struct {
+	text string
+	// detail is the kind of the item, or the type of a column
+	detail string
+}
+
+// completionContext is what precedes the cursor
+type completionContext struct {
+	// prefix is the part of the word being completed before the cursor
+	prefix string
+	// qualifier is the table or alias before a dot, as in o.<prefix>
+	qualifier string
+	// keyword is the last keyword before the word
+	keyword string
+}
+
+var (
+	wordBeforeCursor = regexp.MustCompile(`(?:("(?:[^"]|"")+"|[A-Za-z_][A-Za-z0-9_]*)\.)?([A-Za-z_][A-Za-z0-9_]*)?$`)
+	keywordToken     = regexp.MustCompile(`[A-Za-z_]+`)
+	// tableReference matches the tables of a query and their aliases
+	tableReference = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|UPDATE|INTO)\s+("(?:[^"]|"")+"|[A-Za-z_][A-Za-z0-9_]*)(?:\s+AS\s+OF\s+'[^']*')?(?:\s+(?:AS\s+)?([A-Za-z_][A-Za-z0-9_]*))?`)
+)
+
+// parseCompletionContext finds the word being completed in the text before
+// the cursor
+func parseCompletionContext(before string) completionContext {
+	m := wordBeforeCursor.FindStringSubmatch(before)
+	ctx := completionContext{prefix: m[2], qualifier: unquoteIdent(m[1])}
+	rest := before[:len(before)-len(m[0])]
+	for _, tok := range keywordToken.FindAllString(rest, -1) {
+		upper := strings.ToUpper(tok)
+		if tableKeywords[upper] || columnKeywords[upper] {
+			ctx.keyword = upper
+		}
+	}
+	return ctx
+}
+
+// unquoteIdent removes the quotes around a quoted identifier
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+	}
+	return s
+}
+
+// complete lists the completions for the word before the cursor. query is
+// the whole query, whose tables provide the columns.
+func (src *completionSource) complete(query string, ctx completionContext) []completionItem {
+	// Aliases and tables of the query
+	aliases := make(map[string]string)
+	var queryTables []string
+	for _, m := range tableReference.FindAllStringSubmatch(query, -1) {
+		table := unquoteIdent(m[1])
+		queryTables = append(queryTables, table)
+		aliases[strings.ToLower(table)] = table
+		if m[2] != "" && !tableKeywords[strings.ToUpper(m[2])] && !columnKeywords[strings.ToUpper(m[2])] {
+			aliases[strings.ToLower(m[2])] = table
+		}
+	}
+
+	var items []completionItem
+	addColumns := func(tables []string) {
+		seen := make(map[string]bool)
+		for _, t := range tables {
+			for _, c := range src.tables[t] {
+				if !seen[c.Name] {
+					seen[c.Name] = true
+					items = append(items, completionItem{text: c.Name, detail: c.Type})
+				}
+			}
+		}
+	}
+	addTables := func() {
+		names := make([]string, 0, len(src.tables))
+		for name := range src.tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			items = append(items, completionItem{text: name, detail: "table"})
+		}
+	}
+	addKeywords := func() {
+		for _, k := range sqlKeywords {
+			items = append(items, completionItem{text: k, detail: "keyword"})
+		}
+	}
+	addFunctions := func() {
+		for _, f := range src.functions {
+			items = append(items, completionItem{text: f, detail: "function"})
+		}
+	}
+
+	switch {
+	case ctx.qualifier != "":
+		if table, ok := aliases[strings.ToLower(ctx.qualifier)]; ok {
+			addColumns([]string{table})
+		} else {
+			addColumns([]string{ctx.qualifier})
+		}
+	case tableKeywords[ctx.keyword]:
+		addTables()
+		addKeywords()
+	case columnKeywords[ctx.keyword]:
+		addColumns(queryTables)
+		addFunctions()
+		addKeywords()
+	default:
+		addKeywords()
+		addTables()
+		addFunctions()
+	}
+
+	var matches []completionItem
+	prefix := strings.ToLower(ctx.prefix)
+	for _, item := range items {
+		if strings.HasPrefix(strings.ToLower(item.text), prefix) {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
+
+// completionPopup is the menu of completions shown below the editor
+type completionPopup struct {
+	items    []completionItem
+	prefix   string
+	selected int
+}
+
+// cursorText returns the text of a textarea before its cursor
+func cursorText(ta textarea.Model) string {
+	lines := strings.Split(ta.Value(), "\n")
+	row := ta.Line()
+	info := ta.LineInfo()
+	col := min(info.StartColumn+info.ColumnOffset, len([]rune(lines[row])))
+	return strings.Join(append(lines[:row:row], string([]rune(lines[row])[:col])), "\n")
+}
+
+// insideStringOrComment reports whether text ends inside a string literal or
+// a line comment, where nothing is completed
+func insideStringOrComment(text string) bool {
+	inString := false
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\'':
+			inString = !inString
+		case !inString && strings.HasPrefix(text[i:], "--"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				return true
+			}
+			i += end
+		}
+	}
+	return inString
+}
+
+// updateCompletion opens, refreshes or closes the completion popup for the
+// word before the cursor. explicit is set when the user asked for
+// completions, which then also opens without a prefix. The popup opens once
+// the completion source has loaded.
+func (qe *QueryEditor) updateCompletion(explicit bool) tea.Cmd {
+	before := cursorText(qe.textarea)
+	if insideStringOrComment(before) {
+		qe.completion = nil
+		return nil
+	}
+	ctx := parseCompletionContext(before)
+	dotted := strings.HasSuffix(before, ".")
+	if !explicit && len(ctx.prefix) < 2 && !dotted && qe.completion == nil {
+		return nil
+	}
+	if qe.completions == nil {
+		qe.completion = nil
+		if qe.loadingCompletions {
+			return nil
+		}
+		qe.loadingCompletions = true
+		return loadCompletionsCmd(qe.dataLake, qe.completionsGen, explicit)
+	}
+	items := qe.completions.complete(qe.textarea.Value(), ctx)
+	// A word typed out in full needs no popup
+	if len(items) == 0 || (!explicit && len(items) == 1 && strings.EqualFold(items[0].text, ctx.prefix)) {
+		qe.completion = nil
+		return nil
+	}
+	qe.completion = &completionPopup{items: items, prefix: ctx.prefix}
+	return nil
+}
+
+// finishLoadingCompletions keeps the loaded completion source and opens the
+// popup for the word now before the cursor
+func (qe *QueryEditor) finishLoadingCompletions(msg completionsLoadedMsg) {
+	if msg.gen != qe.completionsGen {
+		return
+	}
+	qe.loadingCompletions = false
+	if msg.err != nil {
+		qe.status = fmt.Sprintf("Error loading completions: %v", msg.err)
+		return
+	}
+	qe.completions = msg.src
+	if !qe.gridFocused && !qe.HasOverlay() {
+		qe.updateCompletion(msg.explicit)
+	}
+}
+
+// resetCompletions drops the completion source, which is loaded again when
+// next needed
+func (qe *QueryEditor) resetCompletions() {
+	qe.completions = nil
+	qe.completionsGen++
+	qe.loadingCompletions = false
+}
+
+// acceptCompletion replaces the word before the cursor with the selected
+// completion
+func (qe *QueryEditor) acceptCompletion() {
+	item := qe.completion.items[qe.completion.selected]
+	for range []rune(qe.completion.prefix) {
+		qe.textarea, _ = qe.textarea.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	text := item.text
+	if !plainIdent.MatchString(text) {
+		text = lake.QuoteIdent(text)
+	}
+	qe.textarea.InsertString(text)
+	qe.completion = nil
+}
+
+// updateCompletionPopup handles a key while the popup is open. It reports
+// whether the key was used by the popup.
+func (qe *QueryEditor) updateCompletionPopup(msg tea.KeyMsg) bool {
+	p := qe.completion
+	switch msg.String() {
+	case "up":
+		p.selected = (p.selected + len(p.items) - 1) % len(p.items)
+	case "down":
+		p.selected = (p.selected + 1) % len(p.items)
+	case "tab", "enter":
+		qe.acceptCompletion()
+	case "esc":
+		qe.completion = nil
+	default:
+		return false
+	}
+	return true
+}
+
+// isWordKey reports whether a key types part of an identifier, or a dot
+func isWordKey(msg tea.KeyMsg) bool {
+	if msg.Type != tea.KeyRunes || len(msg.Runes) != 1 {
+		return false
+	}
+	r := msg.Runes[0]
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (p *completionPopup) View() string {
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	detailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	boxStyle := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("8")).Padding(0, 1)
+
+	top := max(min(p.selected-maxCompletions/2, len(p.items)-maxCompletions), 0)
+	end := min(top+maxCompletions, len(p.items))
+	var lines []string
+	for i := top; i < end; i++ {
+		item := p.items[i]
+		line := fmt.Sprintf("%-32s", truncate(item.text, 32))
+		if i == p.selected {
+			line = selectedStyle.Render("> " + line)
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line+" "+detailStyle.Render(truncate(item.detail, 20)))
+	}
+	lines = append(lines, detailStyle.Render(fmt.Sprintf("%d of %d, 'tab' to complete, 'esc' to close", p.selected+1, len(p.items))))
+	return boxStyle.Render(strings.Join(lines, "\n"))
+}
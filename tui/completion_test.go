@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestCompletionsLoadInBackground(t *testing.T) {
+	l, err := lake.OpenExisting(newTestLake(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestTables(t, l, "orders")
+	qe := NewQueryEditor(l.Name, 120, 40)
+	qe.textarea.SetValue("SELECT * FROM o")
+
+	qe, cmd := qe.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if qe.completions != nil || qe.completion != nil {
+		t.Fatal("completions were loaded while handling the key")
+	}
+	var loaded tea.Msg
+	for _, msg := range runCmd(cmd) {
+		if msg, ok := msg.(completionsLoadedMsg); ok {
+			loaded = msg
+		}
+	}
+	if loaded == nil {
+		t.Fatal("typing a word did not load the completions")
+	}
+	if _, ok := loaded.(completionsLoadedMsg).src.tables[lake.StateTable]; ok {
+		t.Errorf("completions offer the internal %s table", lake.StateTable)
+	}
+
+	qe, _ = qe.Update(loaded)
+	if qe.completion == nil || qe.completion.items[0].text != "orders" {
+		t.Fatalf("popup after loading = %+v, want orders", qe.completion)
+	}
+
+	// A load started before the source was dropped is ignored
+	qe.resetCompletions()
+	qe, _ = qe.Update(loaded)
+	if qe.completions != nil {
+		t.Error("a stale completion source was kept")
+	}
+}
+
+// runCmd runs a command and the commands it batches, returning their messages
+func runCmd(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var msgs []tea.Msg
+		for _, c := range batch {
+			msgs = append(msgs, runCmd(c)...)
+		}
+		return msgs
+	}
+	return []tea.Msg{msg}
+}
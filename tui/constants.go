@@ -2,11 +2,18 @@ package tui
 
 // Message types for progress updates and script execution
 type progressMsg float64
-type scriptSuccessMsg string
+type scriptSuccessMsg struct {
+	output string
+	run    PipelineRun
+}
 type scriptErrorMsg struct{ err error }
 type createDataLakeErrorMsg struct{ err error }
 type createDataLakeSuccessMsg string
 type queryResultMsg struct {
-	result string
+	// cursor is the result of a query, script the results of a script
+	cursor *queryCursor
+	script *scriptResult
 	err    error
+	// id is the query editor's ID of the query
+	id int
 }
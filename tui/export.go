@@ -0,0 +1,468 @@
+package tui
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	duckdb "github.com/marcboeker/go-duckdb"
+)
+
+// exportBatchSize is how many rows are fetched at a time while exporting
+const exportBatchSize = 1000
+
+type exportFormat struct {
+	name string
+	ext  string
+}
+
+var exportFormats = []exportFormat{
+	{"CSV", ".csv"},
+	{"Parquet", ".parquet"},
+	{"NDJSON", ".ndjson"},
+	{"Markdown", ".md"},
+}
+
+// exportJob is the progress of a running export, shared with the command
+// writing the file
+type exportJob struct {
+	written atomic.Int64
+	// total is the number of rows to write, or -1 while unknown
+	total   atomic.Int64
+	started time.Time
+}
+
+type exportTickMsg struct {
+	job *exportJob
+}
+
+type exportDoneMsg struct {
+	job  *exportJob
+	rows int64
+	err  error
+}
+
+func exportTickCmd(job *exportJob) tea.Cmd {
+	return tea.Tick(queryTickInterval, func(time.Time) tea.Msg {
+		return exportTickMsg{job: job}
+	})
+}
+
+// exportScreen asks where to export a result to and shows the progress of
+// the export. The result as shown exports the grid's sort, filters and
+// visible columns; the query exports everything the editor's query returns.
+// Both run the query again and stream its rows to the file, so exports are
+// not limited to the rows the grid has loaded.
+type exportScreen struct {
+	dataLake string
+	// resultQuery selects the result as shown, or is empty if the grid has
+	// no result that can be exported
+	resultQuery string
+	query       string
+	// args are bound to the parameters of both queries
+	args       []any
+	wholeQuery bool
+	format     int
+	path       string
+	// overwrite is set once the user confirmed replacing an existing file
+	overwrite bool
+	job       *exportJob
+	cancel    context.CancelFunc
+	finished  bool
+	status    string
+	bar       progress.Model
+}
+
+func newExportScreen(dataLake, resultQuery, query string, args []any) *exportScreen {
+	s := &exportScreen{
+		dataLake:    dataLake,
+		resultQuery: resultQuery,
+		query:       query,
+		args:        args,
+		wholeQuery:  resultQuery == "",
+		bar:         progress.New(progress.WithDefaultGradient()),
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "."
+	}
+	s.path = filepath.Join(dir, fmt.Sprintf("%s_%s%s", dataLake, time.Now().Format("20060102_150405"), exportFormats[0].ext))
+	return s
+}
+
+// Running reports whether the export is writing its file
+func (s *exportScreen) Running() bool {
+	return s.job != nil && !s.finished
+}
+
+// Update handles a key press and reports whether the screen should close.
+func (s *exportScreen) Update(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if s.Running() {
+		if msg.String() == "esc" {
+			s.cancel()
+			s.status = "Cancelling..."
+		}
+		return false, nil
+	}
+	if s.finished {
+		return msg.String() == "esc" || msg.String() == "enter", nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		return true, nil
+	case "tab":
+		// Follow the format in the path's extension
+		old := exportFormats[s.format].ext
+		s.format = (s.format + 1) % len(exportFormats)
+		if strings.HasSuffix(s.path, old) {
+			s.path = strings.TrimSuffix(s.path, old) + exportFormats[s.format].ext
+		}
+	case "ctrl+a":
+		if s.resultQuery != "" {
+			s.wholeQuery = !s.wholeQuery
+		}
+	case "backspace":
+		if len(s.path) > 0 {
+			s.path = s.path[:len(s.path)-1]
+		}
+		s.overwrite = false
+	case "enter":
+		return false, s.start()
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			s.path += string(msg.Runes)
+			s.overwrite = false
+		}
+	}
+	s.status = ""
+	return false, nil
+}
+
+// start checks the path and starts writing the file
+func (s *exportScreen) start() tea.Cmd {
+	path := strings.TrimSpace(s.path)
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	if path == "" {
+		s.status = "Enter the path of the file to export to"
+		return nil
+	}
+	if _, err := os.Stat(path); err == nil && !s.overwrite {
+		s.overwrite = true
+		s.status = "The file already exists, press 'enter' again to replace it"
+		return nil
+	}
+	query := s.query
+	if !s.wholeQuery {
+		query = s.resultQuery
+	}
+	statement := strings.TrimRight(strings.TrimSpace(query), ";")
+	if !countablePattern.MatchString(statement) || strings.Contains(statement, ";") {
+		s.status = "Only the result of a single SELECT can be exported"
+		return nil
+	}
+
+	s.path = path
+	s.status = ""
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	job := &exportJob{started: time.Now()}
+	job.total.Store(-1)
+	s.job = job
+	dataLake, format, args := s.dataLake, exportFormats[s.format], s.args
+	write := func() tea.Msg {
+		defer cancel()
+		rows, err := exportFile(ctx, dataLake, statement, args, format, path, job)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = context.Canceled
+			}
+		}
+		return exportDoneMsg{job: job, rows: rows, err: err}
+	}
+	return tea.Batch(write, exportTickCmd(job))
+}
+
+// Progress handles the messages of a running export
+func (s *exportScreen) Progress(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case exportTickMsg:
+		if msg.job == s.job && s.Running() {
+			return exportTickCmd(msg.job)
+		}
+	case exportDoneMsg:
+		if msg.job != s.job {
+			return nil
+		}
+		s.finished = true
+		elapsed := time.Since(s.job.started).Round(time.Millisecond)
+		switch {
+		case errors.Is(msg.err, context.Canceled):
+			s.status = "Export cancelled"
+		case msg.err != nil:
+			s.status = fmt.Sprintf("Export failed: %v", msg.err)
+		default:
+			s.status = fmt.Sprintf("Exported %d rows to %s in %s", msg.rows, s.path, elapsed)
+		}
+	}
+	return nil
+}
+
+func (s *exportScreen) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	out := titleStyle.Render("Export") + "\n\n"
+	if s.job != nil {
+		format := exportFormats[s.format]
+		out += fmt.Sprintf("Writing %s to %s\n\n", format.name, s.path)
+		written, total := s.job.written.Load(), s.job.total.Load()
+		elapsed := time.Since(s.job.started).Round(100 * time.Millisecond)
+		switch {
+		case total > 0:
+			out += s.bar.ViewAs(min(float64(written)/float64(total), 1)) + "\n"
+			out += fmt.Sprintf("%d of %d rows, %s\n", written, total, elapsed)
+		case format.name == "Parquet":
+			// DuckDB writes Parquet itself, so only the file's size is known
+			size := int64(0)
+			if info, err := os.Stat(s.path); err == nil {
+				size = info.Size()
+			}
+			out += fmt.Sprintf("%s written, %s\n", formatBytes(size), elapsed)
+		default:
+			out += fmt.Sprintf("%d rows, %s\n", written, elapsed)
+		}
+		if s.finished {
+			out += "\n" + s.status + "\n" + footerStyle.Render("\nPress 'enter' or 'esc' to close")
+		} else {
+			out += "\n" + s.status + "\n" + footerStyle.Render("\nPress 'esc' to cancel")
+		}
+		return out
+	}
+
+	out += "Format: "
+	for i, f := range exportFormats {
+		if i == s.format {
+			out += selectedStyle.Render("["+f.name+"]") + " "
+		} else {
+			out += " " + f.name + "  "
+		}
+	}
+	out += "\nRows:   "
+	switch {
+	case s.resultQuery == "":
+		out += "everything the query returns"
+	case s.wholeQuery:
+		out += "everything the query returns, ignoring the grid's sort, filters and hidden columns"
+	default:
+		out += "the result as shown, with the grid's sort, filters and visible columns"
+	}
+	out += fmt.Sprintf("\n\nExport to:\n> %s\n", s.path)
+	if s.status != "" {
+		out += "\n" + s.status + "\n"
+	}
+	help := "\nPress 'tab' to change the format, "
+	if s.resultQuery != "" {
+		help += "'ctrl+a' to switch between the result as shown and the whole query, "
+	}
+	out += footerStyle.Render(help + "'enter' to export or 'esc' to cancel")
+	return out
+}
+
+// exportFile exports the result of a query to a temporary file next to path
+// and moves it over path once it is complete. A failed or cancelled export
+// leaves any file already at path as it was.
+func exportFile(ctx context.Context, dataLake, query string, args []any, format exportFormat, path string, job *exportJob) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return 0, err
+	}
+	tmp.Close()
+	rows, err := exportQuery(ctx, dataLake, query, args, format, tmp.Name(), job)
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return rows, err
+	}
+	return rows, nil
+}
+
+// exportQuery runs a query and writes its result to path, returning the
+// number of rows written. Parquet is written by DuckDB; the other formats
+// are streamed through the cursor a batch at a time.
+func exportQuery(ctx context.Context, dataLake, query string, args []any, format exportFormat, path string, job *exportJob) (int64, error) {
+	if format.name == "Parquet" {
+		return exportParquet(ctx, dataLake, query, args, path)
+	}
+
+	cursor, err := openQuery(ctx, dataLake, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close()
+	if cursor.countQuery != "" {
+		go func() {
+			if total, err := countRows(ctx, dataLake, cursor.countQuery, args...); err == nil {
+				job.total.Store(total)
+			}
+		}()
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	buf := bufio.NewWriter(file)
+
+	var writeRow func([]interface{}) error
+	switch format.name {
+	case "CSV":
+		w := csv.NewWriter(buf)
+		if err := w.Write(cursor.columns); err != nil {
+			return 0, err
+		}
+		writeRow = func(row []interface{}) error {
+			record := make([]string, len(row))
+			for i, val := range row {
+				// NULL is an empty field in CSV
+				if val != nil {
+					record[i] = formatValue(val, cursor.types[i])
+				}
+			}
+			w.Write(record)
+			return w.Error()
+		}
+		defer w.Flush()
+	case "NDJSON":
+		keys := make([][]byte, len(cursor.columns))
+		for i, c := range cursor.columns {
+			keys[i], _ = json.Marshal(c)
+		}
+		writeRow = func(row []interface{}) error {
+			// Write the object by hand to keep the columns in order
+			buf.WriteByte('{')
+			for i, val := range row {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				buf.Write(keys[i])
+				buf.WriteByte(':')
+				buf.Write(jsonValue(val, cursor.types[i]))
+			}
+			_, err := buf.WriteString("}\n")
+			return err
+		}
+	case "Markdown":
+		writeMarkdownRow(buf, cursor.columns)
+		separator := make([]string, len(cursor.columns))
+		for i := range separator {
+			separator[i] = "---"
+		}
+		writeMarkdownRow(buf, separator)
+		writeRow = func(row []interface{}) error {
+			cells := make([]string, len(row))
+			for i, val := range row {
+				cells[i] = formatValue(val, cursor.types[i])
+			}
+			return writeMarkdownRow(buf, cells)
+		}
+	}
+
+	var written int64
+	for !cursor.done {
+		page, err := cursor.FetchValues(exportBatchSize)
+		if err != nil {
+			return written, err
+		}
+		for _, row := range page {
+			if err := writeRow(row); err != nil {
+				return written, err
+			}
+		}
+		written += int64(len(page))
+		job.written.Store(written)
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+	}
+	return written, buf.Flush()
+}
+
+// exportParquet lets DuckDB stream the result of a query into a Parquet
+// file, keeping the types of its columns
+func exportParquet(ctx context.Context, dataLake, query string, args []any, path string) (int64, error) {
+	l, err := lake.OpenExisting(dataLake)
+	if err != nil {
+		return 0, err
+	}
+	db, err := l.DB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	resolved, err := l.ResolveTimeTravel(ctx, conn, query)
+	if err != nil {
+		return 0, err
+	}
+	// The newline ends a trailing line comment before the parenthesis
+	copyQuery := fmt.Sprintf("COPY (%s\n) TO %s (FORMAT PARQUET, COMPRESSION ZSTD);",
+		strings.TrimRight(strings.TrimSpace(resolved), ";"), lake.QuoteLiteral(path))
+	res, err := conn.ExecContext(ctx, copyQuery, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// jsonValue encodes a scanned DuckDB value as JSON. Numbers and booleans
+// stay unquoted; values JSON has no type for are written as displayed.
+func jsonValue(val interface{}, dbType string) []byte {
+	switch val.(type) {
+	case duckdb.Decimal:
+		return []byte(formatValue(val, dbType))
+	case time.Time, []byte:
+		val = formatValue(val, dbType)
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		b, _ = json.Marshal(formatValue(val, dbType))
+	}
+	return b
+}
+
+// writeMarkdownRow writes a row of a Markdown table, escaping the characters
+// that would break the table
+func writeMarkdownRow(w io.Writer, cells []string) error {
+	escaper := strings.NewReplacer("|", `\|`, "\r", "", "\n", "<br>")
+	line := "|"
+	for _, c := range cells {
+		line += " " + escaper.Replace(c) + " |"
+	}
+	_, err := io.WriteString(w, line+"\n")
+	return err
+}
@@ -0,0 +1,68 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportQueryEndingInComment(t *testing.T) {
+	dataLake := newTestLake(t)
+	query := "SELECT range AS n FROM range(3) -- three rows"
+	for _, format := range exportFormats {
+		t.Run(format.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out"+format.ext)
+			job := &exportJob{started: time.Now()}
+			rows, err := exportQuery(context.Background(), dataLake, query, nil, format, path, job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rows != 3 {
+				t.Errorf("exported %d rows, want 3", rows)
+			}
+			if format.name == "CSV" {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := strings.TrimSpace(string(data)); got != "n\n0\n1\n2" {
+					t.Errorf("exported %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestFailedExportKeepsExistingFile(t *testing.T) {
+	dataLake := newTestLake(t)
+	for _, format := range exportFormats {
+		t.Run(format.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "out"+format.ext)
+			if err := os.WriteFile(path, []byte("keep me"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			job := &exportJob{started: time.Now()}
+			if _, err := exportFile(context.Background(), dataLake, "SELECT * FROM missing_table", nil, format, path, job); err == nil {
+				t.Fatal("exporting a failing query succeeded")
+			}
+			data, err := os.ReadFile(path)
+			if err != nil || string(data) != "keep me" {
+				t.Errorf("existing file is %q, %v after a failed export", data, err)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("failed export left %d files behind", len(entries)-1)
+			}
+
+			if _, err := exportFile(context.Background(), dataLake, "SELECT 1 AS n", nil, format, path, job); err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := os.ReadFile(path); string(data) == "keep me" {
+				t.Error("successful export did not replace the file")
+			}
+		})
+	}
+}
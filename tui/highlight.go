@@ -0,0 +1,328 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sqlTokenKind is how a character of a query is highlighted
+type sqlTokenKind int
+
+const (
+	plainText sqlTokenKind = iota
+	keywordText
+	functionText
+	stringText
+	numberText
+	commentText
+	parameterText
+)
+
+var tokenStyles = map[sqlTokenKind]lipgloss.Style{
+	plainText:    lipgloss.NewStyle(),
+	keywordText:  lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12")),
+	functionText: lipgloss.NewStyle().Foreground(lipgloss.Color("6")),
+	stringText:   lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+	numberText:   lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+	commentText:  lipgloss.NewStyle().Foreground(lipgloss.Color("8")),
+	// Named parameters, whose values are asked for when the query runs
+	parameterText: lipgloss.NewStyle().Foreground(lipgloss.Color("13")),
+}
+
+// highlightedKeywords are the completed keywords and a few more that are
+// only highlighted
+var highlightedKeywords = func() map[string]bool {
+	keywords := map[string]bool{}
+	for _, k := range sqlKeywords {
+		keywords[k] = true
+	}
+	for _, k := range []string{"ALTER", "ATTACH", "CHECKPOINT", "DEFAULT", "EXPLAIN", "FALSE", "FOR", "IF",
+		"INTERVAL", "KEY", "MATERIALIZED", "PRAGMA", "PRIMARY", "RETURNING", "TRUE", "VACUUM"} {
+		keywords[k] = true
+	}
+	return keywords
+}()
+
+// highlightQuery classifies each rune of a query for highlighting. Strings
+// and block comments may span lines, so the whole query is classified at
+// once.
+func highlightQuery(text []rune) []sqlTokenKind {
+	kinds := make([]sqlTokenKind, len(text))
+	mark := func(from, to int, kind sqlTokenKind) {
+		for i := from; i < to; i++ {
+			kinds[i] = kind
+		}
+	}
+	// until returns the index after the closing delimiter, or the end of
+	// the text if it is missing
+	until := func(from int, end string) int {
+		if i := strings.Index(string(text[from:]), end); i >= 0 {
+			return from + utf8.RuneCountInString(string(text[from:])[:i]) + utf8.RuneCountInString(end)
+		}
+		return len(text)
+	}
+	isWord := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	for i := 0; i < len(text); {
+		r := text[i]
+		switch {
+		case r == '-' && i+1 < len(text) && text[i+1] == '-':
+			end := until(i, "\n")
+			mark(i, end, commentText)
+			i = end
+		case r == '/' && i+1 < len(text) && text[i+1] == '*':
+			end := until(i+2, "*/")
+			mark(i, end, commentText)
+			i = end
+		case r == '\'':
+			// A doubled quote is an escaped quote and continues the string
+			end := until(i+1, "'")
+			for end < len(text) && text[end] == '\'' {
+				end = until(end+1, "'")
+			}
+			mark(i, end, stringText)
+			i = end
+		case r == '"':
+			i = until(i+1, `"`)
+		case r == ':' && i+1 < len(text) && (text[i+1] == '_' || unicode.IsLetter(text[i+1])) &&
+			(i == 0 || !(isWord(text[i-1]) || strings.ContainsRune(":])'\"", text[i-1]))):
+			end := i + 1
+			for end < len(text) && isWord(text[end]) {
+				end++
+			}
+			mark(i, end, parameterText)
+			i = end
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(text) && unicode.IsDigit(text[i+1])):
+			end := i
+			for end < len(text) && (isWord(text[end]) || text[end] == '.') {
+				end++
+			}
+			mark(i, end, numberText)
+			i = end
+		case isWord(r):
+			end := i
+			for end < len(text) && isWord(text[end]) {
+				end++
+			}
+			next := end
+			for next < len(text) && text[next] == ' ' {
+				next++
+			}
+			switch {
+			case highlightedKeywords[strings.ToUpper(string(text[i:end]))]:
+				mark(i, end, keywordText)
+			case next < len(text) && text[next] == '(':
+				mark(i, end, functionText)
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	return kinds
+}
+
+// errorPosition is where in a query DuckDB found an error, as a line and a
+// rune column counted from zero
+type errorPosition struct {
+	line   int
+	column int
+}
+
+var (
+	// DuckDB shows the line of a binder or catalog error with a caret under
+	// the offending character:
+	//
+	//	LINE 3:   foo(3)
+	//	          ^
+	errorContextPattern = regexp.MustCompile(`(?m)^LINE (\d+): (.*)\n( *)\^`)
+	// Parser errors only name the token they stopped at
+	errorNearPattern = regexp.MustCompile(`at or near "([^"]*)"`)
+)
+
+// queryErrorPosition finds the position of an error in the query that
+// caused it
+func queryErrorPosition(message, query string) (errorPosition, bool) {
+	lines := strings.Split(query, "\n")
+	if m := errorContextPattern.FindStringSubmatch(message); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n < 1 || n > len(lines) {
+			return errorPosition{}, false
+		}
+		line := lines[n-1]
+		caret := len(m[3]) - len(fmt.Sprintf("LINE %s: ", m[1]))
+		excerpt := m[2]
+		// Long lines are cut down to the part around the error, marked by
+		// "...", so the excerpt is looked up in the line
+		start := 0
+		if trimmed, ok := strings.CutPrefix(excerpt, "..."); ok {
+			excerpt, caret = trimmed, caret-3
+			start = -1
+		}
+		if i := strings.Index(line, strings.TrimSuffix(excerpt, "...")); i >= 0 {
+			start = i
+		}
+		if start < 0 || caret < 0 {
+			return errorPosition{}, false
+		}
+		// The caret counts characters rather than bytes
+		column := min(utf8.RuneCountInString(line[:start])+caret, utf8.RuneCountInString(line))
+		return errorPosition{line: n - 1, column: column}, true
+	}
+	if strings.Contains(message, "at end of input") {
+		last := len(lines) - 1
+		return errorPosition{line: last, column: utf8.RuneCountInString(lines[last])}, true
+	}
+	if m := errorNearPattern.FindStringSubmatch(message); m != nil && m[1] != "" {
+		if i := strings.Index(query, m[1]); i >= 0 {
+			before := strings.Split(query[:i], "\n")
+			return errorPosition{line: len(before) - 1, column: utf8.RuneCountInString(before[len(before)-1])}, true
+		}
+	}
+	return errorPosition{}, false
+}
+
+// failedAt is where the last query from the editor failed, while the
+// editor still holds that query
+func (qe *QueryEditor) failedAt() (errorPosition, bool) {
+	if qe.errorAt == nil || qe.textarea.Value() != qe.errorQuery {
+		return errorPosition{}, false
+	}
+	return *qe.errorAt, true
+}
+
+// editorView draws the query highlighted, with line numbers, the cursor and
+// a marker at the position of the last error, in place of the plain text
+// area. The text area still does the editing.
+func (qe *QueryEditor) editorView() string {
+	gutterStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	errorStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("1"))
+	markerStyle := lipgloss.NewStyle().Underline(true).Foreground(lipgloss.Color("1"))
+	cursorStyle := lipgloss.NewStyle().Reverse(true)
+
+	const gutterWidth = 6
+	width := max(qe.width-gutterWidth, 10)
+	height := qe.textarea.Height()
+	focused := qe.textarea.Focused()
+
+	value := qe.textarea.Value()
+	text := []rune(value)
+	kinds := highlightQuery(text)
+	errAt, hasErr := qe.failedAt()
+	row := qe.textarea.Line()
+	info := qe.textarea.LineInfo()
+	column := info.StartColumn + info.ColumnOffset
+
+	// Wrap the lines to the width of the editor, remembering which line
+	// each row belongs to and where the cursor is
+	type editorRow struct {
+		line, start int
+		text        []rune
+		kinds       []sqlTokenKind
+	}
+	var rows []editorRow
+	cursorRow := 0
+	offset := 0
+	for i, line := range strings.Split(value, "\n") {
+		runes := []rune(line)
+		for start := 0; ; start += width {
+			end := min(start+width, len(runes))
+			if i == row && column >= start && (column < end || end == len(runes)) {
+				cursorRow = len(rows)
+			}
+			rows = append(rows, editorRow{line: i, start: start, text: runes[start:end], kinds: kinds[offset+start : offset+end]})
+			if end == len(runes) {
+				break
+			}
+		}
+		offset += len(runes) + 1
+	}
+
+	// Scroll to keep the cursor in view
+	if cursorRow < qe.editorTop {
+		qe.editorTop = cursorRow
+	}
+	if cursorRow >= qe.editorTop+height {
+		qe.editorTop = cursorRow - height + 1
+	}
+	qe.editorTop = min(qe.editorTop, max(len(rows)-height, 0))
+
+	var out []string
+	for r := qe.editorTop; r < qe.editorTop+height; r++ {
+		if r >= len(rows) {
+			out = append(out, gutterStyle.Render("┃"))
+			continue
+		}
+		er := rows[r]
+		gutter := gutterStyle.Render("┃     ")
+		if er.start == 0 {
+			number := fmt.Sprintf("%3d ", er.line+1)
+			if hasErr && er.line == errAt.line {
+				number = errorStyle.Render(number)
+			} else {
+				number = gutterStyle.Render(number)
+			}
+			gutter = gutterStyle.Render("┃ ") + number
+		}
+		if len(text) == 0 {
+			placeholder := gutterStyle.Render(qe.textarea.Placeholder)
+			if focused {
+				placeholder = cursorStyle.Render(qe.textarea.Placeholder[:1]) + gutterStyle.Render(qe.textarea.Placeholder[1:])
+			}
+			out = append(out, gutter+placeholder)
+			continue
+		}
+
+		// Characters are drawn as highlighted, as the cursor or as the error
+		// marker, and runs of characters drawn alike are rendered together
+		const (
+			cursorCell = -1
+			markerCell = -2
+		)
+		cellAt := func(c int) int {
+			col := er.start + c
+			switch {
+			case focused && er.line == row && col == column:
+				return cursorCell
+			case hasErr && er.line == errAt.line && col == errAt.column:
+				return markerCell
+			case c < len(er.kinds):
+				return int(er.kinds[c])
+			}
+			return int(plainText)
+		}
+		cells := er.text
+		// The cursor or the marker may sit after the last character
+		if r == len(rows)-1 || rows[r+1].line != er.line {
+			end := er.start + len(cells)
+			if (focused && er.line == row && column == end) || (hasErr && er.line == errAt.line && errAt.column == end) {
+				cells = append(cells[:len(cells):len(cells)], ' ')
+			}
+		}
+		var b strings.Builder
+		for c := 0; c < len(cells); {
+			cell := cellAt(c)
+			end := c + 1
+			for end < len(cells) && cellAt(end) == cell {
+				end++
+			}
+			style := tokenStyles[sqlTokenKind(cell)]
+			switch cell {
+			case cursorCell:
+				style = cursorStyle
+			case markerCell:
+				style = markerStyle
+			}
+			b.WriteString(style.Render(strings.ReplaceAll(string(cells[c:end]), "\t", " ")))
+			c = end
+		}
+		out = append(out, gutter+b.String())
+	}
+	return strings.Join(out, "\n")
+}
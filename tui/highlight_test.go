@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQueryErrorPosition(t *testing.T) {
+	// caretAt renders DuckDB's caret line under a LINE excerpt, col runes in
+	caretAt := func(line, excerpt string, col int) string {
+		prefix := "LINE " + line + ": "
+		return prefix + excerpt + "\n" + strings.Repeat(" ", len(prefix)+col) + "^"
+	}
+	long := "SELECT a_very_long_column_name, another_long_column_name FROM orders WHERE foo = 1 AND bar = 2"
+	tests := []struct {
+		name    string
+		message string
+		query   string
+		want    errorPosition
+		wantOK  bool
+	}{
+		{
+			name:    "caret",
+			message: "Binder Error: Referenced column \"foo\" not found in FROM clause!\n" + caretAt("2", "WHERE foo = 1", 6),
+			query:   "SELECT *\nWHERE foo = 1",
+			want:    errorPosition{line: 1, column: 6},
+			wantOK:  true,
+		},
+		{
+			name:    "caret in a truncated excerpt",
+			message: "Binder Error: Referenced column \"foo\" not found\n" + caretAt("1", "...orders WHERE foo = 1 AND bar...", 3+13),
+			query:   long,
+			want:    errorPosition{line: 0, column: strings.Index(long, "foo")},
+			wantOK:  true,
+		},
+		{
+			name:    "caret counts characters",
+			message: "Binder Error: Referenced column \"foo\" not found\n" + caretAt("1", "SELECT 'héllo', foo", 16),
+			query:   "SELECT 'héllo', foo",
+			want:    errorPosition{line: 0, column: 16},
+			wantOK:  true,
+		},
+		{
+			name:    "truncated excerpt after multibyte text",
+			message: "Binder Error: Referenced column \"foo\" not found\n" + caretAt("1", "...x, foo FROM t", 3+3),
+			query:   "SELECT 'ééééé', x, foo FROM t",
+			want:    errorPosition{line: 0, column: 19},
+			wantOK:  true,
+		},
+		{
+			name:    "caret past the end of the line",
+			message: "Parser Error: syntax error\n" + caretAt("1", "SELECT", 9),
+			query:   "SELECT",
+			want:    errorPosition{line: 0, column: 6},
+			wantOK:  true,
+		},
+		{
+			name:    "line outside the query",
+			message: "Binder Error: oops\n" + caretAt("5", "foo", 0),
+			query:   "SELECT foo",
+		},
+		{
+			name:    "at end of input",
+			message: "Parser Error: syntax error at end of input",
+			query:   "SELECT 'é'\nFROM é",
+			want:    errorPosition{line: 1, column: 6},
+			wantOK:  true,
+		},
+		{
+			name:    "at or near",
+			message: `Parser Error: syntax error at or near "FORM"`,
+			query:   "SELECT 'é'\n  FORM t",
+			want:    errorPosition{line: 1, column: 2},
+			wantOK:  true,
+		},
+		{
+			name:    "no position",
+			message: "Catalog Error: Table with name orders does not exist!",
+			query:   "SELECT * FROM orders",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := queryErrorPosition(tt.message, tt.query)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("queryErrorPosition = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestErrorPositionInScript(t *testing.T) {
+	script := "SELECT 1;\nSELECT 'é'; SELECT foo\n  FROM bar"
+	offset := strings.Index(script, "SELECT foo")
+	tests := []struct {
+		name string
+		at   errorPosition
+		want errorPosition
+	}{
+		{"first line of the statement", errorPosition{line: 0, column: 7}, errorPosition{line: 1, column: 19}},
+		{"later line of the statement", errorPosition{line: 1, column: 7}, errorPosition{line: 2, column: 7}},
+	}
+	for _, tt := range tests {
+		if got := tt.at.in(script, offset); got != tt.want {
+			t.Errorf("%s: %+v in the script = %+v, want %+v", tt.name, tt.at, got, tt.want)
+		}
+	}
+	if got := (errorPosition{line: 0, column: 3}).in(script, 0); got != (errorPosition{line: 0, column: 3}) {
+		t.Errorf("position in the first statement moved to %+v", got)
+	}
+}
@@ -0,0 +1,289 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sahilm/fuzzy"
+)
+
+const (
+	historyFile = "query_history.json"
+	// maxHistoryEntries caps the history, dropping the oldest queries
+	maxHistoryEntries = 1000
+	// historyPageSize is how many queries the history panel lists at once
+	historyPageSize = 15
+)
+
+// HistoryEntry is a query run from the query editor
+type HistoryEntry struct {
+	Lake     string        `json:"lake"`
+	Query    string        `json:"query"`
+	RanAt    time.Time     `json:"ran_at"`
+	Duration time.Duration `json:"duration"`
+	// Rows is the number of rows returned, or -1 if unknown
+	Rows   int64  `json:"rows"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Query outcomes recorded in the history
+const (
+	historySucceeded = "succeeded"
+	historyFailed    = "failed"
+	historyCancelled = "cancelled"
+	historyTimedOut  = "timed out"
+)
+
+func historyPath() (string, error) {
+	storageDir, err := getStorageDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(storageDir, historyFile), nil
+}
+
+// loadHistory returns the query history, oldest first
+func loadHistory() ([]HistoryEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeHistory(data)
+}
+
+// decodeHistory reads the entries of a history file. A damaged file yields
+// the entries before the damage along with the error.
+func decodeHistory(data []byte) ([]HistoryEntry, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if token, err := dec.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('[') {
+		return nil, fmt.Errorf("query history is not a list")
+	}
+	var entries []HistoryEntry
+	for dec.More() {
+		var entry HistoryEntry
+		if err := dec.Decode(&entry); err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	if _, err := dec.Token(); err != nil {
+		return entries, err
+	}
+	return entries, nil
+}
+
+func saveHistory(entries []HistoryEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return lake.WriteFileAtomic(path, data)
+}
+
+// updateHistory applies change to the stored history. When the history
+// file is damaged, a copy is kept next to it with a .damaged suffix and the
+// entries that could still be read carry on.
+func updateHistory(change func([]HistoryEntry) []HistoryEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var entries []HistoryEntry
+	if len(data) > 0 {
+		if entries, err = decodeHistory(data); err != nil {
+			if err := lake.WriteFileAtomic(path+".damaged", data); err != nil {
+				return err
+			}
+		}
+	}
+	return saveHistory(change(entries))
+}
+
+// appendHistory records a query. History is best effort and never fails a
+// query.
+func appendHistory(entry HistoryEntry) {
+	updateHistory(func(entries []HistoryEntry) []HistoryEntry {
+		return append(entries, entry)
+	})
+}
+
+// setHistoryRows fills in the row count of a query once counting its rows
+// finished
+func setHistoryRows(dataLake string, ranAt time.Time, rows int64) {
+	updateHistory(func(entries []HistoryEntry) []HistoryEntry {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].Lake == dataLake && entries[i].RanAt.Equal(ranAt) {
+				entries[i].Rows = rows
+				break
+			}
+		}
+		return entries
+	})
+}
+
+// historyScreen searches the query history. Typing fuzzy searches the
+// queries; the chosen query is recalled into the editor or run again.
+type historyScreen struct {
+	dataLake string
+	entries  []HistoryEntry
+	// matches indexes entries, best match or newest first
+	matches  []int
+	search   string
+	allLakes bool
+	selected int
+	top      int
+	err      error
+}
+
+func newHistoryScreen(dataLake string) *historyScreen {
+	s := &historyScreen{dataLake: dataLake}
+	s.entries, s.err = loadHistory()
+	s.filter()
+	return s
+}
+
+// filter lists the entries of the lake, or of all lakes, that match the
+// search
+func (s *historyScreen) filter() {
+	var candidates []int
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if s.allLakes || s.entries[i].Lake == s.dataLake {
+			candidates = append(candidates, i)
+		}
+	}
+	s.matches = candidates
+	if s.search != "" {
+		queries := make([]string, len(candidates))
+		for i, e := range candidates {
+			queries[i] = s.entries[e].Query
+		}
+		s.matches = nil
+		for _, m := range fuzzy.Find(s.search, queries) {
+			s.matches = append(s.matches, candidates[m.Index])
+		}
+	}
+	s.selected, s.top = 0, 0
+}
+
+// Update handles a key press. It returns the query to recall into the
+// editor, whether to run it and whether the screen should close.
+func (s *historyScreen) Update(msg tea.KeyMsg) (query string, run bool, closed bool) {
+	switch msg.String() {
+	case "esc":
+		return "", false, true
+	case "up":
+		if s.selected > 0 {
+			s.selected--
+		}
+	case "down":
+		if s.selected < len(s.matches)-1 {
+			s.selected++
+		}
+	case "enter", "ctrl+e":
+		if len(s.matches) == 0 {
+			return "", false, false
+		}
+		return s.entries[s.matches[s.selected]].Query, msg.String() == "ctrl+e", true
+	case "ctrl+a":
+		s.allLakes = !s.allLakes
+		s.filter()
+	case "backspace":
+		if len(s.search) > 0 {
+			s.search = s.search[:len(s.search)-1]
+			s.filter()
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			s.search += string(msg.Runes)
+			s.filter()
+		}
+	}
+	if s.selected < s.top {
+		s.top = s.selected
+	}
+	if s.selected >= s.top+historyPageSize {
+		s.top = s.selected - historyPageSize + 1
+	}
+	return "", false, false
+}
+
+func (s *historyScreen) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	scope := "data lake " + s.dataLake
+	if s.allLakes {
+		scope = "all data lakes"
+	}
+	out := titleStyle.Render("Query history of "+scope) + "\n"
+	if s.err != nil {
+		return out + fmt.Sprintf("\nError reading query history: %v\n", s.err) + footerStyle.Render("\nPress 'esc' to return")
+	}
+	out += fmt.Sprintf("Search: %s\n\n", s.search)
+	if len(s.matches) == 0 {
+		out += "No queries found.\n"
+	}
+
+	end := min(s.top+historyPageSize, len(s.matches))
+	for row := s.top; row < end; row++ {
+		e := s.entries[s.matches[row]]
+		rows := ""
+		if e.Rows >= 0 && e.Status == historySucceeded {
+			rows = fmt.Sprintf("%d rows", e.Rows)
+		}
+		line := fmt.Sprintf("%s  %-12s %8s %12s  %s", formatTime(e.RanAt), truncate(e.Lake, 12), e.Duration.Round(time.Millisecond), rows, truncate(cellText(strings.TrimSpace(e.Query)), 60))
+		if e.Status != historySucceeded {
+			line += " (" + e.Status + ")"
+		}
+		switch {
+		case row == s.selected:
+			out += selectedStyle.Render("> "+line) + "\n"
+		case e.Status != historySucceeded:
+			out += "  " + errorStyle.Render(line) + "\n"
+		default:
+			out += "  " + line + "\n"
+		}
+	}
+	if len(s.matches) > 0 {
+		if e := s.entries[s.matches[s.selected]]; e.Error != "" {
+			out += "\n" + errorStyle.Render("Error: "+truncate(cellText(e.Error), 110)) + "\n"
+		}
+	}
+
+	out += footerStyle.Render(fmt.Sprintf("\n%d of %d queries. Type to search, press 'enter' to recall the query into the editor, 'ctrl+e' to run it again, 'ctrl+a' to show all data lakes, 'esc' to return", len(s.matches), len(s.entries)))
+	return out
+}
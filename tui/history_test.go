@@ -0,0 +1,54 @@
+package tui
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendHistoryRecoversDamagedFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	first := HistoryEntry{Lake: "test", Query: "SELECT 1", RanAt: time.Now().Add(-time.Minute), Rows: 1, Status: historySucceeded}
+	second := HistoryEntry{Lake: "test", Query: "SELECT 2", RanAt: time.Now().Add(-time.Second), Rows: 1, Status: historySucceeded}
+	data, err := json.Marshal([]HistoryEntry{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := historyPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A write cut short in the middle of the second entry
+	damaged := data[:len(data)-20]
+	if err := os.WriteFile(path, damaged, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadHistory(); err == nil {
+		t.Fatal("read a damaged history without an error")
+	}
+
+	appendHistory(HistoryEntry{Lake: "test", Query: "SELECT 3", RanAt: time.Now(), Rows: -1, Status: historySucceeded})
+	entries, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queries []string
+	for _, entry := range entries {
+		queries = append(queries, entry.Query)
+	}
+	if len(queries) != 2 || queries[0] != "SELECT 1" || queries[1] != "SELECT 3" {
+		t.Errorf("history holds %q, want the recovered and the new query", queries)
+	}
+	kept, err := os.ReadFile(path + ".damaged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(kept) != string(damaged) {
+		t.Error("damaged history was not kept")
+	}
+}
@@ -0,0 +1,502 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sampleRows is how many rows the table view previews
+const sampleRows = 10
+
+// LakeBrowserModel browses the tables of a lake catalog. Opening a table
+// shows its schema, a sample of its rows and the files behind it.
+type LakeBrowserModel struct {
+	lake          *lake.Lake
+	tables        []lake.Table
+	modTimes      map[string]time.Time
+	materialized  map[string]bool
+	selectedTable int
+	selectedFile  int
+	showFiles     bool
+	sample        string
+	err           error
+	width, height int
+	status        string
+	compacting    bool
+	// Editing the compaction schedule
+	showScheduler bool
+	scheduleInput string
+	retention     *retentionScreen
+	profile       *profileScreen
+	lineage       *lineageScreen
+	maintenance   *maintenanceScheduler
+}
+
+func NewLakeBrowser(dataLake string, maintenance *maintenanceScheduler, width, height int) *LakeBrowserModel {
+	b := &LakeBrowserModel{width: width, height: height, maintenance: maintenance}
+	b.lake, b.err = lake.OpenExisting(dataLake)
+	if b.err == nil {
+		b.Refresh()
+	}
+	return b
+}
+
+// Refresh reloads the catalog from disk, keeping the selected table. The
+// table view is left if its table is gone.
+func (b *LakeBrowserModel) Refresh() {
+	selected := b.selectedName()
+	b.tables, b.err = b.lake.Tables()
+	b.selectedTable = 0
+	found := false
+	for i, t := range b.tables {
+		if t.Name == selected {
+			b.selectedTable = i
+			found = true
+		}
+	}
+	if b.showFiles && !found {
+		b.showFiles = false
+		b.status = fmt.Sprintf("Table %s no longer exists", selected)
+	}
+	if b.showFiles && b.selectedFile >= len(b.tables[b.selectedTable].Files) {
+		b.selectedFile = 0
+	}
+	settings, err := b.lake.LoadSettings()
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.materialized = make(map[string]bool)
+	for _, name := range settings.Materialized {
+		b.materialized[name] = true
+	}
+	b.modTimes = make(map[string]time.Time)
+	for _, t := range b.tables {
+		for _, f := range t.Files {
+			if info, err := os.Stat(b.lake.Path(f)); err == nil {
+				b.modTimes[f.Path] = info.ModTime()
+			}
+		}
+	}
+}
+
+// selectedName returns the name of the selected table, or "" if there are
+// no tables
+func (b *LakeBrowserModel) selectedName() string {
+	if b.selectedTable < len(b.tables) {
+		return b.tables[b.selectedTable].Name
+	}
+	return ""
+}
+
+type sampleRowsMsg struct {
+	table  string
+	result string
+	err    error
+}
+
+// sampleRowsCmd fetches the first rows of a table through its query view
+func sampleRowsCmd(dataLake, table string) tea.Cmd {
+	return func() tea.Msg {
+		cursor, err := openQuery(context.Background(), dataLake, fmt.Sprintf("SELECT * FROM %s LIMIT %d", tableRef(table), sampleRows))
+		if err != nil {
+			return sampleRowsMsg{table: table, err: err}
+		}
+		defer cursor.Close()
+		rows, err := cursor.Fetch(sampleRows)
+		if err != nil {
+			return sampleRowsMsg{table: table, err: err}
+		}
+		return sampleRowsMsg{table: table, result: renderSample(cursor.columns, rows)}
+	}
+}
+
+// renderSample lays out sample rows in columns like the result grid
+func renderSample(columns []string, rows [][]string) string {
+	widths := make([]int, len(columns))
+	for i, c := range columns {
+		widths[i] = max(min(len([]rune(c)), maxColumnWidth), 1)
+	}
+	for _, row := range rows {
+		for i, v := range row {
+			widths[i] = max(widths[i], min(len([]rune(cellText(v))), maxColumnWidth))
+		}
+	}
+	line := func(cells []string) string {
+		padded := make([]string, len(cells))
+		for i, v := range cells {
+			padded[i] = padCell(v, widths[i])
+		}
+		return strings.Join(padded, " │ ")
+	}
+	lines := []string{line(columns)}
+	for _, row := range rows {
+		lines = append(lines, line(row))
+	}
+	if len(rows) == 0 {
+		lines = append(lines, "No rows")
+	}
+	return strings.Join(lines, "\n")
+}
+
+type lakeDBRefreshedMsg struct {
+	status string
+	err    error
+}
+
+// refreshLakeDBCmd brings the lake database up to date with the catalog
+func refreshLakeDBCmd(l *lake.Lake, status string) tea.Cmd {
+	return func() tea.Msg {
+		_, err := l.DB(context.Background())
+		return lakeDBRefreshedMsg{status: status, err: err}
+	}
+}
+
+// toggleMaterialized switches a table between a view and a table stored in
+// the lake database
+func (b *LakeBrowserModel) toggleMaterialized(table string) tea.Cmd {
+	settings, err := b.lake.LoadSettings()
+	if err != nil {
+		b.status = fmt.Sprintf("Error reading settings: %v", err)
+		return nil
+	}
+	var materialized []string
+	for _, name := range settings.Materialized {
+		if name != table {
+			materialized = append(materialized, name)
+		}
+	}
+	status := fmt.Sprintf("%s is now a view over the lake files", table)
+	if !b.materialized[table] {
+		materialized = append(materialized, table)
+		status = fmt.Sprintf("%s is now materialized in the lake database", table)
+	}
+	settings.Materialized = materialized
+	if err := b.lake.SaveSettings(settings); err != nil {
+		b.status = fmt.Sprintf("Error saving settings: %v", err)
+		return nil
+	}
+	b.Refresh()
+	b.status = fmt.Sprintf("Refreshing the lake database for %s...", table)
+	return refreshLakeDBCmd(b.lake, status)
+}
+
+// openQueryEditorMsg asks the model to open the query editor on a lake with
+// the query already typed in.
+type openQueryEditorMsg struct {
+	dataLake string
+	query    string
+	// saved is the saved query being opened, if any
+	saved *lake.SavedQuery
+	run   bool
+}
+
+var plainIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// tableRef returns how a table is written in SQL, quoting it only when needed
+func tableRef(table string) string {
+	if plainIdent.MatchString(table) {
+		return table
+	}
+	return lake.QuoteIdent(table)
+}
+
+// inSubView reports whether the file list, the scheduler, the retention
+// screen, a table profile or the lineage view is open
+func (b *LakeBrowserModel) inSubView() bool {
+	return b.showFiles || b.showScheduler || b.retention != nil || b.profile != nil || b.lineage != nil
+}
+
+func (b *LakeBrowserModel) Update(msg tea.Msg) (*LakeBrowserModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.width = msg.Width
+		b.height = msg.Height
+	case compactionDoneMsg:
+		b.compacting = false
+		var lines []string
+		for _, report := range msg.reports {
+			lines = append(lines, fmt.Sprintf("Compacted %s: %d files (%s) -> %d files (%s)", report.Table,
+				report.FilesBefore, formatBytes(report.BytesBefore), report.FilesAfter, formatBytes(report.BytesAfter)))
+		}
+		if msg.err != nil {
+			lines = append(lines, fmt.Sprintf("Compaction failed: %v", msg.err))
+		}
+		b.status = strings.Join(lines, "\n")
+		b.Refresh()
+	case retentionDoneMsg:
+		if b.retention != nil {
+			b.retention.Update(msg)
+		}
+		b.Refresh()
+	case lakeDBRefreshedMsg:
+		if msg.err != nil {
+			b.status = fmt.Sprintf("Error: %v", msg.err)
+		} else {
+			b.status = msg.status
+		}
+	case profileDoneMsg:
+		if b.profile != nil {
+			b.profile.Update(msg)
+		}
+	case sampleRowsMsg:
+		if b.showFiles && b.selectedName() == msg.table {
+			if msg.err != nil {
+				b.sample = fmt.Sprintf("Error reading sample rows: %v", msg.err)
+			} else {
+				b.sample = msg.result
+			}
+		}
+	case tea.KeyMsg:
+		if b.lineage != nil {
+			if b.lineage.Update(msg) {
+				b.lineage = nil
+			}
+			return b, nil
+		}
+		if b.profile != nil {
+			closed, cmd := b.profile.Update(msg)
+			if closed {
+				b.profile = nil
+			}
+			return b, cmd
+		}
+		if b.retention != nil {
+			closed, cmd := b.retention.Update(msg)
+			if closed {
+				b.retention = nil
+				b.Refresh()
+			}
+			return b, cmd
+		}
+		if b.showScheduler {
+			b.updateScheduler(msg)
+			return b, nil
+		}
+		switch msg.String() {
+		case "up":
+			if b.showFiles {
+				if b.selectedFile > 0 {
+					b.selectedFile--
+				}
+			} else if b.selectedTable > 0 {
+				b.selectedTable--
+			}
+		case "down":
+			if b.showFiles {
+				if b.selectedFile < len(b.tables[b.selectedTable].Files)-1 {
+					b.selectedFile++
+				}
+			} else if b.selectedTable < len(b.tables)-1 {
+				b.selectedTable++
+			}
+		case "enter":
+			if !b.showFiles && len(b.tables) > 0 {
+				b.showFiles = true
+				b.selectedFile = 0
+				b.sample = "Loading sample rows..."
+				return b, sampleRowsCmd(b.lake.Name, b.tables[b.selectedTable].Name)
+			}
+		case "e":
+			if len(b.tables) > 0 {
+				query := fmt.Sprintf("SELECT * FROM %s LIMIT 100", tableRef(b.tables[b.selectedTable].Name))
+				return b, func() tea.Msg {
+					return openQueryEditorMsg{dataLake: b.lake.Name, query: query}
+				}
+			}
+		case "p":
+			if len(b.tables) > 0 {
+				var cmd tea.Cmd
+				b.profile, cmd = newProfileScreen(b.lake, b.tables[b.selectedTable].Name)
+				return b, cmd
+			}
+		case "g":
+			if len(b.tables) > 0 {
+				b.lineage = newLineageScreen(b.lake.Name, lake.Node{Kind: lake.NodeTable, Name: b.tables[b.selectedTable].Name})
+			}
+		case "esc":
+			b.showFiles = false
+		case "r":
+			b.Refresh()
+		case "c", "C":
+			if b.showFiles || b.compacting || len(b.tables) == 0 {
+				return b, nil
+			}
+			table := b.tables[b.selectedTable].Name
+			b.status = fmt.Sprintf("Compacting %s...", table)
+			if msg.String() == "C" {
+				// Compact every table of the lake
+				table = ""
+				b.status = "Compacting every table..."
+			}
+			b.compacting = true
+			return b, compactCmd(b.lake, table)
+		case "m":
+			if !b.showFiles && len(b.tables) > 0 {
+				return b, b.toggleMaterialized(b.tables[b.selectedTable].Name)
+			}
+		case "k":
+			if !b.showFiles {
+				table := ""
+				if len(b.tables) > 0 {
+					table = b.tables[b.selectedTable].Name
+				}
+				b.retention = newRetentionScreen(b.lake, table, b.maintenance)
+			}
+		case "s":
+			if !b.showFiles {
+				settings, err := b.lake.LoadSettings()
+				if err != nil {
+					b.status = fmt.Sprintf("Error reading settings: %v", err)
+					return b, nil
+				}
+				b.showScheduler = true
+				b.scheduleInput = settings.Compaction.Schedule
+			}
+		}
+	}
+	return b, nil
+}
+
+func (b *LakeBrowserModel) updateScheduler(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "esc":
+		b.showScheduler = false
+	case "enter":
+		b.showScheduler = false
+		settings, err := b.lake.LoadSettings()
+		if err == nil {
+			settings.Compaction.Schedule = strings.TrimSpace(b.scheduleInput)
+			err = b.lake.SaveSettings(settings)
+		}
+		if err != nil {
+			b.status = fmt.Sprintf("Error saving schedule: %v", err)
+			return
+		}
+		b.maintenance.Reload()
+		if settings.Compaction.Schedule == "" {
+			b.status = "Scheduled compaction disabled"
+		} else {
+			b.status = fmt.Sprintf("Compaction scheduled, next run %s", getScheduleDisplay(settings.Compaction.Schedule))
+		}
+	case "backspace":
+		if len(b.scheduleInput) > 0 {
+			b.scheduleInput = b.scheduleInput[:len(b.scheduleInput)-1]
+		}
+	case "space", " ":
+		b.scheduleInput += " "
+	default:
+		if len(msg.String()) == 1 {
+			b.scheduleInput += msg.String()
+		}
+	}
+}
+
+func (b *LakeBrowserModel) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	separator := footerStyle.Render(strings.Repeat("─", b.width))
+
+	if b.err != nil {
+		return fmt.Sprintf("Error reading catalog: %v\n\nPress 'q' to return.", b.err)
+	}
+
+	if b.lineage != nil {
+		return b.lineage.View()
+	}
+	if b.profile != nil {
+		return b.profile.View()
+	}
+	if b.retention != nil {
+		return b.retention.View()
+	}
+	if b.showScheduler {
+		return titleStyle.Render(fmt.Sprintf("Compaction schedule for data lake: %s", b.lake.Name)) + "\n\n" +
+			"Enter cron expression (e.g., '0 3 * * *' for every day at 3am), or leave empty to disable:\n" +
+			fmt.Sprintf("> %s\n\n", b.scheduleInput) +
+			footerStyle.Render("Press 'enter' to confirm or 'esc' to cancel")
+	}
+
+	s := ""
+	if !b.showFiles {
+		s += titleStyle.Render(fmt.Sprintf("Catalog for data lake: %s", b.lake.Name)) + "\n\n"
+		if len(b.tables) == 0 {
+			return s + "No tables have been landed in this lake yet.\n\nPress 'q' to return."
+		}
+		s += headerStyle.Render(fmt.Sprintf("  %-30s %-13s %-7s %8s %12s %10s  %s", "TABLE", "MODE", "STORED", "FILES", "ROWS", "SIZE", "LAST LANDED")) + "\n"
+		s += separator + "\n"
+		for i, t := range b.tables {
+			last := t.Files[len(t.Files)-1]
+			stored := "view"
+			if b.materialized[t.Name] {
+				stored = "table"
+			}
+			line := fmt.Sprintf("%-30s %-13s %-7s %8d %12d %10s  %s",
+				t.Name, t.Meta.Mode, stored, len(t.Files), t.Rows(), formatBytes(t.Bytes()), formatTime(last.LandedAt))
+			if i == b.selectedTable {
+				s += selectedStyle.Render("> "+line) + "\n"
+			} else {
+				s += "  " + line + "\n"
+			}
+		}
+		if b.status != "" {
+			s += "\n" + b.status + "\n"
+		}
+		s += footerStyle.Render("\nPress 'enter' to view a table, 'e' to query it, 'p' to profile it, 'g' for its lineage, 'm' to materialize it, 'c' to compact the table, 'C' to compact every table, 's' to schedule compaction, 'k' for retention, 'r' to refresh, 'q' to return")
+		return s
+	}
+
+	t := b.tables[b.selectedTable]
+	s += titleStyle.Render(fmt.Sprintf("Table: %s", t.Name)) + "\n\n"
+	s += headerStyle.Render("Schema") + "\n"
+	for _, c := range t.Schema() {
+		s += fmt.Sprintf("  %-30s %s\n", c.Name, c.Type)
+	}
+	s += "\n"
+	s += headerStyle.Render(fmt.Sprintf("Sample rows (first %d)", sampleRows)) + "\n"
+	s += b.sample + "\n\n"
+	s += headerStyle.Render(fmt.Sprintf("  %-60s %-20s %-22s %10s %10s  %-20s %s", "PATH", "PIPELINE", "RUN", "ROWS", "SIZE", "MODIFIED", "CHECKSUM")) + "\n"
+	s += separator + "\n"
+	for i, f := range t.Files {
+		pipeline := f.Pipeline
+		if pipeline == "" {
+			pipeline = "-"
+		}
+		checksum := f.Checksum
+		if len(checksum) > 12 {
+			checksum = checksum[:12]
+		}
+		line := fmt.Sprintf("%-60s %-20s %-22s %10d %10s  %-20s %s",
+			f.Path, pipeline, f.RunID, f.Rows, formatBytes(f.Bytes), formatTime(b.modTimes[f.Path]), checksum)
+		if i == b.selectedFile {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			s += "  " + line + "\n"
+		}
+	}
+	s += footerStyle.Render("\nPress 'e' to query this table, 'p' to profile it, 'g' for its lineage, 'esc' to return to the table list")
+	return s
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
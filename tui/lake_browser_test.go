@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// writeTestTables lands a one-row table for each name
+func writeTestTables(t *testing.T, l *lake.Lake, names ...string) {
+	t.Helper()
+	w, err := l.NewWriter("test", lake.NewRunID(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := w.WriteRows(name, []string{"id"}, [][]string{{"1"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dropTestTable removes a table from the manifest of a lake
+func dropTestTable(t *testing.T, l *lake.Lake, table string) {
+	t.Helper()
+	m, err := l.LoadManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []lake.FileEntry
+	for _, f := range m.Files {
+		if f.Table != table {
+			files = append(files, f)
+		}
+	}
+	m.Files = files
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lake.WriteFileAtomic(filepath.Join(l.Dir, "_manifest.json"), data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLakeBrowserRefreshKeepsSelectedTable(t *testing.T) {
+	l, err := lake.OpenExisting(newTestLake(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestTables(t, l, "a", "b", "c")
+	b := NewLakeBrowser(l.Name, nil, 120, 40)
+	down := tea.KeyMsg{Type: tea.KeyDown}
+
+	b.Update(down)
+	b.Update(down)
+	b.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if !b.showFiles || b.selectedName() != "c" {
+		t.Fatalf("opened table %q, want c", b.selectedName())
+	}
+
+	dropTestTable(t, l, "a")
+	b.Refresh()
+	if !b.showFiles || b.selectedName() != "c" {
+		t.Errorf("after another table was dropped the browser shows %q, want c", b.selectedName())
+	}
+
+	dropTestTable(t, l, "c")
+	b.Refresh()
+	if b.showFiles {
+		t.Error("the table view stayed open after its table was dropped")
+	}
+
+	b.Update(down)
+	b.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if err := os.WriteFile(filepath.Join(l.Dir, "_manifest.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b.Refresh()
+	if b.showFiles || len(b.tables) != 0 {
+		t.Fatal("the table view stayed open after the catalog could not be read")
+	}
+	b.Update(sampleRowsMsg{table: "b"})
+	b.Update(down)
+	_ = b.View()
+}
@@ -0,0 +1,262 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// lakeScanInterval is how often the open data lake selector checks the disk
+// for lakes created, renamed or deleted outside the TUI
+const lakeScanInterval = 2 * time.Second
+
+type lakesScannedMsg struct {
+	lakes []string
+	// usage and saved hold the disk usage and saved query count of the
+	// lakes measured by the scan; the others keep their cached values
+	usage map[string]int64
+	saved map[string]int
+	err   error
+	// watch is the selector visit that asked for the scan, 0 for a single
+	// scan. The periodic scan only continues while that visit lasts.
+	watch int
+}
+
+type lakeActionDoneMsg struct {
+	status string
+	err    error
+}
+
+// scanLakes lists the lakes on disk and measures those not in measured.
+// Lakes are opened without creating them, so a lake deleted or renamed
+// after being listed is not brought back.
+func scanLakes(measured map[string]bool, watch int) tea.Msg {
+	lakes, err := lake.List()
+	if err != nil {
+		return lakesScannedMsg{err: err, watch: watch}
+	}
+	usage := make(map[string]int64)
+	saved := make(map[string]int)
+	for _, name := range lakes {
+		if measured[name] {
+			continue
+		}
+		l, err := lake.OpenExisting(name)
+		if err != nil {
+			continue
+		}
+		if size, err := l.DiskUsage(); err == nil {
+			usage[name] = size
+		}
+		if queries, err := l.SavedQueries(); err == nil {
+			saved[name] = len(queries)
+		}
+	}
+	return lakesScannedMsg{lakes: lakes, usage: usage, saved: saved, watch: watch}
+}
+
+// scanLakesCmd reads and measures every lake on disk once
+func scanLakesCmd(watch int) tea.Cmd {
+	return func() tea.Msg {
+		return scanLakes(nil, watch)
+	}
+}
+
+// watchLakesCmd reads the lakes on disk after lakeScanInterval, measuring
+// only the lakes that appeared since the last scan
+func watchLakesCmd(measured map[string]bool, watch int) tea.Cmd {
+	return tea.Tick(lakeScanInterval, func(time.Time) tea.Msg {
+		return scanLakes(measured, watch)
+	})
+}
+
+// showLakeSelect opens the data lake selector, measures every lake afresh
+// and watches the disk for changes while the selector stays open
+func (m *Model) showLakeSelect() tea.Cmd {
+	m.inDataLakeSelect = true
+	m.lakeWatch++
+	return scanLakesCmd(m.lakeWatch)
+}
+
+// measuredLakes returns the lakes whose disk usage is cached
+func (m Model) measuredLakes() map[string]bool {
+	measured := make(map[string]bool, len(m.lakeUsage))
+	for name := range m.lakeUsage {
+		measured[name] = true
+	}
+	return measured
+}
+
+// setDataLakes replaces the lake list, keeping the same lake selected and
+// the cached measurements of lakes the scan did not measure
+func (m *Model) setDataLakes(lakes []string, usage map[string]int64, saved map[string]int) {
+	selected := ""
+	if m.selectedDataLake < len(m.dataLakes) {
+		selected = m.dataLakes[m.selectedDataLake]
+	}
+	lakeUsage := make(map[string]int64, len(lakes))
+	lakeSavedQueries := make(map[string]int, len(lakes))
+	for _, name := range lakes {
+		if size, ok := usage[name]; ok {
+			lakeUsage[name] = size
+			lakeSavedQueries[name] = saved[name]
+		} else if size, ok := m.lakeUsage[name]; ok {
+			lakeUsage[name] = size
+			lakeSavedQueries[name] = m.lakeSavedQueries[name]
+		}
+	}
+	m.dataLakes = lakes
+	m.lakeUsage = lakeUsage
+	m.lakeSavedQueries = lakeSavedQueries
+	m.selectedDataLake = 0
+	for i, name := range lakes {
+		if name == selected {
+			m.selectedDataLake = i
+		}
+	}
+	m.cancelMissingTarget()
+}
+
+// cancelMissingTarget cancels a rename or delete whose lake no longer exists
+// and reports whether it did
+func (m *Model) cancelMissingTarget() bool {
+	if m.lakeAction != "rename" && m.lakeAction != "delete" {
+		return false
+	}
+	for _, name := range m.dataLakes {
+		if name == m.lakeTarget {
+			return false
+		}
+	}
+	m.lakeStatus = fmt.Sprintf("Cancelled: data lake %s no longer exists", m.lakeTarget)
+	m.lakeAction = ""
+	m.lakeInput = ""
+	m.lakeTarget = ""
+	return true
+}
+
+// updateLakeAction handles keys while creating, renaming or deleting a lake
+func (m Model) updateLakeAction(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.lakeAction == "delete" {
+		switch msg.String() {
+		case "y", "Y":
+			if m.cancelMissingTarget() {
+				return m, nil
+			}
+			name := m.lakeTarget
+			m.lakeAction = ""
+			m.lakeTarget = ""
+			m.lakeStatus = fmt.Sprintf("Deleting %s...", name)
+			return m, lakeActionCmd(func() (string, error) {
+				return fmt.Sprintf("Deleted data lake %s", name), lake.Delete(name)
+			})
+		case "n", "N", "esc":
+			m.lakeAction = ""
+			m.lakeTarget = ""
+			m.lakeStatus = ""
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "esc":
+		m.lakeAction = ""
+		m.lakeTarget = ""
+		m.lakeStatus = ""
+	case "enter":
+		if m.cancelMissingTarget() {
+			return m, nil
+		}
+		name := strings.TrimSpace(m.lakeInput)
+		action := m.lakeAction
+		oldName := m.lakeTarget
+		m.lakeAction = ""
+		m.lakeInput = ""
+		m.lakeTarget = ""
+		if action == "create" {
+			return m, lakeActionCmd(func() (string, error) {
+				_, err := lake.Create(name)
+				return fmt.Sprintf("Created data lake %s", name), err
+			})
+		}
+		return m, lakeActionCmd(func() (string, error) {
+			return fmt.Sprintf("Renamed data lake %s to %s", oldName, name), lake.Rename(oldName, name)
+		})
+	case "backspace":
+		if len(m.lakeInput) > 0 {
+			m.lakeInput = m.lakeInput[:len(m.lakeInput)-1]
+		}
+	default:
+		if len(msg.String()) == 1 {
+			m.lakeInput += msg.String()
+		}
+	}
+	return m, nil
+}
+
+func lakeActionCmd(action func() (string, error)) tea.Cmd {
+	return func() tea.Msg {
+		status, err := action()
+		return lakeActionDoneMsg{status: status, err: err}
+	}
+}
+
+// lakeSelectView renders the data lake selector and lake management prompts
+func (m Model) lakeSelectView() string {
+	unselectedLineStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF"))
+	selectedLineStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	if m.savedQueries != nil {
+		return m.savedQueries.View()
+	}
+
+	s := selectedLineStyle.Render("Select a Data Lake:\n")
+	s += "\n"
+	if len(m.dataLakes) == 0 {
+		s += "No data lakes yet. Press 'n' to create one.\n"
+	}
+	for i, name := range m.dataLakes {
+		cursor := "  "
+		lineStyle := unselectedLineStyle
+		if m.selectedDataLake == i {
+			cursor = "> "
+			lineStyle = selectedLineStyle
+		}
+		usage := "-"
+		if size, ok := m.lakeUsage[name]; ok {
+			usage = formatBytes(size)
+		}
+		saved := ""
+		if n := m.lakeSavedQueries[name]; n > 0 {
+			saved = fmt.Sprintf("%d saved queries", n)
+		}
+		line := fmt.Sprintf("%s%-30s %10s  %s", cursor, name, usage, saved)
+		s += lineStyle.Render(line) + "\n"
+	}
+
+	switch m.lakeAction {
+	case "create":
+		s += fmt.Sprintf("\nName of the new data lake:\n> %s\n", m.lakeInput)
+		return s + footerStyle.Render("\nPress 'enter' to create or 'esc' to cancel")
+	case "rename":
+		s += fmt.Sprintf("\nRename %s to:\n> %s\n", m.lakeTarget, m.lakeInput)
+		return s + footerStyle.Render("\nPress 'enter' to rename or 'esc' to cancel")
+	case "delete":
+		name := m.lakeTarget
+		s += fmt.Sprintf("\nDelete data lake %s and every file in it (%s)? This cannot be undone. (y/n)\n",
+			name, formatBytes(m.lakeUsage[name]))
+		return s
+	}
+
+	if m.lakeStatus != "" {
+		s += "\n" + m.lakeStatus + "\n"
+	}
+	s += "\nUse Up/Down arrows to navigate, 'Enter' to open the query editor, 'b' to browse tables and files, 's' for saved queries."
+	s += "\nPress 'n' to create a lake, 'r' to rename it, 'd' to delete it."
+	return s
+}
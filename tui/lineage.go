@@ -0,0 +1,180 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// lineageScreen navigates the lineage graph of a lake. It lists the direct
+// upstream and downstream neighbours of the focused node; opening one moves
+// the focus to it.
+type lineageScreen struct {
+	lake     *lake.Lake
+	graph    *lake.Lineage
+	focus    lake.Node
+	history  []lake.Node
+	upstream []lake.Node
+	// downstream follows upstream in the selectable list
+	downstream []lake.Node
+	// edgeTimes holds when the edge to each neighbour was last seen and
+	// summary the nodes around the focused one. Both are worked out when
+	// the focus changes rather than on every render.
+	edgeTimes map[lake.Node]time.Time
+	summary   []string
+	selected  int
+	err       error
+}
+
+func newLineageScreen(dataLake string, focus lake.Node) *lineageScreen {
+	s := &lineageScreen{focus: focus}
+	s.lake, s.err = lake.OpenExisting(dataLake)
+	if s.err == nil {
+		s.refresh()
+	}
+	return s
+}
+
+// refresh reloads the graph and the neighbours of the focused node
+func (s *lineageScreen) refresh() {
+	s.graph, s.err = s.lake.Lineage()
+	if s.err != nil {
+		return
+	}
+	s.upstream = s.graph.Upstream(s.focus)
+	s.downstream = s.graph.Downstream(s.focus)
+	s.edgeTimes = make(map[lake.Node]time.Time)
+	for _, n := range s.upstream {
+		edge, _ := s.graph.Edge(n, s.focus)
+		s.edgeTimes[n] = edge.At
+	}
+	for _, n := range s.downstream {
+		edge, _ := s.graph.Edge(s.focus, n)
+		s.edgeTimes[n] = edge.At
+	}
+
+	// Summarize the whole graph around the node
+	s.summary = nil
+	for _, kind := range []lake.NodeKind{lake.NodePipeline, lake.NodeRun} {
+		if nodes := s.graph.Ancestors(s.focus, kind); len(nodes) > 0 {
+			s.summary = append(s.summary, fmt.Sprintf("Produced by %d %s(s): %s", len(nodes), kind, nodeNames(nodes, 5)))
+		}
+	}
+	for _, kind := range []lake.NodeKind{lake.NodeTable, lake.NodeDerived, lake.NodeQuery} {
+		if nodes := s.graph.Descendants(s.focus, kind); len(nodes) > 0 {
+			s.summary = append(s.summary, fmt.Sprintf("Feeds %d %s(s): %s", len(nodes), kind, nodeNames(nodes, 5)))
+		}
+	}
+	if s.selected >= len(s.upstream)+len(s.downstream) {
+		s.selected = 0
+	}
+}
+
+func (s *lineageScreen) selectedNode() (lake.Node, bool) {
+	if s.selected < len(s.upstream) {
+		return s.upstream[s.selected], true
+	}
+	if i := s.selected - len(s.upstream); i < len(s.downstream) {
+		return s.downstream[i], true
+	}
+	return lake.Node{}, false
+}
+
+// Update handles a key press and reports whether the screen should close.
+func (s *lineageScreen) Update(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "esc", "q":
+		return true
+	case "up":
+		if s.selected > 0 {
+			s.selected--
+		}
+	case "down":
+		if s.selected < len(s.upstream)+len(s.downstream)-1 {
+			s.selected++
+		}
+	case "enter":
+		if n, ok := s.selectedNode(); ok && s.err == nil {
+			s.history = append(s.history, s.focus)
+			s.focus = n
+			s.selected = 0
+			s.refresh()
+		}
+	case "backspace":
+		if len(s.history) > 0 {
+			s.focus = s.history[len(s.history)-1]
+			s.history = s.history[:len(s.history)-1]
+			s.selected = 0
+			s.refresh()
+		}
+	case "r":
+		s.refresh()
+	}
+	return false
+}
+
+func (s *lineageScreen) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	out := titleStyle.Render(fmt.Sprintf("Lineage of %s %s", s.focus.Kind, truncate(s.focus.Name, 80))) + "\n\n"
+	if s.err != nil {
+		return out + fmt.Sprintf("Error reading lineage: %v\n", s.err) + footerStyle.Render("\nPress 'esc' to return")
+	}
+	if !s.graph.Live(s.focus) {
+		out += "No longer in the lake: dropped by compaction or retention.\n\n"
+	}
+
+	// The summary of the graph around the node comes before its neighbours
+	for _, line := range s.summary {
+		out += line + "\n"
+	}
+	out += "\n"
+
+	row := 0
+	section := func(title string, nodes []lake.Node) {
+		out += headerStyle.Render(fmt.Sprintf("%s (%d)", title, len(nodes))) + "\n"
+		if len(nodes) == 0 {
+			out += "  none\n"
+		}
+		for _, n := range nodes {
+			name := truncate(n.Name, 70)
+			if !s.graph.Live(n) {
+				name += " (gone)"
+			}
+			line := fmt.Sprintf("%-9s %-78s %s", n.Kind, name, formatTime(s.edgeTimes[n]))
+			if row == s.selected {
+				out += selectedStyle.Render("> "+line) + "\n"
+			} else {
+				out += "  " + line + "\n"
+			}
+			row++
+		}
+		out += "\n"
+	}
+	section("Upstream", s.upstream)
+	section("Downstream", s.downstream)
+
+	out += footerStyle.Render("Press 'enter' to follow the selected node, 'backspace' to go back, 'r' to refresh, 'esc' to return")
+	return out
+}
+
+// nodeNames lists up to limit node names
+func nodeNames(nodes []lake.Node, limit int) string {
+	s := ""
+	for i, n := range nodes {
+		if i == limit {
+			return s + fmt.Sprintf(", and %d more", len(nodes)-limit)
+		}
+		if i > 0 {
+			s += ", "
+		}
+		s += truncate(n.Name, 40)
+	}
+	return s
+}
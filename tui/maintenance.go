@@ -0,0 +1,125 @@
+package tui
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/robfig/cron/v3"
+)
+
+// maintenanceScheduler runs the background maintenance jobs configured in
+// each lake's settings.
+type maintenanceScheduler struct {
+	mu   sync.Mutex
+	cron *cron.Cron
+}
+
+func newMaintenanceScheduler() *maintenanceScheduler {
+	s := &maintenanceScheduler{cron: cron.New(cron.WithSeconds())}
+	s.Reload()
+	return s
+}
+
+// Reload reschedules every job from the current lake settings.
+func (s *maintenanceScheduler) Reload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cron.Stop()
+	s.cron = cron.New(cron.WithSeconds())
+
+	lakes, err := lake.List()
+	if err != nil {
+		return
+	}
+	for _, name := range lakes {
+		l, err := lake.OpenExisting(name)
+		if err != nil {
+			continue
+		}
+		settings, err := l.LoadSettings()
+		if err != nil {
+			l.LogMaintenance("skipping scheduled jobs: %v", err)
+			continue
+		}
+		if settings.Compaction.Schedule != "" {
+			_, err := s.cron.AddFunc(cronSpec(settings.Compaction.Schedule), func() {
+				if _, err := l.Compact(settings.Compaction); err != nil {
+					l.LogMaintenance("scheduled compaction failed: %v", err)
+				}
+			})
+			if err != nil {
+				l.LogMaintenance("invalid compaction schedule %q: %v", settings.Compaction.Schedule, err)
+			}
+		}
+		if settings.Retention.Schedule != "" {
+			_, err := s.cron.AddFunc(cronSpec(settings.Retention.Schedule), func() {
+				if err := enforceRetention(l); err != nil {
+					l.LogMaintenance("scheduled retention failed: %v", err)
+				}
+			})
+			if err != nil {
+				l.LogMaintenance("invalid retention schedule %q: %v", settings.Retention.Schedule, err)
+			}
+		}
+	}
+	s.cron.Start()
+}
+
+// cronSpec adds the seconds field to a standard five-field expression
+func cronSpec(expr string) string {
+	if len(strings.Fields(expr)) == 5 {
+		return "0 " + expr
+	}
+	return expr
+}
+
+type compactionDoneMsg struct {
+	reports []lake.CompactionReport
+	err     error
+}
+
+// compactCmd compacts one table of a lake, or every table when table is empty.
+func compactCmd(l *lake.Lake, table string) tea.Cmd {
+	return func() tea.Msg {
+		settings, err := l.LoadSettings()
+		if err != nil {
+			return compactionDoneMsg{err: err}
+		}
+		if table == "" {
+			reports, err := l.Compact(settings.Compaction)
+			return compactionDoneMsg{reports: reports, err: err}
+		}
+		report, err := l.CompactTable(table, settings.Compaction)
+		return compactionDoneMsg{reports: []lake.CompactionReport{report}, err: err}
+	}
+}
+
+// enforceRetention deletes every snapshot the lake's current retention
+// settings no longer keep. Settings are re-read so edits made after the job
+// was scheduled apply.
+func enforceRetention(l *lake.Lake) error {
+	settings, err := l.LoadSettings()
+	if err != nil {
+		return err
+	}
+	plans, err := l.PlanRetention(settings.Retention, time.Now())
+	if err != nil {
+		return err
+	}
+	return l.ApplyRetention(plans)
+}
+
+type retentionDoneMsg struct {
+	err error
+}
+
+// retentionCmd applies the plans the user confirmed in the preview.
+func retentionCmd(l *lake.Lake, plans []lake.RetentionPlan) tea.Cmd {
+	return func() tea.Msg {
+		return retentionDoneMsg{err: l.ApplyRetention(plans)}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -17,6 +18,7 @@ type Model struct {
 	selectedService   int
 	dataTypes         []string
 	selectedDataType  int
+	primaryKey        []string
 	cursorPosition    int
 	inputs            []string
 	currentScreen     string
@@ -26,11 +28,18 @@ type Model struct {
 	scriptOutput      string
 	scriptCancel      context.CancelFunc
 	dataLakes         []string
+	lakeUsage         map[string]int64
+	lakeSavedQueries  map[string]int
+	lakeWatch         int    // counts visits to the lake selector
+	lakeAction        string // "create", "rename" or "delete" while managing lakes
+	lakeInput         string
+	lakeTarget        string // the lake being renamed or deleted
+	lakeStatus        string
 	selectedDataLake  int
 	inDataLakeSelect  bool
+	savedQueries      *savedQueriesScreen
 	inQueryEditor     bool
 	queryInput        string
-	queryResult       string
 	width             int
 	height            int
 	queryEditor       *QueryEditor
@@ -39,8 +48,14 @@ type Model struct {
 	customServiceName string
 	pipelinesModel    *PipelinesModel
 	inPipelinesTab    bool
+	lakeBrowser       *LakeBrowserModel
+	inLakeBrowser     bool
+	maintenance       *maintenanceScheduler
 }
 
+// loadModes matches the data loading types offered by the creation wizard
+var loadModes = []lake.LoadMode{lake.FullRefresh, lake.Append, lake.Upsert}
+
 //Add connection to the API
 
 func InitialModel() Model {
@@ -56,7 +71,7 @@ func InitialModel() Model {
 
 		state:            "welcome",
 		services:         []string{"Salesforce", "Monday", "HubSpot", "Bring Your Own Data"},
-		dataTypes:        []string{"All Data", "Batch"},
+		dataTypes:        []string{"Full Refresh", "Append", "Upsert"},
 		selectedService:  0,
 		selectedDataType: 0,
 		cursorPosition:   0,
@@ -68,9 +83,10 @@ func InitialModel() Model {
 		inQueryEditor:    false,
 		textInput:        newTextInput(),
 		pipelinesModel:   pipelinesModel,
+		maintenance:      newMaintenanceScheduler(),
 	}
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(m.textInput.Init(), createDataLakeFolder())
+	return tea.Batch(m.textInput.Init(), createDataLakeFolder(), adoptLegacyFilesCmd(), scanLakesCmd(0))
 }
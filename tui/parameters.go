@@ -0,0 +1,124 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// parameterForm asks for the values of a query's named parameters before it
+// runs. The values last entered on the lake are filled in.
+type parameterForm struct {
+	names  []string
+	values []string
+	focus  int
+	// run runs the query once the values are entered
+	run func(values map[string]string) tea.Cmd
+}
+
+func newParameterForm(names []string, last map[string]string, run func(map[string]string) tea.Cmd) *parameterForm {
+	f := &parameterForm{names: names, values: make([]string, len(names)), run: run}
+	for i, name := range names {
+		f.values[i] = last[name]
+	}
+	return f
+}
+
+// Update handles a key press and reports whether the form was submitted or
+// cancelled.
+func (f *parameterForm) Update(msg tea.KeyMsg) (submitted, cancelled bool) {
+	switch msg.String() {
+	case "esc":
+		return false, true
+	case "tab", "down":
+		f.focus = (f.focus + 1) % len(f.values)
+	case "shift+tab", "up":
+		f.focus = (f.focus + len(f.values) - 1) % len(f.values)
+	case "enter":
+		return true, false
+	case "backspace":
+		if v := f.values[f.focus]; len(v) > 0 {
+			f.values[f.focus] = v[:len(v)-1]
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			f.values[f.focus] += string(msg.Runes)
+		}
+	}
+	return false, false
+}
+
+// Values returns the entered value of every parameter by name
+func (f *parameterForm) Values() map[string]string {
+	values := make(map[string]string, len(f.names))
+	for i, name := range f.names {
+		values[name] = f.values[i]
+	}
+	return values
+}
+
+func (f *parameterForm) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	out := titleStyle.Render("Query parameters") + "\n\n"
+	for i, name := range f.names {
+		line := fmt.Sprintf(":%s\n> %s", name, f.values[i])
+		if i == f.focus {
+			line = selectedStyle.Render(line)
+		}
+		out += line + "\n\n"
+	}
+	return out + footerStyle.Render("Numbers, true and false, dates such as 2024-01-31 and NULL are bound as such; quote a value to bind it as text.\n"+
+		"Press 'tab' to move between parameters, 'enter' to run the query or 'esc' to cancel")
+}
+
+// withParameters runs a query of the editor right away, or once the values
+// of its named parameters are entered
+func (qe *QueryEditor) withParameters(query string, run func(values map[string]string) tea.Cmd) tea.Cmd {
+	names := lake.QueryParameters(query)
+	if len(names) == 0 {
+		return run(nil)
+	}
+	var last map[string]string
+	if l, err := lake.OpenExisting(qe.dataLake); err == nil {
+		if settings, err := l.LoadSettings(); err == nil {
+			last = settings.Parameters
+		}
+	}
+	qe.params = newParameterForm(names, last, run)
+	return nil
+}
+
+// updateParameterForm handles keys while asking for parameter values. The
+// values are remembered for the lake's next query.
+func (qe *QueryEditor) updateParameterForm(msg tea.KeyMsg) tea.Cmd {
+	submitted, cancelled := qe.params.Update(msg)
+	if cancelled {
+		qe.params = nil
+	}
+	if !submitted {
+		return nil
+	}
+	form := qe.params
+	qe.params = nil
+	values := form.Values()
+	cmd := form.run(values)
+	if l, err := lake.OpenExisting(qe.dataLake); err == nil {
+		if settings, err := l.LoadSettings(); err == nil {
+			if settings.Parameters == nil {
+				settings.Parameters = make(map[string]string)
+			}
+			for name, value := range values {
+				settings.Parameters[name] = value
+			}
+			if err := l.SaveSettings(settings); err != nil {
+				qe.status = fmt.Sprintf("Error saving parameter values: %v", err)
+			}
+		}
+	}
+	return cmd
+}
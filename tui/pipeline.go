@@ -1,15 +1,16 @@
 package tui
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -19,22 +20,99 @@ import (
 )
 
 type Pipeline struct {
-	ID             int          `json:"id"`
-	Name           string       `json:"name"`
-	Status         string       `json:"status"`
-	LastRun        time.Time    `json:"last_run"`
-	NextRun        time.Time    `json:"next_run"`
-	Healthy        bool         `json:"healthy"`
-	Running        bool         `json:"running"`
-	Logs           []string     `json:"logs"`
-	CronExpr       string       `json:"cron_expr"`
-	CronID         cron.EntryID `json:"-"`
-	cron           *cron.Cron   `json:"-"`
-	animation      []string     `json:"animation"`
-	animIndex      int          `json:"anim_index"`
-	ScriptPath     string       `json:"script_path"`
-	ScriptType     string       `json:"script_type"`
-	LastScriptPath string       `json:"last_script_path"`
+	ID             int           `json:"id"`
+	Name           string        `json:"name"`
+	Status         string        `json:"status"`
+	LastRun        time.Time     `json:"last_run"`
+	NextRun        time.Time     `json:"next_run"`
+	Healthy        bool          `json:"healthy"`
+	Running        bool          `json:"running"`
+	Logs           []string      `json:"logs"`
+	CronExpr       string        `json:"cron_expr"`
+	CronID         cron.EntryID  `json:"-"`
+	cron           *cron.Cron    `json:"-"`
+	animation      []string      `json:"animation"`
+	animIndex      int           `json:"anim_index"`
+	ScriptPath     string        `json:"script_path"`
+	ScriptType     string        `json:"script_type"`
+	LastScriptPath string        `json:"last_script_path"`
+	DriftPolicy    string        `json:"drift_policy"`
+	LoadMode       string        `json:"load_mode"`
+	PrimaryKey     []string      `json:"primary_key"`
+	Checks         []lake.Check  `json:"checks,omitempty"`
+	HealthReason   string        `json:"health_reason,omitempty"`
+	Runs           []PipelineRun `json:"runs"`
+}
+
+// PipelineRun records the outcome of a single pipeline execution
+type PipelineRun struct {
+	RunID    string             `json:"run_id"`
+	Started  time.Time          `json:"started"`
+	Finished time.Time          `json:"finished"`
+	Status   string             `json:"status"`
+	Rows     int64              `json:"rows"`
+	Drift    []lake.DriftEvent  `json:"drift,omitempty"`
+	Checks   []lake.CheckResult `json:"checks,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// Only the most recent runs are kept in pipelines.json
+const maxPipelineRuns = 50
+
+func (r PipelineRun) finish(err error) PipelineRun {
+	r.Finished = time.Now()
+	if err != nil {
+		r.Status = "Failed"
+		r.Error = err.Error()
+	} else {
+		r.Status = "Completed"
+	}
+	return r
+}
+
+// health reports whether the run leaves its pipeline healthy, and if not why
+func (r PipelineRun) health() (bool, string) {
+	if r.Error != "" {
+		return false, "run failed: " + r.Error
+	}
+	var failed []string
+	for _, result := range r.Checks {
+		if !result.Passed {
+			failed = append(failed, result.String())
+		}
+	}
+	if len(failed) > 0 {
+		return false, "check failed: " + strings.Join(failed, "; ")
+	}
+	return true, ""
+}
+
+// checksPassed counts the checks of the run that passed
+func (r PipelineRun) checksPassed() int {
+	passed := 0
+	for _, result := range r.Checks {
+		if result.Passed {
+			passed++
+		}
+	}
+	return passed
+}
+
+// loadMode returns how the pipeline's runs combine in the lake. Pipelines
+// created before load modes existed keep appending.
+func (p Pipeline) loadMode() lake.LoadMode {
+	if p.LoadMode == "" {
+		return lake.Append
+	}
+	return lake.LoadMode(p.LoadMode)
+}
+
+// driftPolicy returns the pipeline's schema drift policy, warning by default
+func (p Pipeline) driftPolicy() lake.DriftPolicy {
+	if p.DriftPolicy == "" {
+		return lake.DriftWarn
+	}
+	return lake.DriftPolicy(p.DriftPolicy)
 }
 
 type PipelineStorage struct {
@@ -137,6 +215,9 @@ type PipelinesModel struct {
 	selectedIndex   int
 	showScheduler   bool
 	showLogs        bool
+	showDetail      bool
+	checksEditor    *checksEditor
+	lineage         *lineageScreen
 	scheduleInput   string
 	animationTicker *time.Ticker
 	healthTicker    *time.Ticker
@@ -144,6 +225,12 @@ type PipelinesModel struct {
 	nextID          int
 }
 
+// inSubView reports whether logs, the scheduler, the detail screen or one of
+// its lineage and checks screens is open
+func (m *PipelinesModel) inSubView() bool {
+	return m.showLogs || m.showScheduler || m.showDetail || m.checksEditor != nil || m.lineage != nil
+}
+
 func (m *PipelinesModel) SetSize(width, height int) {
 	m.width = width
 	m.height = height
@@ -348,7 +435,8 @@ func (m *PipelinesModel) startHealthChecks() {
 
 func (m *PipelinesModel) checkPipelinesHealth() {
 	for i, p := range m.pipelines {
-		healthy := checkPipelineAPI(p)
+		// A pipeline stays unhealthy until a run succeeds and passes its checks
+		healthy := checkPipelineAPI(p) && p.HealthReason == ""
 		m.pipelines[i].Healthy = healthy
 	}
 	// Save after health check updates
@@ -364,10 +452,27 @@ func (m *PipelinesModel) Update(msg tea.Msg) (*PipelinesModel, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.lineage != nil {
+			if m.lineage.Update(msg) {
+				m.lineage = nil
+			}
+			return m, nil
+		}
+		if m.checksEditor != nil {
+			checks, done, cmd := m.checksEditor.Update(msg)
+			if done {
+				m.checksEditor = nil
+				if checks != nil {
+					m.pipelines[m.list.Index()].Checks = checks
+					m.SavePipelines()
+				}
+			}
+			return m, cmd
+		}
 		switch msg.String() {
 		case "q":
 			// Only quit the entire app if we're in the main pipeline view
-			if !m.showLogs && !m.showScheduler {
+			if !m.inSubView() {
 				m.SavePipelines()
 				return m, nil
 			}
@@ -383,7 +488,7 @@ func (m *PipelinesModel) Update(msg tea.Msg) (*PipelinesModel, tea.Cmd) {
 				}
 			}
 		case "d":
-			if len(m.pipelines) > 0 && !m.showLogs && !m.showScheduler {
+			if len(m.pipelines) > 0 && !m.inSubView() {
 				selectedIndex := m.list.Index()
 				// Remove from cron if scheduled
 				if m.pipelines[selectedIndex].CronID != 0 {
@@ -402,22 +507,44 @@ func (m *PipelinesModel) Update(msg tea.Msg) (*PipelinesModel, tea.Cmd) {
 				}
 			}
 		case "l":
-			if !m.showLogs && !m.showScheduler && len(m.pipelines) > 0 {
+			if !m.inSubView() && len(m.pipelines) > 0 {
 				selectedPipeline := m.pipelines[m.list.Index()]
 				logsContent := formatLogs(selectedPipeline.Logs)
 				m.logsViewport.SetContent(logsContent)
 				m.showLogs = true
 			}
+		case "i":
+			if !m.inSubView() && len(m.pipelines) > 0 {
+				m.showDetail = true
+			}
+		case "p":
+			if m.showDetail {
+				m.cycleDriftPolicy(m.list.Index())
+			}
+		case "g":
+			if m.showDetail {
+				p := m.pipelines[m.list.Index()]
+				m.lineage = newLineageScreen(lakeForScript(p.ScriptType), lake.Node{Kind: lake.NodePipeline, Name: p.Name})
+				// The list would take "g" as go to start and lose the selection
+				return m, nil
+			}
+		case "x":
+			if m.showDetail {
+				m.checksEditor = newChecksEditor(m.pipelines[m.list.Index()], m.width, m.height)
+				return m, m.checksEditor.textarea.Cursor.BlinkCmd()
+			}
 		case "esc":
 			if m.showLogs {
 				m.showLogs = false
+			} else if m.showDetail {
+				m.showDetail = false
 			} else if m.showScheduler {
 				m.showScheduler = false
 				m.scheduleInput = ""
 			}
 			return m, nil
 		case "s":
-			if !m.showLogs && !m.showScheduler {
+			if !m.inSubView() {
 				m.showScheduler = true
 			}
 		case "enter":
@@ -446,7 +573,9 @@ func (m *PipelinesModel) Update(msg tea.Msg) (*PipelinesModel, tea.Cmd) {
 		return m, nil
 	}
 
-	if !m.showLogs {
+	// Keys belong to the open sub-view; letting the list see them would move
+	// the selection away from the pipeline the sub-view acts on
+	if _, isKey := msg.(tea.KeyMsg); !isKey || !m.inSubView() {
 		var cmd tea.Cmd
 		m.list, cmd = m.list.Update(msg)
 		if cmd != nil {
@@ -478,6 +607,15 @@ func (m *PipelinesModel) View() string {
 	if m.showScheduler {
 		return m.renderScheduler()
 	}
+	if m.checksEditor != nil {
+		return m.checksEditor.View()
+	}
+	if m.lineage != nil {
+		return m.lineage.View()
+	}
+	if m.showDetail {
+		return m.renderDetailView()
+	}
 
 	nameWidth := 20
 	statusWidth := 15
@@ -514,7 +652,7 @@ func (m *PipelinesModel) View() string {
 
 	footer := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("8")).
-		Render("\nPress 'r' to run pipeline, 'l' for logs, 's' to schedule, 'q' to quit")
+		Render("\nPress 'r' to run pipeline, 'l' for logs, 'i' for details, 's' to schedule, 'q' to quit")
 
 	mainStyle := lipgloss.NewStyle().
 		MaxHeight(m.height).
@@ -614,29 +752,21 @@ func (m *PipelinesModel) executePipeline(index int) (string, error) {
 	m.pipelines[index].Status = "Running"
 	m.SavePipelines()
 
-	var cmd *exec.Cmd
-	if pipeline.ScriptType == "byod" {
-		cmd = exec.Command("python3", "/Users/brettfloyd/pipeterm/utils/byod.py", pipeline.ScriptPath)
-	} else {
-		cmd = exec.Command("python3", "/Users/brettfloyd/pipeterm/utils/salesforce.py")
-	}
-
-	output, err := cmd.CombinedOutput()
+	run, output, err := runPipelineScript(context.Background(), pipeline)
 
 	// Update pipeline status based on execution result
 	m.pipelines[index].Running = false
 	m.pipelines[index].LastRun = time.Now()
+	m.recordRun(index, run)
 
 	if err != nil {
 		m.pipelines[index].Status = "Failed"
-		m.pipelines[index].Healthy = false
 		m.pipelines[index].Logs = append(m.pipelines[index].Logs,
 			fmt.Sprintf("[%s] Pipeline execution failed: %v",
 				time.Now().Format("2006-01-02 15:04:05"),
 				err))
 	} else {
 		m.pipelines[index].Status = "Completed"
-		m.pipelines[index].Healthy = true
 		m.pipelines[index].Logs = append(m.pipelines[index].Logs,
 			fmt.Sprintf("[%s] Pipeline executed successfully",
 				time.Now().Format("2006-01-02 15:04:05")))
@@ -645,7 +775,135 @@ func (m *PipelinesModel) executePipeline(index int) (string, error) {
 	// Save updated pipeline state
 	m.SavePipelines()
 
-	return string(output), err
+	return output, err
+}
+
+// recordRun stores a finished run on the pipeline, updates its health from
+// the run's outcome and checks, and logs any schema drift
+func (m *PipelinesModel) recordRun(index int, run PipelineRun) {
+	p := &m.pipelines[index]
+	p.Runs = append(p.Runs, run)
+	if len(p.Runs) > maxPipelineRuns {
+		p.Runs = p.Runs[len(p.Runs)-maxPipelineRuns:]
+	}
+
+	p.Healthy, p.HealthReason = run.health()
+	for _, result := range run.Checks {
+		if !result.Passed {
+			p.Logs = append(p.Logs,
+				fmt.Sprintf("[%s] Check failed: %s",
+					time.Now().Format("2006-01-02 15:04:05"),
+					result))
+		}
+	}
+
+	if p.driftPolicy() == lake.DriftWarn {
+		for _, event := range run.Drift {
+			p.Logs = append(p.Logs,
+				fmt.Sprintf("[%s] Schema drift warning: %s",
+					time.Now().Format("2006-01-02 15:04:05"),
+					event))
+		}
+	}
+}
+
+// cycleDriftPolicy moves the pipeline on to the next schema drift policy
+func (m *PipelinesModel) cycleDriftPolicy(index int) {
+	current := m.pipelines[index].driftPolicy()
+	next := lake.DriftPolicies[0]
+	for i, policy := range lake.DriftPolicies {
+		if policy == current {
+			next = lake.DriftPolicies[(i+1)%len(lake.DriftPolicies)]
+			break
+		}
+	}
+	m.pipelines[index].DriftPolicy = string(next)
+	m.SavePipelines()
+}
+
+func (m *PipelinesModel) renderDetailView() string {
+	if len(m.pipelines) == 0 {
+		return "No pipelines available."
+	}
+
+	p := m.pipelines[m.list.Index()]
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("5"))
+	headerStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("12"))
+	footerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("8"))
+
+	s := titleStyle.Render(fmt.Sprintf("Pipeline: %s", p.Name)) + "\n\n"
+	s += fmt.Sprintf("Script:        %s %s\n", p.ScriptType, p.ScriptPath)
+	s += fmt.Sprintf("Data lake:     %s\n", lakeForScript(p.ScriptType))
+	s += fmt.Sprintf("Schedule:      %s\n", getScheduleDisplay(p.CronExpr))
+	s += fmt.Sprintf("Load mode:     %s\n", p.loadMode())
+	if len(p.PrimaryKey) > 0 {
+		s += fmt.Sprintf("Primary key:   %s\n", strings.Join(p.PrimaryKey, ", "))
+	}
+	s += fmt.Sprintf("Drift policy:  %s\n", p.driftPolicy())
+	if p.HealthReason != "" {
+		s += lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render("Unhealthy:     "+p.HealthReason) + "\n"
+	}
+	s += "\n"
+
+	s += headerStyle.Render("Checks") + "\n"
+	if len(p.Checks) == 0 {
+		s += "No checks configured.\n"
+	}
+	var lastResults []lake.CheckResult
+	if len(p.Runs) > 0 {
+		lastResults = p.Runs[len(p.Runs)-1].Checks
+	}
+	for _, c := range p.Checks {
+		outcome := "not run yet"
+		for _, result := range lastResults {
+			if result.Check.String() == c.String() {
+				outcome = "passed"
+				if !result.Passed {
+					outcome = "FAILED: " + result.Detail
+				}
+			}
+		}
+		s += fmt.Sprintf("  %-60s %s\n", c, outcome)
+	}
+	s += "\n"
+
+	s += headerStyle.Render(fmt.Sprintf("%-22s %-20s %-10s %-10s %10s  %-6s %s", "RUN", "STARTED", "DURATION", "STATUS", "ROWS", "DRIFT", "CHECKS")) + "\n"
+	if len(p.Runs) == 0 {
+		s += "No runs recorded yet.\n"
+	}
+	var drift []lake.DriftEvent
+	for i := len(p.Runs) - 1; i >= 0; i-- {
+		run := p.Runs[i]
+		checks := "-"
+		if len(run.Checks) > 0 {
+			checks = fmt.Sprintf("%d/%d passed", run.checksPassed(), len(run.Checks))
+		}
+		s += fmt.Sprintf("%-22s %-20s %-10s %-10s %10d  %-6d %s\n",
+			run.RunID,
+			formatTime(run.Started),
+			run.Finished.Sub(run.Started).Round(time.Second),
+			run.Status,
+			run.Rows,
+			len(run.Drift),
+			checks)
+		drift = append(drift, run.Drift...)
+	}
+
+	s += "\n" + headerStyle.Render("Schema drift history") + "\n"
+	if len(drift) == 0 {
+		s += "No schema changes detected.\n"
+	}
+	for _, event := range drift {
+		s += fmt.Sprintf("[%s] %s  %s\n", event.DetectedAt.Format("2006-01-02 15:04:05"), event.RunID, event)
+	}
+
+	s += footerStyle.Render("\nPress 'p' to change the drift policy (warn, allow, fail), 'x' to edit checks, 'g' for lineage, 'esc' to go back")
+	return lipgloss.NewStyle().MaxHeight(m.height).MaxWidth(m.width).Render(s)
 }
 
 func (m *PipelinesModel) RunPipeline(index int) tea.Cmd {
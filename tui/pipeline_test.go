@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newTestPipelines creates a pipelines model holding n pipelines under a
+// temporary home directory
+func newTestPipelines(t *testing.T, n int) *PipelinesModel {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	m := NewPipelinesModel(120, 40)
+	for i := 0; i < n; i++ {
+		m.AddPipeline(Pipeline{Name: fmt.Sprintf("pipeline%d", i), ScriptType: "byod"})
+	}
+	return m
+}
+
+func pressKeys(m *PipelinesModel, keys ...string) {
+	for _, key := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		switch key {
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		}
+		m.Update(msg)
+	}
+}
+
+func TestLineageKeepsSelectedPipeline(t *testing.T) {
+	m := newTestPipelines(t, 3)
+	m.list.Select(2)
+
+	pressKeys(m, "i", "g")
+	if m.lineage == nil {
+		t.Fatal("'g' did not open the lineage screen")
+	}
+	pressKeys(m, "esc")
+	if m.lineage != nil {
+		t.Fatal("esc did not close the lineage screen")
+	}
+	if got := m.list.Index(); got != 2 {
+		t.Errorf("selected pipeline %d after lineage, want 2", got)
+	}
+}
+
+func TestDetailViewKeepsSelectedPipeline(t *testing.T) {
+	m := newTestPipelines(t, 3)
+	m.list.Select(1)
+
+	pressKeys(m, "i", "down", "j", "p")
+	if got := m.list.Index(); got != 1 {
+		t.Fatalf("selected pipeline %d in the detail view, want 1", got)
+	}
+	for i, p := range m.pipelines {
+		want := ""
+		if i == 1 {
+			want = string(lake.DriftAllow)
+		}
+		if p.DriftPolicy != want {
+			t.Errorf("pipeline %d has drift policy %q, want %q", i, p.DriftPolicy, want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sparkBlocks draw histograms one character per bucket
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// barWidth is the width of the longest bar in the column detail
+const barWidth = 30
+
+// profileScreen shows the column profile of a table: per-column statistics
+// with a small histogram, and the top values of the selected column.
+type profileScreen struct {
+	lake     *lake.Lake
+	table    string
+	profile  lake.Profile
+	loading  bool
+	err      error
+	selected int
+}
+
+type profileDoneMsg struct {
+	table   string
+	profile lake.Profile
+	err     error
+}
+
+// profileCmd computes the profile of a table, reusing the cached profile of
+// its latest snapshot unless recompute is set
+func profileCmd(l *lake.Lake, table string, recompute bool) tea.Cmd {
+	return func() tea.Msg {
+		p, err := l.Profile(context.Background(), table, !recompute)
+		return profileDoneMsg{table: table, profile: p, err: err}
+	}
+}
+
+func newProfileScreen(l *lake.Lake, table string) (*profileScreen, tea.Cmd) {
+	return &profileScreen{lake: l, table: table, loading: true}, profileCmd(l, table, false)
+}
+
+// Update handles a message and reports whether the screen should close.
+func (p *profileScreen) Update(msg tea.Msg) (bool, tea.Cmd) {
+	switch msg := msg.(type) {
+	case profileDoneMsg:
+		if msg.table != p.table {
+			return false, nil
+		}
+		p.loading = false
+		p.profile, p.err = msg.profile, msg.err
+		if p.selected >= len(p.profile.Columns) {
+			p.selected = 0
+		}
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q":
+			return true, nil
+		case "up":
+			if p.selected > 0 {
+				p.selected--
+			}
+		case "down":
+			if p.selected < len(p.profile.Columns)-1 {
+				p.selected++
+			}
+		case "r":
+			if !p.loading {
+				p.loading = true
+				return false, profileCmd(p.lake, p.table, true)
+			}
+		}
+	}
+	return false, nil
+}
+
+func (p *profileScreen) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	s := titleStyle.Render(fmt.Sprintf("Profile of table: %s", p.table)) + "\n\n"
+	if p.loading {
+		return s + "Profiling...\n"
+	}
+	if p.err != nil {
+		return s + fmt.Sprintf("Error profiling table: %v\n", p.err) + footerStyle.Render("\nPress 'r' to retry, 'esc' to return")
+	}
+
+	s += fmt.Sprintf("%d rows as of run %s, profiled %s\n\n", p.profile.Rows, p.profile.RunID, formatTime(p.profile.ProfiledAt))
+	s += headerStyle.Render(fmt.Sprintf("  %-25s %-15s %7s %10s  %-20s %-20s %s", "COLUMN", "TYPE", "NULL %", "DISTINCT", "MIN", "MAX", "HISTOGRAM")) + "\n"
+	for i, c := range p.profile.Columns {
+		line := fmt.Sprintf("%-25s %-15s %6.1f%% %10d  %-20s %-20s %s",
+			truncate(c.Name, 25), truncate(c.Type, 15), c.NullPct, c.Distinct, truncate(c.Min, 20), truncate(c.Max, 20), sparkline(c.Histogram))
+		if i == p.selected {
+			s += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			s += "  " + line + "\n"
+		}
+	}
+
+	if len(p.profile.Columns) > 0 {
+		c := p.profile.Columns[p.selected]
+		s += "\n" + headerStyle.Render(fmt.Sprintf("Top values of %s", c.Name)) + "\n"
+		var topMax int64
+		for _, v := range c.TopValues {
+			topMax = max(topMax, v.Count)
+		}
+		for _, v := range c.TopValues {
+			s += fmt.Sprintf("  %-30s %10d %s\n", truncate(v.Value, 30), v.Count, bar(v.Count, topMax))
+		}
+		if len(c.Histogram) > 0 {
+			s += "\n" + headerStyle.Render(fmt.Sprintf("Distribution of %s", c.Name)) + "\n"
+			var bucketMax int64
+			for _, b := range c.Histogram {
+				bucketMax = max(bucketMax, b.Count)
+			}
+			for _, b := range c.Histogram {
+				s += fmt.Sprintf("  >= %-27s %10d %s\n", truncate(b.Start, 27), b.Count, bar(b.Count, bucketMax))
+			}
+		}
+	}
+	s += footerStyle.Render("\nUse Up/Down arrows to select a column, 'r' to recompute, 'esc' to return")
+	return s
+}
+
+// sparkline draws a histogram as one block character per bucket
+func sparkline(buckets []lake.Bucket) string {
+	var peak int64
+	for _, b := range buckets {
+		peak = max(peak, b.Count)
+	}
+	if peak == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, b := range buckets {
+		level := int(b.Count * int64(len(sparkBlocks)-1) / peak)
+		if b.Count == 0 {
+			sb.WriteRune(' ')
+			continue
+		}
+		sb.WriteRune(sparkBlocks[level])
+	}
+	return sb.String()
+}
+
+// bar draws n as a horizontal bar relative to peak
+func bar(n, peak int64) string {
+	if peak == 0 {
+		return ""
+	}
+	width := int(n * barWidth / peak)
+	if width == 0 && n > 0 {
+		width = 1
+	}
+	return strings.Repeat("█", width)
+}
+
+func truncate(s string, width int) string {
+	if len([]rune(s)) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
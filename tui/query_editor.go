@@ -1,20 +1,96 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
 	"github.com/charmbracelet/bubbles/textarea"
-	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
+// queryTickInterval is how often the elapsed time of a running query is
+// redrawn
+const queryTickInterval = 100 * time.Millisecond
+
 type QueryEditor struct {
 	textarea textarea.Model
-	viewport viewport.Model
 	dataLake string
 	width    int
 	height   int
+	picker   *snapshotPicker
+	// The running query, if any. queryID tells the result of the current
+	// query apart from a late result of a cancelled one.
+	running bool
+	started time.Time
+	queryID int
+	status  string
+	// Cancelling ctx interrupts the running query or, once it finished,
+	// closes its result and stops counting its rows
+	ctx    context.Context
+	cancel context.CancelFunc
+	// The result of the last query: a grid of rows or an error
+	grid        *resultGrid
+	resultErr   string
+	gridFocused bool
+	// errorAt is where in errorQuery, the query from the editor that
+	// failed, DuckDB found the error, if it said
+	errorQuery string
+	errorAt    *errorPosition
+	// The editor's contents when the query ran, and where in them the
+	// query starts if only one statement ran
+	runText   string
+	runOffset int
+	// script holds the results of a script of several statements, tab is
+	// the one shown
+	script *scriptResult
+	tab    int
+	// args are bound to the parameters of baseQuery, params asks for their
+	// values
+	args   []any
+	params *parameterForm
+	// The query the result came from, the sort and filters applied to it
+	// and the arrangement of its columns
+	baseQuery string
+	view      resultView
+	layout    *gridLayout
+	// refinedColumn stays selected in the sorted or filtered result
+	refinedColumn string
+	// Editing the lake's default query timeout
+	editingTimeout bool
+	timeoutInput   string
+	export         *exportScreen
+	// The query history: the panel searching it, and when the current
+	// query ran if it is recorded in the history
+	history      *historyScreen
+	historyQuery string
+	ranAt        time.Time
+	// saved is the saved query open in the editor, if any
+	saved    *lake.SavedQuery
+	saveForm *savedQueryForm
+	// Completion of the word before the cursor from the lake's catalog,
+	// loaded when first needed. completionsGen counts the sources dropped,
+	// so a load started before the drop is ignored.
+	completions        *completionSource
+	completionsGen     int
+	loadingCompletions bool
+	completion         *completionPopup
+	// editorTop is the first row of the query shown
+	editorTop int
+}
+
+type queryTickMsg struct {
+	id int
+}
+
+func queryTickCmd(id int) tea.Cmd {
+	return tea.Tick(queryTickInterval, func(time.Time) tea.Msg {
+		return queryTickMsg{id: id}
+	})
 }
 
 func NewQueryEditor(dataLake string, width, height int) *QueryEditor {
@@ -22,21 +98,39 @@ func NewQueryEditor(dataLake string, width, height int) *QueryEditor {
 	ta.Placeholder = "Enter your SQL query..."
 	ta.Focus()
 	ta.CharLimit = 0 // No character limit
-	ta.SetWidth(width)
-	ta.SetHeight(height - 5)
 	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
 	ta.ShowLineNumbers = true
 	ta.KeyMap.InsertNewline.SetEnabled(true)
-	vp := viewport.New(30, 5)
-	vp.SetContent("Query results will appear here.")
 
-	return &QueryEditor{
+	qe := &QueryEditor{
 		textarea: ta,
-		viewport: vp,
 		dataLake: dataLake,
-		width:    width,
-		height:   height,
 	}
+	qe.setSize(width, height)
+	return qe
+}
+
+// setSize splits the screen between the editor, which gets a third, and the
+// result grid
+func (qe *QueryEditor) setSize(width, height int) {
+	qe.width = width
+	qe.height = height
+	editorHeight := max((height-8)/3, 3)
+	qe.textarea.SetWidth(width)
+	qe.textarea.SetHeight(editorHeight)
+	if qe.grid != nil {
+		qe.grid.SetSize(width, qe.gridHeight())
+	}
+}
+
+// gridHeight leaves room for the action bar, the editor, the status line and
+// the key help below the grid
+func (qe *QueryEditor) gridHeight() int {
+	if qe.script != nil {
+		// The tabs of the script take a line
+		return max(qe.height-qe.textarea.Height()-11, 3)
+	}
+	return max(qe.height-qe.textarea.Height()-10, 3)
 }
 
 func (qe *QueryEditor) Update(msg tea.Msg) (*QueryEditor, tea.Cmd) {
@@ -44,50 +138,733 @@ func (qe *QueryEditor) Update(msg tea.Msg) (*QueryEditor, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		qe.width = msg.Width
-		qe.height = msg.Height
-		qe.viewport.Width = msg.Width
-		qe.viewport.Height = msg.Height - 5
-		qe.textarea.SetWidth(msg.Width)
+		qe.setSize(msg.Width, msg.Height)
+		return qe, nil
+
+	case queryTickMsg:
+		if qe.running && msg.id == qe.queryID {
+			return qe, queryTickCmd(msg.id)
+		}
+		return qe, nil
+
+	case queryResultMsg:
+		return qe, qe.finishQuery(msg)
+
+	case exportTickMsg, exportDoneMsg:
+		if qe.export != nil {
+			return qe, qe.export.Progress(msg)
+		}
+		return qe, nil
+
+	case completionsLoadedMsg:
+		qe.finishLoadingCompletions(msg)
+		return qe, nil
+
+	case queryCountMsg:
+		if qe.grid == nil || msg.id != qe.queryID || qe.grid.total >= 0 {
+			return qe, nil
+		}
+		qe.grid.counting = false
+		if msg.err != nil {
+			qe.grid.countErr = msg.err
+			return qe, nil
+		}
+		qe.grid.total = msg.total
+		if !qe.ranAt.IsZero() {
+			setHistoryRows(qe.dataLake, qe.ranAt, msg.total)
+		}
 		return qe, nil
 
 	case tea.KeyMsg:
+		if qe.editingTimeout {
+			qe.updateTimeoutInput(msg)
+			return qe, nil
+		}
+		if qe.export != nil {
+			closed, cmd := qe.export.Update(msg)
+			if closed {
+				qe.export = nil
+			}
+			return qe, cmd
+		}
+		if qe.saveForm != nil {
+			qe.updateSaveForm(msg)
+			return qe, nil
+		}
+		if qe.params != nil {
+			return qe, qe.updateParameterForm(msg)
+		}
+		if qe.history != nil {
+			query, run, closed := qe.history.Update(msg)
+			if !closed {
+				return qe, nil
+			}
+			qe.history = nil
+			if query == "" {
+				return qe, nil
+			}
+			qe.textarea.SetValue(query)
+			qe.focusGrid(false)
+			if run && !qe.running {
+				return qe, qe.runEditor()
+			}
+			return qe, nil
+		}
+		if qe.picker != nil {
+			insert, done := qe.picker.Update(msg)
+			if done {
+				qe.picker = nil
+				if insert != "" {
+					qe.textarea.InsertString(insert)
+				}
+			}
+			return qe, nil
+		}
+
+		if qe.completion != nil && !qe.gridFocused && qe.updateCompletionPopup(msg) {
+			return qe, nil
+		}
+
 		switch {
+		case msg.Type == tea.KeyCtrlAt:
+			// ctrl+space in most terminals
+			if !qe.gridFocused {
+				return qe, qe.updateCompletion(true)
+			}
+			return qe, nil
+		case msg.Type == tea.KeyCtrlT:
+			qe.picker = newSnapshotPicker(qe.dataLake)
+			return qe, nil
 		case msg.Type == tea.KeyCtrlE:
-			query := qe.textarea.Value()
-			return qe, func() tea.Msg {
-				result, err := executeQuery(qe.dataLake, query)
-				if err != nil {
-					return queryResultMsg{result: "", err: err}
+			if qe.running {
+				return qe, nil
+			}
+			return qe, qe.runEditor()
+		case msg.Type == tea.KeyCtrlG:
+			if qe.running {
+				return qe, nil
+			}
+			return qe, qe.runStatementAtCursor()
+		case (msg.Type == tea.KeyShiftLeft || msg.Type == tea.KeyShiftRight) && qe.script != nil:
+			step := 1
+			if msg.Type == tea.KeyShiftLeft {
+				step = len(qe.script.tabs) - 1
+			}
+			qe.showTab((qe.tab + step) % len(qe.script.tabs))
+			return qe, nil
+		case msg.Type == tea.KeyCtrlL:
+			if strings.TrimSpace(qe.textarea.Value()) != "" {
+				qe.saveForm = newSavedQueryForm(qe.saved)
+			}
+			return qe, nil
+		case msg.Type == tea.KeyCtrlR:
+			qe.history = newHistoryScreen(qe.dataLake)
+			return qe, nil
+		case msg.Type == tea.KeyCtrlX:
+			if qe.running {
+				qe.cancel()
+				qe.status = "Cancelling..."
+			}
+			return qe, nil
+		case msg.Type == tea.KeyCtrlO:
+			if !qe.running {
+				qe.editingTimeout = true
+				qe.timeoutInput = ""
+				if l, err := lake.OpenExisting(qe.dataLake); err == nil {
+					if settings, err := l.LoadSettings(); err == nil {
+						qe.timeoutInput = settings.QueryTimeout
+					}
 				}
-				return queryResultMsg{result: result, err: nil}
 			}
+			return qe, nil
+		case msg.Type == tea.KeyCtrlS:
+			if !qe.running && strings.TrimSpace(qe.textarea.Value()) != "" {
+				// Export the query that ran, with its parameters bound
+				query, args := qe.textarea.Value(), []any(nil)
+				if qe.baseQuery != "" {
+					query, args = qe.baseQuery, qe.args
+				}
+				qe.export = newExportScreen(qe.dataLake, qe.resultQuery(), query, args)
+			}
+			return qe, nil
+		case msg.Type == tea.KeyTab:
+			if qe.grid != nil {
+				qe.focusGrid(!qe.gridFocused)
+			}
+			return qe, nil
+		case qe.gridFocused && !qe.grid.Inspecting() && strings.Contains("sfFc", msg.String()) && len(msg.Runes) == 1:
+			return qe, qe.refine(msg.String())
+		case qe.gridFocused && !qe.grid.Inspecting() && msg.String() == "#":
+			return qe, qe.countResult()
+		case qe.gridFocused:
+			qe.grid.Update(msg)
+			return qe, nil
 		default:
-
 			qe.textarea, cmd = qe.textarea.Update(msg)
+			switch {
+			case isWordKey(msg):
+				cmd = tea.Batch(cmd, qe.updateCompletion(false))
+			case msg.Type == tea.KeyBackspace && qe.completion != nil:
+				cmd = tea.Batch(cmd, qe.updateCompletion(false))
+			default:
+				qe.completion = nil
+			}
 			return qe, cmd
 		}
 
-	case queryResultMsg:
-		if msg.err != nil {
-			qe.viewport.SetContent(fmt.Sprintf("Error: %v", msg.err))
-		} else {
-			qe.viewport.SetContent(msg.result)
-		}
-		return qe, nil
-
 	default:
 		qe.textarea, cmd = qe.textarea.Update(msg)
 		return qe, cmd
 	}
 }
 
+// focusGrid moves the keyboard focus between the editor and the results
+func (qe *QueryEditor) focusGrid(focused bool) {
+	qe.gridFocused = focused
+	if focused {
+		qe.textarea.Blur()
+	} else {
+		qe.textarea.Focus()
+	}
+}
+
+// refine sorts or filters the result by the selected cell and runs the
+// query again. Only a single SELECT can be wrapped in a sort or filter.
+func (qe *QueryEditor) refine(key string) tea.Cmd {
+	if qe.running {
+		return nil
+	}
+	if qe.grid.cursor.countQuery == "" {
+		qe.grid.status = "Only the result of a single SELECT can be sorted or filtered"
+		return nil
+	}
+	i, value, ok := qe.grid.selectedValue()
+	if !ok && key != "c" {
+		return nil
+	}
+	column, dbType := qe.grid.columns[i], qe.grid.cursor.types[i]
+	switch key {
+	case "s":
+		qe.view.sort(column)
+	case "f":
+		qe.view.filter(column, dbType, value, false)
+	case "F":
+		qe.view.filter(column, dbType, value, true)
+	case "c":
+		if qe.view.empty() {
+			return nil
+		}
+		qe.view = resultView{}
+	}
+	qe.refinedColumn = column
+	return qe.run(qe.view.wrap(qe.baseQuery), false)
+}
+
+// updateSaveForm handles keys while saving the query to the lake's library
+func (qe *QueryEditor) updateSaveForm(msg tea.KeyMsg) {
+	submitted, cancelled := qe.saveForm.Update(msg)
+	if cancelled {
+		qe.saveForm = nil
+	}
+	if !submitted {
+		return
+	}
+	l, err := lake.OpenExisting(qe.dataLake)
+	if err != nil {
+		qe.saveForm.err = err.Error()
+		return
+	}
+	q := qe.saveForm.Query(qe.textarea.Value())
+	if err := l.SaveQuery(q, qe.saveForm.previous); err != nil {
+		qe.saveForm.err = err.Error()
+		return
+	}
+	qe.saveForm = nil
+	qe.saved = &q
+	qe.status = fmt.Sprintf("Saved query %s", q.Name)
+}
+
+// runEditor runs the query in the editor as a new result. A script of
+// several statements runs them in order, each with a result tab of its own.
+func (qe *QueryEditor) runEditor() tea.Cmd {
+	text := qe.textarea.Value()
+	return qe.withParameters(text, func(values map[string]string) tea.Cmd {
+		if statements := splitStatements(text); len(statements) > 1 {
+			return qe.runScript(text, statements, values)
+		}
+		return qe.runQuery(text, text, 0, values)
+	})
+}
+
+// runStatementAtCursor runs only the statement of the script under the
+// cursor
+func (qe *QueryEditor) runStatementAtCursor() tea.Cmd {
+	text := qe.textarea.Value()
+	statement, ok := statementAt(splitStatements(text), len(cursorText(qe.textarea)))
+	if !ok {
+		return nil
+	}
+	return qe.withParameters(statement.text, func(values map[string]string) tea.Cmd {
+		return qe.runQuery(statement.text, text, statement.offset, values)
+	})
+}
+
+// runQuery runs a query of the editor, which starts at offset in its text,
+// as a new result. values are bound to its named parameters; the history
+// keeps the query with its parameters so it asks for them again.
+func (qe *QueryEditor) runQuery(query, text string, offset int, values map[string]string) tea.Cmd {
+	qe.baseQuery, qe.args = lake.BindParameters(query, values)
+	qe.view = resultView{}
+	qe.refinedColumn = ""
+	qe.layout = newGridLayout()
+	cmd := qe.run(qe.baseQuery, true)
+	qe.historyQuery = query
+	qe.runText, qe.runOffset = text, offset
+	return cmd
+}
+
+// runScript runs the statements of a script in order
+func (qe *QueryEditor) runScript(text string, statements []scriptStatement, values map[string]string) tea.Cmd {
+	qe.baseQuery, qe.args = "", nil
+	qe.view = resultView{}
+	qe.refinedColumn = ""
+	qe.layout = newGridLayout()
+	dataLake := qe.dataLake
+	cmd := qe.start(text, true, func(ctx context.Context) queryResultMsg {
+		script, err := executeScript(ctx, dataLake, statements, values)
+		return queryResultMsg{script: script, err: err}
+	})
+	qe.runText, qe.runOffset = text, 0
+	return cmd
+}
+
+// resultQuery selects the result in the grid as shown: sorted, filtered and
+// without its hidden columns. It is empty without a result that can be
+// queried again.
+func (qe *QueryEditor) resultQuery() string {
+	if qe.grid == nil || qe.grid.cursor.countQuery == "" {
+		return ""
+	}
+	var columns []string
+	for _, i := range qe.grid.shown() {
+		columns = append(columns, lake.QuoteIdent(qe.grid.columns[i]))
+	}
+	if len(columns) == len(qe.grid.columns) {
+		return qe.view.wrap(qe.baseQuery)
+	}
+	return fmt.Sprintf("SELECT %s FROM (%s\n)", strings.Join(columns, ", "), qe.view.wrap(qe.baseQuery))
+}
+
+// run starts a query under the lake's query timeout. The timeout only
+// covers running the query, not paging through its result afterwards.
+// Lineage and history are recorded for queries the user wrote, not for the
+// sorted and filtered queries the grid runs on their behalf.
+func (qe *QueryEditor) run(query string, fromEditor bool) tea.Cmd {
+	dataLake, args := qe.dataLake, qe.args
+	return qe.start(query, fromEditor, func(ctx context.Context) queryResultMsg {
+		cursor, err := openQuery(ctx, dataLake, query, args...)
+		if err == nil && fromEditor {
+			recordQueryLineage(dataLake, query)
+		}
+		return queryResultMsg{cursor: cursor, err: err}
+	})
+}
+
+// start runs a query or script in the background, closing the last result
+func (qe *QueryEditor) start(query string, fromEditor bool, execute func(ctx context.Context) queryResultMsg) tea.Cmd {
+	timeout := lake.DefaultQueryTimeout
+	if l, err := lake.OpenExisting(qe.dataLake); err == nil {
+		if settings, err := l.LoadSettings(); err == nil {
+			if t, err := settings.Timeout(); err == nil {
+				timeout = t
+			}
+		}
+	}
+	qe.closeResult()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	qe.queryID++
+	qe.running = true
+	qe.started = time.Now()
+	qe.ctx, qe.cancel = ctx, cancel
+	qe.status = ""
+	qe.historyQuery, qe.ranAt = "", time.Time{}
+	if fromEditor {
+		qe.historyQuery = query
+	}
+	id := qe.queryID
+	runQuery := func() tea.Msg {
+		var timer *time.Timer
+		if timeout > 0 {
+			timer = time.AfterFunc(timeout, cancel)
+		}
+		msg := execute(ctx)
+		if timer != nil && !timer.Stop() && msg.err != nil {
+			msg.err = context.DeadlineExceeded
+		}
+		msg.id = id
+		return msg
+	}
+	return tea.Batch(runQuery, queryTickCmd(id))
+}
+
+// finishQuery shows the outcome of a query and starts counting the rows of
+// its result. The result of a query that is no longer the current one is
+// closed unseen.
+func (qe *QueryEditor) finishQuery(msg queryResultMsg) tea.Cmd {
+	if !qe.running || msg.id != qe.queryID {
+		if msg.cursor != nil {
+			msg.cursor.Close()
+		}
+		if msg.script != nil {
+			msg.script.Close()
+		}
+		return nil
+	}
+	elapsed := time.Since(qe.started).Round(time.Millisecond)
+	qe.running = false
+	entry := HistoryEntry{Lake: qe.dataLake, Query: qe.historyQuery, RanAt: qe.started, Duration: elapsed, Rows: -1}
+
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		qe.status = fmt.Sprintf("Query cancelled after %s", elapsed)
+		entry.Status = historyCancelled
+	case errors.Is(msg.err, context.DeadlineExceeded):
+		qe.status = fmt.Sprintf("Query timed out after %s, press 'ctrl+o' to change the timeout", elapsed)
+		entry.Status = historyTimedOut
+	case msg.err != nil:
+		qe.status = fmt.Sprintf("Query failed after %s", elapsed)
+		qe.resultErr = msg.err.Error()
+		entry.Status, entry.Error = historyFailed, msg.err.Error()
+		if entry.Query != "" {
+			if at, ok := queryErrorPosition(qe.resultErr, entry.Query); ok {
+				at = at.in(qe.runText, qe.runOffset)
+				qe.errorQuery, qe.errorAt = qe.runText, &at
+			}
+		}
+	}
+	if msg.err != nil {
+		if entry.Query != "" {
+			appendHistory(entry)
+		}
+		return nil
+	}
+	// The query may have created tables to complete
+	qe.resetCompletions()
+	if msg.script != nil {
+		qe.finishScript(msg.script, elapsed, entry)
+		return nil
+	}
+	qe.status = fmt.Sprintf("Query finished in %s", elapsed)
+	qe.grid = newResultGrid(msg.cursor, qe.layout, qe.width, qe.gridHeight())
+	if entry.Query != "" {
+		// The row count is filled in if the rows are counted
+		entry.Status, entry.Rows = historySucceeded, qe.grid.total
+		appendHistory(entry)
+		qe.ranAt = entry.RanAt
+	}
+	qe.grid.header = qe.view.String()
+	if qe.refinedColumn != "" {
+		// Stay in the grid while sorting and filtering
+		qe.grid.selectColumn(qe.refinedColumn)
+		qe.focusGrid(true)
+	}
+	return nil
+}
+
+// countResult counts the rows of the result in the grid. Counting runs the
+// query again on another connection, so it only happens when asked for.
+func (qe *QueryEditor) countResult() tea.Cmd {
+	g := qe.grid
+	if g.cursor.countQuery == "" || g.total >= 0 || g.counting {
+		return nil
+	}
+	g.counting, g.countErr = true, nil
+	ctx, id, dataLake, countQuery, args := qe.ctx, qe.queryID, qe.dataLake, g.cursor.countQuery, g.cursor.args
+	return func() tea.Msg {
+		total, err := countRows(ctx, dataLake, countQuery, args...)
+		return queryCountMsg{id: id, total: total, err: err}
+	}
+}
+
+// finishScript shows the results of a script, opening on the tab of its
+// last statement or of the statement that failed
+func (qe *QueryEditor) finishScript(script *scriptResult, elapsed time.Duration, entry HistoryEntry) {
+	qe.script = script
+	last := script.tabs[len(script.tabs)-1]
+	qe.status = fmt.Sprintf("Script of %d statements finished in %s", script.statements, elapsed)
+	entry.Status = historySucceeded
+	if last.err != nil {
+		qe.status = fmt.Sprintf("Statement %d of %d failed after %s", len(script.tabs), script.statements, elapsed)
+		entry.Status, entry.Error = historyFailed, last.err.Error()
+		if at, ok := queryErrorPosition(last.err.Error(), last.statement.text); ok {
+			at = at.in(qe.runText, last.statement.offset)
+			qe.errorQuery, qe.errorAt = qe.runText, &at
+		}
+	}
+	appendHistory(entry)
+	qe.showTab(len(script.tabs) - 1)
+}
+
+// showTab shows the result of a statement of the script
+func (qe *QueryEditor) showTab(i int) {
+	qe.tab = i
+	tab := qe.script.tabs[i]
+	qe.resultErr = ""
+	if tab.err != nil {
+		qe.resultErr = tab.err.Error()
+	}
+	if tab.cursor != nil && tab.grid == nil {
+		tab.grid = newResultGrid(tab.cursor, newGridLayout(), qe.width, qe.gridHeight())
+	}
+	qe.grid = tab.grid
+	if qe.grid == nil {
+		qe.focusGrid(false)
+		return
+	}
+	qe.grid.SetSize(qe.width, qe.gridHeight())
+}
+
+// closeResult releases the result of the last query
+func (qe *QueryEditor) closeResult() {
+	if qe.script != nil {
+		// The grids are the script's to close
+		qe.script.Close()
+		qe.script, qe.grid = nil, nil
+	}
+	if qe.grid != nil {
+		qe.grid.Close()
+		qe.grid = nil
+	}
+	if qe.cancel != nil {
+		qe.cancel()
+	}
+	qe.resultErr = ""
+	qe.errorQuery, qe.errorAt = "", nil
+	qe.focusGrid(false)
+}
+
+// Close interrupts the running query, if any, and releases the result of
+// the last one
+func (qe *QueryEditor) Close() {
+	qe.closeResult()
+	if qe.export != nil && qe.export.Running() {
+		qe.export.cancel()
+	}
+}
+
+func (qe *QueryEditor) updateTimeoutInput(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "esc":
+		qe.editingTimeout = false
+	case "enter":
+		input := strings.TrimSpace(qe.timeoutInput)
+		if input != "" {
+			if _, err := lake.ParseTimeout(input); err != nil {
+				qe.status = err.Error()
+				return
+			}
+		}
+		qe.editingTimeout = false
+		l, err := lake.OpenExisting(qe.dataLake)
+		if err != nil {
+			qe.status = fmt.Sprintf("Error saving timeout: %v", err)
+			return
+		}
+		settings, err := l.LoadSettings()
+		if err == nil {
+			settings.QueryTimeout = input
+			err = l.SaveSettings(settings)
+		}
+		if err != nil {
+			qe.status = fmt.Sprintf("Error saving timeout: %v", err)
+			return
+		}
+		timeout, _ := settings.Timeout()
+		if timeout == 0 {
+			qe.status = "Queries on this lake now run without a timeout"
+		} else {
+			qe.status = fmt.Sprintf("Queries on this lake now time out after %s", timeout)
+		}
+	case "backspace":
+		if len(qe.timeoutInput) > 0 {
+			qe.timeoutInput = qe.timeoutInput[:len(qe.timeoutInput)-1]
+		}
+	default:
+		if len(msg.String()) == 1 {
+			qe.timeoutInput += msg.String()
+		}
+	}
+}
+
+// HasOverlay reports whether a popup inside the editor is handling keys,
+// in which case Esc closes the popup rather than the editor.
+func (qe *QueryEditor) HasOverlay() bool {
+	return qe.picker != nil || qe.editingTimeout || qe.export != nil || qe.history != nil || qe.saveForm != nil || qe.params != nil || qe.completion != nil || (qe.grid != nil && qe.grid.Inspecting())
+}
+
+// Help describes the keys of the editor, or of the results while they have
+// the focus
+func (qe *QueryEditor) Help() string {
+	if qe.gridFocused {
+		return "Press 's' to sort by the column, 'f' to keep or 'F' to drop rows with the value, 'c' to clear sort and filters, '<'/'>' to resize and 'x'/'X' to hide or show columns, 'enter' to inspect the value, 'y', 'Y' or 'ctrl+y' to copy the cell, row or column, 'g'/'G' to go to the first or last row, '#' to count the rows by running the query again, 'ctrl+s' to export, 'tab' to return to the query."
+	}
+	return "Press 'ctrl+e' to run, 'ctrl+x' to cancel, 'ctrl+g' to run only the statement under the cursor, 'tab' to move between the query and its results, 'shift+left'/'shift+right' to move between the results of a script, 'ctrl+t' to query a table as of an earlier run, 'ctrl+o' to set the query timeout, 'ctrl+s' to export the result, 'ctrl+r' to search the query history, 'ctrl+l' to save the query, 'ctrl+space' to complete, 'esc' to return to the data lake selection."
+}
+
 func (qe *QueryEditor) View() string {
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	status := qe.status
+	if qe.running {
+		status = fmt.Sprintf("Running... %s, press 'ctrl+x' to cancel", time.Since(qe.started).Round(100*time.Millisecond))
+		if qe.status != "" {
+			status = qe.status
+		}
+	}
+
+	results := "Query results will appear here."
+	switch {
+	case qe.params != nil:
+		results = qe.params.View()
+	case qe.saveForm != nil:
+		title := "Save query to data lake " + qe.dataLake
+		if qe.saved != nil {
+			title = "Save changes to " + qe.saved.Name
+		}
+		results = qe.saveForm.View(title)
+	case qe.history != nil:
+		results = qe.history.View()
+	case qe.export != nil:
+		results = qe.export.View()
+	case qe.picker != nil:
+		results = qe.picker.View()
+	case qe.editingTimeout:
+		results = fmt.Sprintf("Query timeout for data lake %s (e.g. 30s or 10m, 0 for none, empty for the default of %s):\n> %s\n\nPress 'enter' to confirm or 'esc' to cancel",
+			qe.dataLake, lake.DefaultQueryTimeout, qe.timeoutInput)
+	case qe.resultErr != "":
+		title := "Error: "
+		if at, ok := qe.failedAt(); ok {
+			title = fmt.Sprintf("Error at line %d, column %d: ", at.line+1, at.column+1)
+		}
+		results = lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(title + qe.resultErr)
+	case qe.grid != nil:
+		results = qe.grid.View()
+	case qe.script != nil:
+		results = qe.script.tabs[qe.tab].View()
+	}
+	if qe.script != nil && qe.picker == nil && !qe.editingTimeout && qe.export == nil && qe.history == nil && qe.saveForm == nil && qe.params == nil {
+		results = qe.script.tabBar(qe.tab, qe.width) + "\n" + results
+	}
+	if qe.completion != nil {
+		return lipgloss.JoinVertical(lipgloss.Left, qe.editorView(), qe.completion.View(), footerStyle.Render(status), "", results)
+	}
 	return lipgloss.JoinVertical(
 		lipgloss.Left,
-		qe.viewport.View(),
-		qe.textarea.View(),
+		qe.editorView(),
+		footerStyle.Render(status),
+		"",
+		results,
 	)
 }
 
+// recordQueryLineage records which lake tables a query read, or the derived
+// table it created. Lineage is best effort and never fails a query.
+func recordQueryLineage(dataLake, query string) {
+	if l, err := lake.OpenExisting(dataLake); err == nil {
+		l.RecordQuery(query, time.Now())
+	}
+}
+
+// resultView is a sort and filters applied to the result of a query by
+// wrapping it in another query
+type resultView struct {
+	sortColumn string
+	sortDesc   bool
+	// filters are SQL predicates, labels describe them to the user
+	filters []string
+	labels  []string
+}
+
+func (v *resultView) empty() bool {
+	return v.sortColumn == "" && len(v.filters) == 0
+}
+
+// sort cycles the column between ascending, descending and unsorted
+func (v *resultView) sort(column string) {
+	switch {
+	case v.sortColumn != column:
+		v.sortColumn, v.sortDesc = column, false
+	case !v.sortDesc:
+		v.sortDesc = true
+	default:
+		v.sortColumn, v.sortDesc = "", false
+	}
+}
+
+// filter keeps, or with exclude drops, the rows where the column has the
+// value. The value is the one shown in the grid, so it is cast back to the
+// column's type; values of nested types are compared as text.
+func (v *resultView) filter(column, dbType, value string, exclude bool) {
+	ident := lake.QuoteIdent(column)
+	var predicate, label string
+	switch {
+	case value == "NULL":
+		predicate, label = ident+" IS NULL", column+" is NULL"
+		if exclude {
+			predicate, label = ident+" IS NOT NULL", column+" is not NULL"
+		}
+	default:
+		literal := lake.QuoteLiteral(value)
+		if strings.ContainsAny(dbType, "[<") || strings.HasPrefix(dbType, "STRUCT") || strings.HasPrefix(dbType, "MAP") || strings.HasPrefix(dbType, "UNION") {
+			ident = "CAST(" + ident + " AS VARCHAR)"
+		} else if dbType != "" && dbType != "VARCHAR" {
+			literal = "CAST(" + literal + " AS " + dbType + ")"
+		}
+		predicate, label = ident+" = "+literal, column+" = "+truncate(value, 30)
+		if exclude {
+			predicate, label = ident+" IS DISTINCT FROM "+literal, column+" != "+truncate(value, 30)
+		}
+	}
+	v.filters = append(v.filters, predicate)
+	v.labels = append(v.labels, label)
+}
+
+// wrap applies the sort and filters to a query
+func (v *resultView) wrap(query string) string {
+	if v.empty() {
+		return query
+	}
+	query = "SELECT * FROM (" + strings.TrimRight(strings.TrimSpace(query), ";") + "\n)"
+	if len(v.filters) > 0 {
+		query += " WHERE " + strings.Join(v.filters, " AND ")
+	}
+	if v.sortColumn != "" {
+		direction := "ASC"
+		if v.sortDesc {
+			direction = "DESC"
+		}
+		query += " ORDER BY " + lake.QuoteIdent(v.sortColumn) + " " + direction + " NULLS LAST"
+	}
+	return query
+}
+
+func (v *resultView) String() string {
+	var parts []string
+	if v.sortColumn != "" {
+		direction := "ascending"
+		if v.sortDesc {
+			direction = "descending"
+		}
+		parts = append(parts, fmt.Sprintf("Sorted by %s %s", v.sortColumn, direction))
+	}
+	if len(v.labels) > 0 {
+		parts = append(parts, "Filtered to "+strings.Join(v.labels, " and "))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, ", ") + " (press 'c' to clear)"
+}
+
 type exitEditorMsg struct{}
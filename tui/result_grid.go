@@ -0,0 +1,429 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+const (
+	// gridPageSize is how many rows are fetched from the cursor at a time
+	gridPageSize = 200
+	// maxColumnWidth caps the automatic width of a column in the grid
+	maxColumnWidth = 40
+)
+
+// gridLayout is how the columns of a result are arranged. It outlives the
+// grid, so sorting or filtering a result keeps the hidden and resized
+// columns.
+type gridLayout struct {
+	hidden map[string]bool
+	widths map[string]int
+}
+
+func newGridLayout() *gridLayout {
+	return &gridLayout{hidden: make(map[string]bool), widths: make(map[string]int)}
+}
+
+// resultGrid shows the result of a query. Rows are fetched from the open
+// cursor a page at a time as the grid scrolls, so large results never have
+// to be held in memory or rendered at once. The header row stays in place
+// while scrolling.
+type resultGrid struct {
+	cursor  *queryCursor
+	columns []string
+	rows    [][]string
+	widths  []int
+	layout  *gridLayout
+	// total is the number of rows in the result, or -1 while unknown.
+	// counting is set while the rows are being counted and countErr is why
+	// counting them failed.
+	total       int64
+	counting    bool
+	countErr    error
+	selectedRow int
+	// selectedCol and left index the shown columns
+	selectedCol int
+	// top and left are the first visible row and column
+	top, left int
+	// header describes the sort and filters applied to the result
+	header        string
+	width, height int
+	// status reports the outcome of the last action, like a copy
+	status string
+	err    error
+	// inspector shows the full value of the selected cell
+	inspector *viewport.Model
+}
+
+type queryCountMsg struct {
+	id    int
+	total int64
+	err   error
+}
+
+func newResultGrid(cursor *queryCursor, layout *gridLayout, width, height int) *resultGrid {
+	g := &resultGrid{cursor: cursor, columns: cursor.columns, layout: layout, total: -1, width: width, height: height}
+	g.widths = make([]int, len(g.columns))
+	for i, c := range g.columns {
+		g.widths[i] = max(min(len([]rune(c)), maxColumnWidth), 1)
+	}
+	g.fetch()
+	return g
+}
+
+// fetch reads the next page of rows from the cursor
+func (g *resultGrid) fetch() {
+	if g.cursor.done {
+		return
+	}
+	page, err := g.cursor.Fetch(gridPageSize)
+	if err != nil {
+		g.err = err
+	}
+	for _, row := range page {
+		for i, v := range row {
+			g.widths[i] = max(g.widths[i], min(len([]rune(cellText(v))), maxColumnWidth))
+		}
+	}
+	g.rows = append(g.rows, page...)
+	if g.cursor.done {
+		g.total = int64(len(g.rows))
+	}
+}
+
+// fetchAll reads every remaining row of the result
+func (g *resultGrid) fetchAll() {
+	for !g.cursor.done && g.err == nil {
+		g.fetch()
+	}
+}
+
+// Close releases the cursor of the result
+func (g *resultGrid) Close() {
+	g.cursor.Close()
+}
+
+func (g *resultGrid) SetSize(width, height int) {
+	g.width, g.height = width, height
+	g.scrollTo(g.selectedRow)
+}
+
+// shown returns the indexes of the columns that are not hidden
+func (g *resultGrid) shown() []int {
+	var shown []int
+	for i, c := range g.columns {
+		if !g.layout.hidden[c] {
+			shown = append(shown, i)
+		}
+	}
+	return shown
+}
+
+// column returns the index of the selected column
+func (g *resultGrid) column() int {
+	return g.shown()[g.selectedCol]
+}
+
+// selectColumn selects the shown column with the name, if any
+func (g *resultGrid) selectColumn(name string) {
+	for pos, i := range g.shown() {
+		if g.columns[i] == name {
+			g.selectedCol = pos
+		}
+	}
+	for g.left < g.selectedCol && !g.columnVisible(g.selectedCol) {
+		g.left++
+	}
+}
+
+// columnWidth is the width a column is drawn with, as resized by the user
+// or else fitted to its values
+func (g *resultGrid) columnWidth(i int) int {
+	if w, ok := g.layout.widths[g.columns[i]]; ok {
+		return w
+	}
+	return g.widths[i]
+}
+
+// visibleRows is how many rows fit below the header
+func (g *resultGrid) visibleRows() int {
+	// Leave room for the header, its separator and the status line
+	if g.header != "" {
+		return max(g.height-4, 1)
+	}
+	return max(g.height-3, 1)
+}
+
+// scrollTo selects a row, fetching pages until it is loaded
+func (g *resultGrid) scrollTo(row int) {
+	for row >= len(g.rows) && !g.cursor.done && g.err == nil {
+		g.fetch()
+	}
+	g.selectedRow = max(min(row, len(g.rows)-1), 0)
+	if g.selectedRow < g.top {
+		g.top = g.selectedRow
+	}
+	if g.selectedRow >= g.top+g.visibleRows() {
+		g.top = g.selectedRow - g.visibleRows() + 1
+	}
+	// Keep a page ahead of the visible rows loaded
+	if g.top+g.visibleRows() >= len(g.rows) && !g.cursor.done && g.err == nil {
+		g.fetch()
+	}
+}
+
+// Inspecting reports whether the cell inspector is open
+func (g *resultGrid) Inspecting() bool {
+	return g.inspector != nil
+}
+
+// shownValues returns the values of a row in the columns that are not
+// hidden, in the order they are shown
+func (g *resultGrid) shownValues(row int) []string {
+	var values []string
+	for _, i := range g.shown() {
+		values = append(values, g.rows[row][i])
+	}
+	return values
+}
+
+// selectedValue returns the selected column and the value of the selected
+// cell
+func (g *resultGrid) selectedValue() (column int, value string, ok bool) {
+	if len(g.rows) == 0 {
+		return 0, "", false
+	}
+	column = g.column()
+	return column, g.rows[g.selectedRow][column], true
+}
+
+func (g *resultGrid) Update(msg tea.KeyMsg) {
+	if g.inspector != nil {
+		switch msg.String() {
+		case "esc", "enter", "q":
+			g.inspector = nil
+		default:
+			vp, _ := g.inspector.Update(msg)
+			g.inspector = &vp
+		}
+		return
+	}
+
+	g.status = ""
+	shown := len(g.shown())
+	if shown == 0 {
+		return
+	}
+	switch msg.String() {
+	case "up", "k":
+		g.scrollTo(g.selectedRow - 1)
+	case "down", "j":
+		g.scrollTo(g.selectedRow + 1)
+	case "pgup":
+		g.scrollTo(g.selectedRow - g.visibleRows())
+	case "pgdown", " ":
+		g.scrollTo(g.selectedRow + g.visibleRows())
+	case "home", "g":
+		g.scrollTo(0)
+	case "end", "G":
+		// Pages through every remaining row of the result
+		g.fetchAll()
+		g.scrollTo(len(g.rows) - 1)
+	case "left", "h":
+		if g.selectedCol > 0 {
+			g.selectedCol--
+		}
+	case "right", "l":
+		if g.selectedCol < shown-1 {
+			g.selectedCol++
+		}
+	case "<", ">":
+		i := g.column()
+		step := 4
+		if msg.String() == "<" {
+			step = -4
+		}
+		g.layout.widths[g.columns[i]] = max(g.columnWidth(i)+step, 3)
+	case "x":
+		if shown == 1 {
+			g.status = "The last shown column cannot be hidden"
+			break
+		}
+		name := g.columns[g.column()]
+		g.layout.hidden[name] = true
+		g.selectedCol = min(g.selectedCol, shown-2)
+		g.status = fmt.Sprintf("Hid column %s", name)
+	case "X":
+		clear(g.layout.hidden)
+	case "enter":
+		if _, value, ok := g.selectedValue(); ok {
+			g.inspect(value)
+		}
+	case "y":
+		if i, value, ok := g.selectedValue(); ok {
+			g.copy(value, fmt.Sprintf("the value of %s", g.columns[i]))
+		}
+	case "Y":
+		if len(g.rows) > 0 {
+			g.copy(strings.Join(g.shownValues(g.selectedRow), "\t"), fmt.Sprintf("row %d", g.selectedRow+1))
+		}
+	case "ctrl+y":
+		// Copies the column of the whole result, not only the loaded rows
+		g.fetchAll()
+		i := g.column()
+		values := make([]string, len(g.rows))
+		for r, row := range g.rows {
+			values[r] = row[i]
+		}
+		g.copy(strings.Join(values, "\n"), fmt.Sprintf("%d values of %s", len(values), g.columns[i]))
+	}
+	if g.selectedCol < g.left {
+		g.left = g.selectedCol
+	}
+	for g.left < g.selectedCol && !g.columnVisible(g.selectedCol) {
+		g.left++
+	}
+}
+
+// inspect opens the full value of a cell, indented if it is JSON
+func (g *resultGrid) inspect(value string) {
+	var indented bytes.Buffer
+	if json.Indent(&indented, []byte(value), "", "  ") == nil {
+		value = indented.String()
+	}
+	vp := viewport.New(g.width, g.visibleRows())
+	vp.SetContent(lipgloss.NewStyle().Width(g.width).Render(value))
+	g.inspector = &vp
+}
+
+// copy puts text on the clipboard. Without a clipboard tool, as over SSH,
+// the terminal is asked to set its clipboard instead.
+func (g *resultGrid) copy(text, what string) {
+	if err := clipboard.WriteAll(text); err != nil {
+		termenv.Copy(text)
+	}
+	g.status = fmt.Sprintf("Copied %s to the clipboard", what)
+}
+
+// columnVisible reports whether a shown column fits on screen when scrolled
+// to left
+func (g *resultGrid) columnVisible(col int) bool {
+	shown := g.shown()
+	used := g.gutterWidth()
+	for i := g.left; i <= col; i++ {
+		used += g.columnWidth(shown[i]) + 3
+	}
+	return used <= g.width
+}
+
+func (g *resultGrid) gutterWidth() int {
+	return len(fmt.Sprint(max(int64(len(g.rows)), g.total))) + 1
+}
+
+// cellText flattens a value onto a single line
+func cellText(v string) string {
+	return strings.NewReplacer("\n", "⏎", "\r", "", "\t", " ").Replace(v)
+}
+
+func padCell(v string, width int) string {
+	r := []rune(cellText(v))
+	if len(r) > width {
+		return string(r[:width-1]) + "…"
+	}
+	return string(r) + strings.Repeat(" ", width-len(r))
+}
+
+func (g *resultGrid) View() string {
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	cellStyle := lipgloss.NewStyle().Reverse(true)
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	if len(g.columns) == 0 {
+		return "Statement executed."
+	}
+	if g.inspector != nil {
+		i, _, _ := g.selectedValue()
+		return headerStyle.Render(fmt.Sprintf("Row %d, column %s", g.selectedRow+1, g.columns[i])) + "\n" +
+			g.inspector.View() + "\n" +
+			footerStyle.Render("Use Up/Down arrows to scroll, 'esc' to close")
+	}
+
+	// Shown columns from left that fit the width
+	shown := g.shown()
+	last := g.left
+	used := g.gutterWidth()
+	for last < len(shown) && (last == g.left || used+g.columnWidth(shown[last])+3 <= g.width) {
+		used += g.columnWidth(shown[last]) + 3
+		last++
+	}
+
+	gutter := g.gutterWidth()
+	header := strings.Repeat(" ", gutter)
+	for _, i := range shown[g.left:last] {
+		header += " │ " + padCell(g.columns[i], g.columnWidth(i))
+	}
+	var sb strings.Builder
+	if g.header != "" {
+		sb.WriteString(footerStyle.Render(truncate(g.header, g.width)) + "\n")
+	}
+	sb.WriteString(headerStyle.Render(header) + "\n")
+	sb.WriteString(footerStyle.Render(strings.Repeat("─", min(used, g.width))) + "\n")
+
+	end := min(g.top+g.visibleRows(), len(g.rows))
+	for r := g.top; r < end; r++ {
+		line := fmt.Sprintf("%*d", gutter, r+1)
+		if r != g.selectedRow {
+			for _, i := range shown[g.left:last] {
+				line += " │ " + padCell(g.rows[r][i], g.columnWidth(i))
+			}
+			sb.WriteString(line + "\n")
+			continue
+		}
+		// The selected row is highlighted, its selected cell inverted
+		for pos, i := range shown[g.left:last] {
+			line += " │ "
+			if g.left+pos == g.selectedCol {
+				sb.WriteString(selectedStyle.Render(line) + cellStyle.Render(padCell(g.rows[r][i], g.columnWidth(i))))
+				line = ""
+				continue
+			}
+			line += padCell(g.rows[r][i], g.columnWidth(i))
+		}
+		sb.WriteString(selectedStyle.Render(line) + "\n")
+	}
+
+	total := fmt.Sprintf("at least %d", len(g.rows))
+	if g.total >= 0 {
+		total = fmt.Sprint(g.total)
+	} else if g.countErr != nil {
+		total += fmt.Sprintf(" (counting failed: %s)", truncate(cellText(g.countErr.Error()), 60))
+	} else if g.counting {
+		total = "counting..."
+	} else if g.cursor.countQuery != "" {
+		total += " ('#' counts them by running the query again)"
+	}
+	status := fmt.Sprintf("Row %d of %s, column %d of %d (%s)", g.selectedRow+1, total, g.selectedCol+1, len(shown), g.columns[g.column()])
+	if len(g.rows) == 0 {
+		status = "No rows"
+	}
+	if hidden := len(g.columns) - len(shown); hidden > 0 {
+		status += fmt.Sprintf(", %d hidden", hidden)
+	}
+	if g.err != nil {
+		status += fmt.Sprintf(", error fetching rows: %v", g.err)
+	}
+	if g.status != "" {
+		status += " - " + g.status
+	}
+	sb.WriteString(footerStyle.Render(status))
+	return sb.String()
+}
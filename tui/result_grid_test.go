@@ -0,0 +1,18 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShownValuesSkipHiddenColumns(t *testing.T) {
+	g := &resultGrid{
+		columns: []string{"id", "name", "email"},
+		rows:    [][]string{{"1", "Ada", "ada@example.com"}},
+		layout:  newGridLayout(),
+	}
+	g.layout.hidden["name"] = true
+	if got, want := g.shownValues(0), []string{"1", "ada@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("shownValues() = %v, want %v", got, want)
+	}
+}
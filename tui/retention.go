@@ -0,0 +1,218 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// retentionScreen edits a lake's retention rules and previews what they
+// would delete before anything is removed.
+type retentionScreen struct {
+	lake        *lake.Lake
+	table       string
+	settings    lake.Settings
+	plans       []lake.RetentionPlan
+	err         error
+	status      string
+	editing     string // "lake", "table" or "schedule" while an input is open
+	input       string
+	applying    bool
+	maintenance *maintenanceScheduler
+}
+
+func newRetentionScreen(l *lake.Lake, table string, maintenance *maintenanceScheduler) *retentionScreen {
+	r := &retentionScreen{lake: l, table: table, maintenance: maintenance}
+	r.refresh()
+	return r
+}
+
+// refresh reloads the settings and recomputes the dry run
+func (r *retentionScreen) refresh() {
+	r.settings, r.err = r.lake.LoadSettings()
+	if r.err != nil {
+		return
+	}
+	r.plans, r.err = r.lake.PlanRetention(r.settings.Retention, time.Now())
+}
+
+// Update handles a key press and reports whether the screen should close.
+func (r *retentionScreen) Update(msg tea.Msg) (bool, tea.Cmd) {
+	switch msg := msg.(type) {
+	case retentionDoneMsg:
+		r.applying = false
+		if msg.err != nil {
+			r.status = fmt.Sprintf("Retention failed: %v", msg.err)
+		} else {
+			r.status = "Expired snapshots deleted, see _maintenance.log in the lake for details"
+		}
+		r.refresh()
+	case tea.KeyMsg:
+		if r.editing != "" {
+			r.updateInput(msg)
+			return false, nil
+		}
+		switch msg.String() {
+		case "esc", "q":
+			return true, nil
+		case "l":
+			r.editing = "lake"
+			r.input = r.settings.Retention.Default.String()
+		case "t":
+			if r.table != "" {
+				r.editing = "table"
+				r.input = r.settings.Retention.PolicyFor(r.table).String()
+			}
+		case "s":
+			r.editing = "schedule"
+			r.input = r.settings.Retention.Schedule
+		case "y":
+			if len(r.plans) > 0 && !r.applying {
+				r.applying = true
+				r.status = "Deleting expired snapshots..."
+				return false, retentionCmd(r.lake, r.plans)
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r *retentionScreen) updateInput(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "esc":
+		r.editing = ""
+	case "enter":
+		if err := r.save(); err != nil {
+			r.status = err.Error()
+			return
+		}
+		r.editing = ""
+		r.status = ""
+		r.refresh()
+	case "backspace":
+		if len(r.input) > 0 {
+			r.input = r.input[:len(r.input)-1]
+		}
+	case "space", " ":
+		r.input += " "
+	default:
+		if len(msg.String()) == 1 {
+			r.input += msg.String()
+		}
+	}
+}
+
+// save stores the value being edited in the lake settings
+func (r *retentionScreen) save() error {
+	retention := &r.settings.Retention
+	switch r.editing {
+	case "schedule":
+		retention.Schedule = strings.TrimSpace(r.input)
+	case "lake", "table":
+		policy, err := lake.ParseRetentionPolicy(r.input)
+		if err != nil {
+			return err
+		}
+		if r.editing == "lake" {
+			retention.Default = policy
+		} else if strings.TrimSpace(r.input) == "" {
+			// An empty table policy falls back to the lake policy
+			delete(retention.Tables, r.table)
+		} else {
+			if retention.Tables == nil {
+				retention.Tables = make(map[string]lake.RetentionPolicy)
+			}
+			retention.Tables[r.table] = policy
+		}
+	}
+	if err := r.lake.SaveSettings(r.settings); err != nil {
+		return err
+	}
+	if r.editing == "schedule" {
+		r.maintenance.Reload()
+	}
+	return nil
+}
+
+func (r *retentionScreen) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	if r.err != nil {
+		return fmt.Sprintf("Error reading retention settings: %v\n\nPress 'esc' to return.", r.err)
+	}
+
+	s := titleStyle.Render(fmt.Sprintf("Retention for data lake: %s", r.lake.Name)) + "\n\n"
+	s += fmt.Sprintf("Lake policy:     %s\n", describePolicy(r.settings.Retention.Default))
+	if r.table != "" {
+		policy := describePolicy(r.settings.Retention.PolicyFor(r.table))
+		if _, ok := r.settings.Retention.Tables[r.table]; !ok {
+			policy += " (lake policy)"
+		}
+		s += fmt.Sprintf("Table %-10s %s\n", r.table+":", policy)
+	}
+	schedule := "Not scheduled"
+	if r.settings.Retention.Schedule != "" {
+		schedule = fmt.Sprintf("%s (next run %s)", r.settings.Retention.Schedule, getScheduleDisplay(r.settings.Retention.Schedule))
+	}
+	s += fmt.Sprintf("Schedule:        %s\n\n", schedule)
+
+	if r.editing != "" {
+		if r.editing == "schedule" {
+			s += "Enter cron expression (e.g., '0 4 * * *' for every day at 4am), or leave empty to disable:\n"
+		} else {
+			s += "Enter rules, e.g. 'last=10 days=30 monthly=90'. A snapshot is kept if any rule keeps it:\n" +
+				"  last=N     keep the newest N snapshots\n" +
+				"  days=N     keep every snapshot from the last N days\n" +
+				"  monthly=N  keep every snapshot from the last N days, then one per month\n"
+		}
+		s += fmt.Sprintf("> %s\n\n", r.input)
+		if r.status != "" {
+			s += r.status + "\n\n"
+		}
+		return s + footerStyle.Render("Press 'enter' to confirm or 'esc' to cancel")
+	}
+
+	if len(r.plans) == 0 {
+		s += "Dry run: nothing would be deleted.\n"
+	} else {
+		s += headerStyle.Render("Dry run: these snapshots would be deleted") + "\n"
+		s += headerStyle.Render(fmt.Sprintf("  %-30s %-22s %-20s %10s", "TABLE", "RUN", "TAKEN", "ROWS")) + "\n"
+		for _, plan := range r.plans {
+			for _, snap := range plan.Expired {
+				s += fmt.Sprintf("  %-30s %-22s %-20s %10d\n", plan.Table, snap.RunID, formatTime(snap.Timestamp), snap.Rows)
+			}
+		}
+		for _, plan := range r.plans {
+			s += fmt.Sprintf("\n%s: %d snapshots, %d rows, %d files (%s) deleted", plan.Table,
+				len(plan.Expired), plan.Rows(), len(plan.Files), formatBytes(plan.Bytes()))
+			if len(plan.Rewritten) > 0 {
+				s += fmt.Sprintf(", up to %d compacted files rewritten", len(plan.Rewritten))
+			}
+		}
+		s += "\n"
+	}
+	if r.status != "" {
+		s += "\n" + r.status + "\n"
+	}
+	footer := "\nPress 'l' to set the lake policy, 's' to schedule retention, "
+	if r.table != "" {
+		footer += "'t' to set the policy of " + r.table + ", "
+	}
+	if len(r.plans) > 0 {
+		footer += "'y' to delete now, "
+	}
+	return s + footerStyle.Render(footer+"'esc' to return")
+}
+
+func describePolicy(p lake.RetentionPolicy) string {
+	if p.IsZero() {
+		return "keep everything"
+	}
+	return p.String()
+}
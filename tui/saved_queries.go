@@ -0,0 +1,307 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sqlPreviewLines is how much of the selected query the library shows
+const sqlPreviewLines = 8
+
+var savedQueryFields = []string{"Name", "Description", "Tags (comma separated)"}
+
+// savedQueryForm edits the name, description and tags of a saved query
+type savedQueryForm struct {
+	values []string
+	focus  int
+	// previous is the name the query is saved under, if it is saved
+	previous string
+	err      string
+}
+
+func newSavedQueryForm(q *lake.SavedQuery) *savedQueryForm {
+	f := &savedQueryForm{values: make([]string, len(savedQueryFields))}
+	if q != nil {
+		f.values = []string{q.Name, q.Description, strings.Join(q.Tags, ", ")}
+		f.previous = q.Name
+	}
+	return f
+}
+
+// Update handles a key press and reports whether the form was submitted or
+// cancelled.
+func (f *savedQueryForm) Update(msg tea.KeyMsg) (submitted, cancelled bool) {
+	switch msg.String() {
+	case "esc":
+		return false, true
+	case "tab", "down":
+		f.focus = (f.focus + 1) % len(f.values)
+	case "shift+tab", "up":
+		f.focus = (f.focus + len(f.values) - 1) % len(f.values)
+	case "enter":
+		if strings.TrimSpace(f.values[0]) == "" {
+			f.err = "A saved query needs a name"
+			return false, false
+		}
+		return true, false
+	case "backspace":
+		if v := f.values[f.focus]; len(v) > 0 {
+			f.values[f.focus] = v[:len(v)-1]
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			f.values[f.focus] += string(msg.Runes)
+		}
+	}
+	f.err = ""
+	return false, false
+}
+
+// Query returns the saved query described by the form
+func (f *savedQueryForm) Query(sql string) lake.SavedQuery {
+	return lake.SavedQuery{
+		Name:        strings.TrimSpace(f.values[0]),
+		Description: strings.TrimSpace(f.values[1]),
+		Tags:        lake.ParseTags(f.values[2]),
+		SQL:         sql,
+	}
+}
+
+func (f *savedQueryForm) View(title string) string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	out := titleStyle.Render(title) + "\n\n"
+	for i, label := range savedQueryFields {
+		line := fmt.Sprintf("%s:\n> %s", label, f.values[i])
+		if i == f.focus {
+			line = selectedStyle.Render(line)
+		}
+		out += line + "\n\n"
+	}
+	if f.err != "" {
+		out += errorStyle.Render(f.err) + "\n\n"
+	}
+	return out + footerStyle.Render("Press 'tab' to move between fields, 'enter' to save or 'esc' to cancel")
+}
+
+// savedQueriesScreen is the query library of a lake, opened from the data
+// lake selection. Queries open in the query editor to be edited and run;
+// the library can be shared as a directory of .sql files.
+type savedQueriesScreen struct {
+	lake     *lake.Lake
+	queries  []lake.SavedQuery
+	selected int
+	form     *savedQueryForm
+	deleting bool
+	// dirAction is "export" or "import" while asking for a directory
+	dirAction string
+	dirInput  string
+	status    string
+	err       error
+}
+
+func newSavedQueriesScreen(dataLake string) *savedQueriesScreen {
+	s := &savedQueriesScreen{}
+	s.lake, s.err = lake.OpenExisting(dataLake)
+	if s.err == nil {
+		s.refresh()
+	}
+	return s
+}
+
+func (s *savedQueriesScreen) refresh() {
+	s.queries, s.err = s.lake.SavedQueries()
+	if s.selected >= len(s.queries) {
+		s.selected = max(len(s.queries)-1, 0)
+	}
+}
+
+// openQuery opens a saved query in the query editor, optionally running it
+func (s *savedQueriesScreen) openQuery(run bool) tea.Cmd {
+	q := s.queries[s.selected]
+	dataLake := s.lake.Name
+	return func() tea.Msg {
+		return openQueryEditorMsg{dataLake: dataLake, query: q.SQL, saved: &q, run: run}
+	}
+}
+
+// Update handles a key press. It reports whether the screen should close.
+func (s *savedQueriesScreen) Update(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if s.form != nil {
+		submitted, cancelled := s.form.Update(msg)
+		if cancelled {
+			s.form = nil
+		}
+		if submitted {
+			q := s.form.Query(s.queries[s.selected].SQL)
+			if err := s.lake.SaveQuery(q, s.form.previous); err != nil {
+				s.form.err = err.Error()
+				return false, nil
+			}
+			s.form = nil
+			s.status = fmt.Sprintf("Saved %s", q.Name)
+			s.refresh()
+		}
+		return false, nil
+	}
+	if s.deleting {
+		switch msg.String() {
+		case "y", "Y":
+			name := s.queries[s.selected].Name
+			if err := s.lake.DeleteQuery(name); err != nil {
+				s.status = fmt.Sprintf("Error deleting %s: %v", name, err)
+			} else {
+				s.status = fmt.Sprintf("Deleted %s", name)
+			}
+			s.refresh()
+		}
+		s.deleting = false
+		return false, nil
+	}
+	if s.dirAction != "" {
+		s.updateDirInput(msg)
+		return false, nil
+	}
+
+	s.status = ""
+	switch msg.String() {
+	case "esc", "q":
+		return true, nil
+	case "up":
+		if s.selected > 0 {
+			s.selected--
+		}
+	case "down":
+		if s.selected < len(s.queries)-1 {
+			s.selected++
+		}
+	case "enter", "r":
+		if len(s.queries) > 0 {
+			return false, s.openQuery(msg.String() == "r")
+		}
+	case "e":
+		if len(s.queries) > 0 {
+			s.form = newSavedQueryForm(&s.queries[s.selected])
+		}
+	case "d":
+		if len(s.queries) > 0 {
+			s.deleting = true
+		}
+	case "x", "i":
+		s.dirAction = "export"
+		if msg.String() == "i" {
+			s.dirAction = "import"
+		}
+		s.dirInput = s.lake.Name + "_queries"
+		if home, err := os.UserHomeDir(); err == nil {
+			s.dirInput = filepath.Join(home, "pipeterm_queries", s.lake.Name)
+		}
+	}
+	return false, nil
+}
+
+// updateDirInput handles keys while asking for the directory to export the
+// library to or import it from
+func (s *savedQueriesScreen) updateDirInput(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "esc":
+		s.dirAction = ""
+	case "enter":
+		dir := strings.TrimSpace(s.dirInput)
+		if strings.HasPrefix(dir, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				dir = filepath.Join(home, dir[2:])
+			}
+		}
+		action := s.dirAction
+		s.dirAction = ""
+		if action == "export" {
+			n, err := s.lake.ExportQueries(dir)
+			s.status = fmt.Sprintf("Exported %d saved queries to %s", n, dir)
+			if err != nil {
+				s.status = fmt.Sprintf("Error exporting saved queries: %v", err)
+			}
+			return
+		}
+		n, err := s.lake.ImportQueries(dir)
+		s.status = fmt.Sprintf("Imported %d saved queries from %s", n, dir)
+		if err != nil {
+			s.status = fmt.Sprintf("Error importing saved queries: %v", err)
+		}
+		s.refresh()
+	case "backspace":
+		if len(s.dirInput) > 0 {
+			s.dirInput = s.dirInput[:len(s.dirInput)-1]
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			s.dirInput += string(msg.Runes)
+		}
+	}
+}
+
+func (s *savedQueriesScreen) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	if s.lake == nil {
+		return fmt.Sprintf("Error opening data lake: %v\n", s.err) + footerStyle.Render("\nPress 'esc' to return")
+	}
+	if s.form != nil {
+		return s.form.View("Edit saved query " + s.form.previous)
+	}
+
+	out := titleStyle.Render("Saved queries of data lake "+s.lake.Name) + "\n\n"
+	if s.err != nil {
+		return out + fmt.Sprintf("Error reading saved queries: %v\n", s.err) + footerStyle.Render("\nPress 'esc' to return")
+	}
+	if len(s.queries) == 0 {
+		out += "No saved queries yet. Save a query from the query editor with 'ctrl+l', or press 'i' to import a directory of .sql files.\n"
+	} else {
+		out += headerStyle.Render(fmt.Sprintf("  %-30s %-24s %s", "Name", "Tags", "Description")) + "\n"
+	}
+	for i, q := range s.queries {
+		line := fmt.Sprintf("%-30s %-24s %s", truncate(q.Name, 30), truncate(strings.Join(q.Tags, ", "), 24), truncate(q.Description, 60))
+		if i == s.selected {
+			out += selectedStyle.Render("> "+line) + "\n"
+		} else {
+			out += "  " + line + "\n"
+		}
+	}
+
+	if len(s.queries) > 0 {
+		lines := strings.Split(s.queries[s.selected].SQL, "\n")
+		if len(lines) > sqlPreviewLines {
+			lines = append(lines[:sqlPreviewLines], "...")
+		}
+		out += "\n" + footerStyle.Render(strings.Join(lines, "\n")) + "\n"
+	}
+
+	switch {
+	case s.deleting:
+		out += fmt.Sprintf("\nDelete saved query %s? (y/n)\n", s.queries[s.selected].Name)
+		return out
+	case s.dirAction == "export":
+		out += fmt.Sprintf("\nExport the saved queries as .sql files to:\n> %s\n", s.dirInput)
+		return out + footerStyle.Render("\nPress 'enter' to export or 'esc' to cancel")
+	case s.dirAction == "import":
+		out += fmt.Sprintf("\nImport the .sql files of:\n> %s\n", s.dirInput)
+		return out + footerStyle.Render("\nPress 'enter' to import or 'esc' to cancel")
+	}
+	if s.status != "" {
+		out += "\n" + s.status + "\n"
+	}
+	out += footerStyle.Render("\nPress 'enter' to open the query in the editor, 'r' to run it, 'e' to edit its name, description and tags, 'd' to delete it,\n'x' to export the library to a directory of .sql files, 'i' to import one, 'esc' to return")
+	return out
+}
@@ -0,0 +1,257 @@
+package tui
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// scriptStatement is one statement of a script
+type scriptStatement struct {
+	// text is the statement without its semicolon and surrounding space
+	text string
+	// offset is where the statement starts in the script, in bytes
+	offset int
+}
+
+// splitStatements splits a script at the semicolons outside strings, quoted
+// identifiers and comments. Statements holding only comments are dropped.
+func splitStatements(script string) []scriptStatement {
+	var statements []scriptStatement
+	start := 0
+	add := func(end int) {
+		text := script[start:end]
+		trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+		offset := start + len(text) - len(trimmed)
+		trimmed = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+		if !onlyComments(trimmed) {
+			statements = append(statements, scriptStatement{text: trimmed, offset: offset})
+		}
+	}
+	for i := 0; i < len(script); i++ {
+		if end, ok := lake.SkipQuoted(script, i); ok {
+			i = end
+		} else if script[i] == ';' {
+			add(i)
+			start = i + 1
+		}
+	}
+	add(len(script))
+	return statements
+}
+
+// onlyComments reports whether a statement holds nothing to run
+func onlyComments(statement string) bool {
+	text := []rune(statement)
+	for i, kind := range highlightQuery(text) {
+		if kind != commentText && !unicode.IsSpace(text[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// statementVerb is the first word of a statement after its comments, such
+// as SELECT or CREATE
+func statementVerb(statement string) string {
+	text := []rune(statement)
+	kinds := highlightQuery(text)
+	start := 0
+	for start < len(text) && (kinds[start] == commentText || unicode.IsSpace(text[start])) {
+		start++
+	}
+	end := start
+	for end < len(text) && (unicode.IsLetter(text[end]) || text[end] == '(') {
+		end++
+	}
+	return strings.ToUpper(string(text[start:end]))
+}
+
+// statementAt returns the statement under offset, or the last one before it
+// when the offset is between statements
+func statementAt(statements []scriptStatement, offset int) (scriptStatement, bool) {
+	if len(statements) == 0 {
+		return scriptStatement{}, false
+	}
+	statement := statements[0]
+	for _, s := range statements {
+		if s.offset <= offset {
+			statement = s
+		}
+	}
+	return statement, true
+}
+
+// in moves a position in a statement to the script the statement starts at
+// offset in
+func (p errorPosition) in(script string, offset int) errorPosition {
+	before := strings.Split(script[:offset], "\n")
+	if p.line == 0 {
+		p.column += utf8.RuneCountInString(before[len(before)-1])
+	}
+	p.line += len(before) - 1
+	return p
+}
+
+// scriptResult is the outcome of a script: a tab for every statement that
+// ran. The statements run on one connection, so temporary tables and
+// settings carry over from one statement to the next.
+type scriptResult struct {
+	conn *sql.Conn
+	tabs []*resultTab
+	// statements is how many statements the script has. Fewer tabs mean a
+	// statement failed and the rest did not run.
+	statements int
+}
+
+// resultTab is the outcome of one statement of a script: the rows it
+// returned, the number of rows it changed, or its error
+type resultTab struct {
+	statement scriptStatement
+	cursor    *queryCursor
+	grid      *resultGrid
+	// affected is the number of rows changed, or -1 if the statement does
+	// not change rows
+	affected int64
+	err      error
+	elapsed  time.Duration
+}
+
+// executeScript runs the statements in order and stops at the first one that
+// fails. Cancelling ctx interrupts the script and discards its results.
+func executeScript(ctx context.Context, dataLake string, statements []scriptStatement, values map[string]string) (*scriptResult, error) {
+	l, err := lake.OpenExisting(dataLake)
+	if err != nil {
+		return nil, err
+	}
+	db, err := l.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	script := &scriptResult{conn: conn, statements: len(statements)}
+	for _, statement := range statements {
+		tab := &resultTab{statement: statement, affected: -1}
+		script.tabs = append(script.tabs, tab)
+		started := time.Now()
+		tab.err = tab.run(ctx, l, conn, values)
+		tab.elapsed = time.Since(started)
+		if ctx.Err() != nil {
+			script.Close()
+			return nil, ctx.Err()
+		}
+		if tab.err != nil {
+			break
+		}
+		recordQueryLineage(dataLake, statement.text)
+	}
+	return script, nil
+}
+
+// run runs the tab's statement with values bound to its parameters. DuckDB
+// answers statements that do not return rows with a single Count column of
+// the rows they changed, or a single Success column.
+func (t *resultTab) run(ctx context.Context, l *lake.Lake, conn *sql.Conn, values map[string]string) error {
+	statement, args := lake.BindParameters(t.statement.text, values)
+	resolved, err := l.ResolveTimeTravel(ctx, conn, statement)
+	if err != nil {
+		return err
+	}
+	rows, err := conn.QueryContext(ctx, resolved, args...)
+	if err != nil {
+		return err
+	}
+	cursor, err := newQueryCursor(rows)
+	if err != nil {
+		return err
+	}
+	if countablePattern.MatchString(t.statement.text) || len(cursor.columns) != 1 ||
+		(cursor.columns[0] != "Count" && cursor.columns[0] != "Success") {
+		t.cursor = cursor
+		return nil
+	}
+	defer cursor.Close()
+	result, err := cursor.FetchValues(1)
+	if err != nil {
+		return err
+	}
+	if cursor.columns[0] == "Count" && len(result) == 1 {
+		if n, ok := result[0][0].(int64); ok {
+			t.affected = n
+		}
+	}
+	return nil
+}
+
+// summary describes the outcome of the tab's statement
+func (t *resultTab) summary() string {
+	switch {
+	case t.err != nil:
+		return "failed"
+	case t.cursor != nil && t.grid != nil && t.grid.total == 1:
+		return "1 row"
+	case t.cursor != nil && t.grid != nil && t.grid.total >= 0:
+		return fmt.Sprintf("%d rows", t.grid.total)
+	case t.cursor != nil:
+		return "rows"
+	case t.affected == 1:
+		return "1 row affected"
+	case t.affected >= 0:
+		return fmt.Sprintf("%d rows affected", t.affected)
+	}
+	return "done"
+}
+
+// View shows the outcome of a statement that returned no rows
+func (t *resultTab) View() string {
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	return fmt.Sprintf("%s\n\n%s in %s", footerStyle.Render(truncate(cellText(t.statement.text), 110)),
+		strings.ToUpper(t.summary()[:1])+t.summary()[1:], t.elapsed.Round(time.Millisecond))
+}
+
+// tabBar lists the tabs of the script, the shown one highlighted
+func (s *scriptResult) tabBar(shown, width int) string {
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	var labels []string
+	for i, t := range s.tabs {
+		label := fmt.Sprintf("[%d %s: %s]", i+1, truncate(statementVerb(t.statement.text), 10), t.summary())
+		switch {
+		case i == shown:
+			label = selectedStyle.Render(label)
+		case t.err != nil:
+			label = errorStyle.Render(label)
+		default:
+			label = footerStyle.Render(label)
+		}
+		labels = append(labels, label)
+	}
+	if skipped := s.statements - len(s.tabs); skipped > 0 {
+		labels = append(labels, footerStyle.Render(fmt.Sprintf("%d not run", skipped)))
+	}
+	return lipgloss.NewStyle().MaxWidth(width).Render(strings.Join(labels, " "))
+}
+
+// Close releases the results of the script and its connection
+func (s *scriptResult) Close() {
+	for _, t := range s.tabs {
+		if t.grid != nil {
+			t.grid.Close()
+		} else if t.cursor != nil {
+			t.cursor.Close()
+		}
+	}
+	s.conn.Close()
+}
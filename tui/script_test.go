@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []scriptStatement
+	}{
+		{
+			name:   "single statement",
+			script: "SELECT 1",
+			want:   []scriptStatement{{text: "SELECT 1", offset: 0}},
+		},
+		{
+			name:   "trailing semicolon and space",
+			script: "  SELECT 1;\n\nSELECT 2 ;  \n",
+			want:   []scriptStatement{{text: "SELECT 1", offset: 2}, {text: "SELECT 2", offset: 13}},
+		},
+		{
+			name:   "semicolon in a string",
+			script: "SELECT 'a;b'; SELECT 'it''s;'",
+			want:   []scriptStatement{{text: "SELECT 'a;b'", offset: 0}, {text: "SELECT 'it''s;'", offset: 14}},
+		},
+		{
+			name:   "semicolon in a quoted identifier",
+			script: `SELECT 1 AS "a;b"; SELECT 2`,
+			want:   []scriptStatement{{text: `SELECT 1 AS "a;b"`, offset: 0}, {text: "SELECT 2", offset: 19}},
+		},
+		{
+			name:   "semicolon in a line comment",
+			script: "SELECT 1 -- one; two\n; SELECT 2",
+			want:   []scriptStatement{{text: "SELECT 1 -- one; two", offset: 0}, {text: "SELECT 2", offset: 23}},
+		},
+		{
+			name:   "semicolon in a block comment",
+			script: "SELECT /* a; b */ 1; SELECT 2",
+			want:   []scriptStatement{{text: "SELECT /* a; b */ 1", offset: 0}, {text: "SELECT 2", offset: 21}},
+		},
+		{
+			name:   "semicolon in a dollar-quoted string",
+			script: "SELECT $$a;b$$; SELECT 2",
+			want:   []scriptStatement{{text: "SELECT $$a;b$$", offset: 0}, {text: "SELECT 2", offset: 16}},
+		},
+		{
+			name:   "comment-only statements dropped",
+			script: "-- setup;\n;SELECT 1; /* done */",
+			want:   []scriptStatement{{text: "SELECT 1", offset: 11}},
+		},
+		{
+			name:   "unterminated string",
+			script: "SELECT 1; SELECT 'a;b",
+			want:   []scriptStatement{{text: "SELECT 1", offset: 0}, {text: "SELECT 'a;b", offset: 10}},
+		},
+		{
+			name:   "empty script",
+			script: " ;; ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitStatements(tt.script)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %+v, want %+v", tt.script, got, tt.want)
+			}
+		})
+	}
+}
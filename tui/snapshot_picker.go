@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// snapshotPicker lets the user pick a table and one of its snapshots and
+// inserts the matching AS OF reference into the query editor.
+type snapshotPicker struct {
+	tables        []lake.Table
+	selectedTable int
+	selectedSnap  int
+	showSnapshots bool
+	err           error
+}
+
+func newSnapshotPicker(dataLake string) *snapshotPicker {
+	p := &snapshotPicker{}
+	l, err := lake.OpenExisting(dataLake)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	p.tables, p.err = l.Tables()
+	return p
+}
+
+// snapshots returns the snapshots of the selected table, newest first.
+func (p *snapshotPicker) snapshots() []lake.Snapshot {
+	snaps := p.tables[p.selectedTable].Snapshots
+	newestFirst := make([]lake.Snapshot, len(snaps))
+	for i, snap := range snaps {
+		newestFirst[len(snaps)-1-i] = snap
+	}
+	return newestFirst
+}
+
+// Update handles a key press. It returns the text to insert into the query
+// once a snapshot is chosen, and whether the picker should close.
+func (p *snapshotPicker) Update(msg tea.KeyMsg) (string, bool) {
+	switch msg.String() {
+	case "up":
+		if p.showSnapshots {
+			if p.selectedSnap > 0 {
+				p.selectedSnap--
+			}
+		} else if p.selectedTable > 0 {
+			p.selectedTable--
+		}
+	case "down":
+		if p.showSnapshots {
+			if p.selectedSnap < len(p.snapshots())-1 {
+				p.selectedSnap++
+			}
+		} else if p.selectedTable < len(p.tables)-1 {
+			p.selectedTable++
+		}
+	case "enter":
+		if len(p.tables) == 0 {
+			return "", true
+		}
+		if !p.showSnapshots {
+			p.showSnapshots = true
+			p.selectedSnap = 0
+			return "", false
+		}
+		snaps := p.snapshots()
+		if len(snaps) == 0 {
+			return "", false
+		}
+		snap := snaps[p.selectedSnap]
+		return fmt.Sprintf("%s AS OF %s", lake.QuoteIdent(snap.Table), lake.QuoteLiteral(snap.RunID)), true
+	case "esc":
+		if p.showSnapshots {
+			p.showSnapshots = false
+			return "", false
+		}
+		return "", true
+	}
+	return "", false
+}
+
+func (p *snapshotPicker) View() string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
+	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	if p.err != nil {
+		return fmt.Sprintf("Error reading catalog: %v", p.err)
+	}
+	if len(p.tables) == 0 {
+		return "No tables have been landed in this lake yet."
+	}
+
+	s := ""
+	if !p.showSnapshots {
+		s += titleStyle.Render("Time travel: select a table") + "\n\n"
+		for i, t := range p.tables {
+			line := fmt.Sprintf("%s (%d snapshots)", t.Name, len(t.Snapshots))
+			if i == p.selectedTable {
+				s += selectedStyle.Render("> "+line) + "\n"
+			} else {
+				s += "  " + line + "\n"
+			}
+		}
+	} else {
+		s += titleStyle.Render(fmt.Sprintf("Time travel: select a snapshot of %s", p.tables[p.selectedTable].Name)) + "\n\n"
+		if len(p.snapshots()) == 0 {
+			s += "This table has no snapshots to travel to.\n"
+		}
+		for i, snap := range p.snapshots() {
+			pipeline := snap.Pipeline
+			if pipeline == "" {
+				pipeline = "-"
+			}
+			line := fmt.Sprintf("%-22s %s  %-20s %8d rows", snap.RunID, formatTime(snap.Timestamp), pipeline, snap.Rows)
+			if i == p.selectedSnap {
+				s += selectedStyle.Render("> "+line) + "\n"
+			} else {
+				s += "  " + line + "\n"
+			}
+		}
+	}
+	s += footerStyle.Render("\n'enter' to select, 'esc' to go back")
+	return s
+}
@@ -3,12 +3,14 @@ package tui
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/marcboeker/go-duckdb"
-	"github.com/olekukonko/tablewriter"
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	"github.com/marcboeker/go-duckdb"
+	"math/big"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -16,32 +18,75 @@ import (
 )
 
 // Command to run the script
-func runScriptCmd(ctx context.Context) tea.Cmd {
+func runScriptCmd(ctx context.Context, p Pipeline) tea.Cmd {
 	return func() tea.Msg {
-		cmd := exec.CommandContext(ctx, "python3", "/Users/brettfloyd/pipeterm/utils/salesforce.py")
-		output, err := cmd.CombinedOutput()
+		run, output, err := runPipelineScript(ctx, p)
 		if ctx.Err() == context.Canceled {
 			return scriptErrorMsg{err: fmt.Errorf("script canceled")}
 		}
 		if err != nil {
 			return scriptErrorMsg{err: err}
 		}
-		return scriptSuccessMsg(string(output))
+		return scriptSuccessMsg{output: output, run: run}
 	}
 }
 
-func runByodScriptCmd(ctx context.Context, userScriptPath string) tea.Cmd {
-	return func() tea.Msg {
-		cmd := exec.CommandContext(ctx, "python3", "/Users/brettfloyd/pipeterm/utils/byod.py", userScriptPath)
-		output, err := cmd.CombinedOutput()
-		if ctx.Err() == context.Canceled {
-			return scriptErrorMsg{err: fmt.Errorf("script canceled")}
-		}
-		if err != nil {
-			return scriptErrorMsg{err: err}
-		}
-		return scriptSuccessMsg(string(output))
+// runPipelineScript runs a pipeline's connector script against a fresh
+// staging area and lands whatever it staged into the script's data lake.
+func runPipelineScript(ctx context.Context, p Pipeline) (PipelineRun, string, error) {
+	started := time.Now()
+	run := PipelineRun{RunID: lake.NewRunID(started), Started: started}
+
+	dataLake, err := lake.Open(lakeForScript(p.ScriptType))
+	if err != nil {
+		return run.finish(err), "", err
+	}
+	writer, err := dataLake.NewWriter(p.Name, run.RunID)
+	if err != nil {
+		return run.finish(err), "", err
+	}
+	writer.SetDriftPolicy(p.driftPolicy())
+	writer.SetLoadMode(p.loadMode(), p.PrimaryKey)
+
+	var cmd *exec.Cmd
+	if p.ScriptType == "byod" {
+		cmd = exec.CommandContext(ctx, "python3", "/Users/brettfloyd/pipeterm/utils/byod.py", p.ScriptPath)
+	} else {
+		cmd = exec.CommandContext(ctx, "python3", "/Users/brettfloyd/pipeterm/utils/salesforce.py")
+	}
+	cmd.Env = append(os.Environ(), "PIPETERM_STAGING_DIR="+writer.StagingDir())
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		writer.Abort()
+		return run.finish(err), string(output), err
+	}
+
+	entries, err := writer.Commit()
+	run.Drift = writer.Drift()
+	if err != nil {
+		return run.finish(err), string(output), err
+	}
+	for _, entry := range entries {
+		run.Rows += entry.Rows
+		output = append(output, fmt.Sprintf("Landed %d rows into %s/%s\n", entry.Rows, dataLake.Name, entry.Table)...)
 	}
+	for _, event := range run.Drift {
+		output = append(output, fmt.Sprintf("Schema drift: %s\n", event)...)
+	}
+	run.Checks = dataLake.RunChecks(entries, p.Checks, time.Now())
+	for _, result := range run.Checks {
+		output = append(output, fmt.Sprintf("Check %s\n", result)...)
+	}
+	return run.finish(nil), string(output), nil
+}
+
+// lakeForScript returns the data lake a script type writes into.
+func lakeForScript(scriptType string) string {
+	if scriptType == "byod" {
+		return "byod"
+	}
+	return "salesforce"
 }
 
 func createDataLakeFolder() tea.Cmd {
@@ -64,113 +109,213 @@ func incrementProgressCmd() tea.Cmd {
 }
 
 func listDataLakes() ([]string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, err
-	}
-	baseDir := filepath.Join(homeDir, ".local", "share", "pipeterm_lake")
-	entries, err := os.ReadDir(baseDir)
-	if err != nil {
-		return nil, err
-	}
-	var dataLakes []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			dataLakes = append(dataLakes, entry.Name())
+	return lake.List()
+}
+
+type legacyAdoptedMsg struct {
+	adopted int
+	err     error
+}
+
+// Command to register files written before the lake catalog existed. Every
+// lake is adopted even if another one fails.
+func adoptLegacyFilesCmd() tea.Cmd {
+	return func() tea.Msg {
+		dataLakes, err := lake.List()
+		if err != nil {
+			return legacyAdoptedMsg{err: err}
 		}
+		var errs []error
+		total := 0
+		for _, name := range dataLakes {
+			dataLake, err := lake.Open(name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("lake %s: %w", name, err))
+				continue
+			}
+			adopted, err := dataLake.Adopt()
+			total += adopted
+			if err != nil {
+				errs = append(errs, fmt.Errorf("lake %s: %w", name, err))
+			}
+		}
+		return legacyAdoptedMsg{adopted: total, err: errors.Join(errs...)}
 	}
-	return dataLakes, nil
 }
 
-// Command to execute the query
-func executeQueryCmd(dataLake string, query string) tea.Cmd {
-	return func() tea.Msg {
-		result, err := executeQuery(dataLake, query)
-		return queryResultMsg{result: result, err: err}
+// formatValue renders a scanned DuckDB value for display
+func formatValue(val interface{}, dbType string) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	case time.Time:
+		switch dbType {
+		case "DATE":
+			return v.Format("2006-01-02")
+		case "TIME":
+			return v.Format("15:04:05.999999")
+		}
+		return v.Format("2006-01-02 15:04:05.999999")
+	case duckdb.Decimal:
+		// Format the unscaled value exactly rather than through a float
+		digits := new(big.Int).Abs(v.Value).String()
+		if scale := int(v.Scale); scale > 0 {
+			digits = strings.Repeat("0", max(scale+1-len(digits), 0)) + digits
+			digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+		}
+		if v.Value.Sign() < 0 {
+			digits = "-" + digits
+		}
+		return digits
 	}
+	return fmt.Sprintf("%v", val)
 }
 
-func executeQuery(dataLake string, query string) (string, error) {
-	// Open a DuckDB connection
-	db, err := sql.Open("duckdb", "")
+// queryCursor is the open result of a query. DuckDB has already computed
+// the result, so fetching a page of rows only converts their values.
+type queryCursor struct {
+	// conn is nil for the results of a script, which share the script's
+	// connection
+	conn    *sql.Conn
+	rows    *sql.Rows
+	columns []string
+	types   []string
+	done    bool
+	// countQuery counts the rows of the result on another connection, or is
+	// empty if the statement cannot be wrapped in a count
+	countQuery string
+	// args are bound to the parameters of the query and its count
+	args []any
+}
+
+// countablePattern matches statements whose result rows can be counted by
+// wrapping them in a subquery
+var countablePattern = regexp.MustCompile(`(?is)^\s*(SELECT|WITH|FROM|VALUES|TABLE|PIVOT|UNPIVOT|\()`)
+
+// openQuery runs a query against a lake and leaves its result open. The
+// cursor is closed when ctx is cancelled.
+func openQuery(ctx context.Context, dataLake string, query string, args ...any) (*queryCursor, error) {
+	l, err := lake.OpenExisting(dataLake)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer db.Close()
-
-	// Get the user's home directory
-	homeDir, err := os.UserHomeDir()
+	db, err := l.DB(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	baseDir := filepath.Join(homeDir, ".local", "share", "pipeterm_lake", dataLake)
-
-	// Create views for each Parquet file in the data lake
-	files, err := filepath.Glob(filepath.Join(baseDir, "*.csv"))
+	// Time-travel views are temporary, so the query must run on the
+	// connection that created them
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := l.ResolveTimeTravel(ctx, conn, query)
 	if err != nil {
-		return "", err
+		conn.Close()
+		return nil, err
 	}
-
-	for _, file := range files {
-		tableName := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
-		createViewQuery := fmt.Sprintf("CREATE VIEW %s AS SELECT * FROM read_csv('%s');", tableName, file)
-		_, err := db.Exec(createViewQuery)
-		if err != nil {
-			return "", err
-		}
+	rows, err := conn.QueryContext(ctx, resolved, args...)
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
-
-	// Execute the user's query
-	rows, err := db.Query(query)
+	c, err := newQueryCursor(rows)
 	if err != nil {
-		return "", err
+		conn.Close()
+		return nil, err
+	}
+	c.conn, c.args = conn, args
+	if statement := strings.TrimRight(strings.TrimSpace(query), ";"); countablePattern.MatchString(statement) && !strings.Contains(statement, ";") {
+		// The newline ends a trailing line comment before the parenthesis
+		c.countQuery = fmt.Sprintf("SELECT count(*) FROM (%s\n)", statement)
 	}
-	defer rows.Close()
+	return c, nil
+}
 
-	// Fetch and format the results
-	columns, err := rows.Columns()
+// newQueryCursor reads the columns of an open result. The rows are closed if
+// that fails.
+func newQueryCursor(rows *sql.Rows) (*queryCursor, error) {
+	columnTypes, err := rows.ColumnTypes()
 	if err != nil {
-		return "", err
+		rows.Close()
+		return nil, err
+	}
+	c := &queryCursor{rows: rows}
+	for _, t := range columnTypes {
+		c.columns = append(c.columns, t.Name())
+		c.types = append(c.types, t.DatabaseTypeName())
 	}
+	return c, nil
+}
 
-	// Prepare data for tablewriter
-	var data [][]string
+// Fetch returns up to n more rows of the result
+func (c *queryCursor) Fetch(n int) ([][]string, error) {
+	values, err := c.FetchValues(n)
+	page := make([][]string, len(values))
+	for r, row := range values {
+		page[r] = make([]string, len(row))
+		for i, val := range row {
+			page[r][i] = formatValue(val, c.types[i])
+		}
+	}
+	return page, err
+}
 
-	for rows.Next() {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
+// FetchValues returns up to n more rows of the result as scanned, before
+// they are formatted for display
+func (c *queryCursor) FetchValues(n int) ([][]interface{}, error) {
+	var page [][]interface{}
+	for len(page) < n && !c.done {
+		if !c.rows.Next() {
+			c.done = true
+			if err := c.rows.Err(); err != nil {
+				return page, err
+			}
+			break
+		}
+		values := make([]interface{}, len(c.types))
+		valuePtrs := make([]interface{}, len(c.types))
 		for i := range values {
 			valuePtrs[i] = &values[i]
 		}
-		if err := rows.Scan(valuePtrs...); err != nil {
-			return "", err
-		}
-		var valueStrs []string
-		for _, val := range values {
-			switch v := val.(type) {
-			case nil:
-				valueStrs = append(valueStrs, "NULL")
-			case []byte:
-				valueStrs = append(valueStrs, string(v))
-			default:
-				valueStrs = append(valueStrs, fmt.Sprintf("%v", v))
-			}
+		if err := c.rows.Scan(valuePtrs...); err != nil {
+			return page, err
 		}
-		data = append(data, valueStrs)
+		page = append(page, values)
 	}
+	return page, nil
+}
 
-	if err := rows.Err(); err != nil {
-		return "", err
+func (c *queryCursor) Close() {
+	c.rows.Close()
+	if c.conn != nil {
+		c.conn.Close()
 	}
+}
 
-	// Create a buffer to capture the table output
-	var buf strings.Builder
-	table := tablewriter.NewWriter(&buf)
-	table.SetAutoWrapText(false)
-	table.SetAutoFormatHeaders(false)
-	table.SetHeader(columns)
-	table.AppendBulk(data)
-	table.Render()
-
-	return buf.String(), nil
+// countRows counts the rows of a query's result on a connection of its own,
+// so the count does not hold up paging through the open cursor
+func countRows(ctx context.Context, dataLake, countQuery string, args ...any) (int64, error) {
+	l, err := lake.OpenExisting(dataLake)
+	if err != nil {
+		return 0, err
+	}
+	db, err := l.DB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	resolved, err := l.ResolveTimeTravel(ctx, conn, countQuery)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = conn.QueryRowContext(ctx, resolved, args...).Scan(&n)
+	return n, err
 }
@@ -0,0 +1,35 @@
+package tui
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+)
+
+// newTestLake creates an empty lake under a temporary home directory
+func newTestLake(t *testing.T) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	if _, err := lake.Create("test"); err != nil {
+		t.Fatal(err)
+	}
+	return "test"
+}
+
+func TestCountRowsOfQueryEndingInComment(t *testing.T) {
+	dataLake := newTestLake(t)
+	ctx := context.Background()
+	cursor, err := openQuery(ctx, dataLake, "SELECT * FROM range(3) -- three rows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close()
+	n, err := countRows(ctx, dataLake, cursor.countQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("counted %d rows, want 3", n)
+	}
+}
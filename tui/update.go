@@ -2,8 +2,11 @@ package tui
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -20,6 +23,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.pipelinesModel != nil {
 			m.pipelinesModel.SetSize(m.width, m.height)
 		}
+		if m.lakeBrowser != nil {
+			m.lakeBrowser, _ = m.lakeBrowser.Update(msg)
+		}
+		if m.queryEditor != nil {
+			m.queryEditor, _ = m.queryEditor.Update(msg)
+		}
 		return m, nil
 
 	case TextInputDoneMsg:
@@ -51,23 +60,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case scriptSuccessMsg:
 		m.scriptCancel = nil
 		m.currentScreen = "pipeline_created"
-		m.scriptOutput = string(msg)
+		m.scriptOutput = msg.output
 		m.progressValue = 1.0
 		cmd := m.progress.SetPercent(1.0)
-		newPipeline := Pipeline{
-			Name:       m.inputs[0],
-			Status:     "Idle",
-			Healthy:    true,
-			Running:    false,
-			LastRun:    time.Now(), // Set the initial run time
-			Logs:       []string{"Pipeline Created."},
-			CronExpr:   "",
-			animation:  []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
-			animIndex:  0,
-			ScriptType: getScriptType(m.selectedService),
-			ScriptPath: m.customServiceName,
-		}
+		newPipeline := m.newPipeline()
+		newPipeline.LastRun = time.Now() // Set the initial run time
+		newPipeline.Logs = []string{"Pipeline Created."}
 		m.pipelinesModel.AddPipeline(newPipeline)
+		m.pipelinesModel.recordRun(len(m.pipelinesModel.pipelines)-1, msg.run)
+		m.pipelinesModel.SavePipelines()
 
 		return m, cmd
 
@@ -79,17 +80,74 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmd := m.progress.SetPercent(1.0)
 		return m, cmd
 
+	case lakesScannedMsg:
+		if msg.err == nil {
+			m.setDataLakes(msg.lakes, msg.usage, msg.saved)
+		}
+		if msg.watch != 0 && msg.watch == m.lakeWatch && m.inDataLakeSelect {
+			return m, watchLakesCmd(m.measuredLakes(), m.lakeWatch)
+		}
+		return m, nil
+
+	case lakeActionDoneMsg:
+		if msg.err != nil {
+			m.lakeStatus = fmt.Sprintf("Error: %v", msg.err)
+		} else {
+			m.lakeStatus = msg.status
+		}
+		// Scheduled maintenance jobs hold on to the lakes they were created for
+		m.maintenance.Reload()
+		return m, scanLakesCmd(0)
+
+	case openQueryEditorMsg:
+		m.inLakeBrowser = false
+		m.inDataLakeSelect = false
+		m.inQueryEditor = true
+		m.queryEditor = NewQueryEditor(msg.dataLake, m.width, m.height)
+		m.queryEditor.textarea.SetValue(msg.query)
+		m.queryEditor.saved = msg.saved
+		if msg.saved != nil {
+			m.queryEditor.status = fmt.Sprintf("Opened saved query %s", msg.saved.Name)
+		}
+		if msg.run {
+			return m, tea.Batch(m.queryEditor.textarea.Cursor.BlinkCmd(), m.queryEditor.runEditor())
+		}
+		return m, m.queryEditor.textarea.Cursor.BlinkCmd()
+
+	case compactionDoneMsg, retentionDoneMsg, sampleRowsMsg, lakeDBRefreshedMsg, profileDoneMsg:
+		if m.lakeBrowser != nil {
+			m.lakeBrowser, _ = m.lakeBrowser.Update(msg)
+		}
+		return m, nil
+
 	case createDataLakeSuccessMsg:
 		return m, nil
 
 	case createDataLakeErrorMsg:
 		return m, nil
 
-	case queryResultMsg:
+	case legacyAdoptedMsg:
+		var status []string
+		if msg.adopted > 0 {
+			status = append(status, fmt.Sprintf("Adopted %d legacy files into the lake catalog", msg.adopted))
+		}
 		if msg.err != nil {
-			m.queryResult = msg.err.Error()
-		} else {
-			m.queryResult = msg.result
+			status = append(status, fmt.Sprintf("Error adopting legacy files: %v", msg.err))
+		}
+		if len(status) > 0 {
+			m.lakeStatus = strings.Join(status, "\n")
+		}
+		if msg.adopted > 0 {
+			// Adopted files change the size of their lakes
+			return m, scanLakesCmd(0)
+		}
+		return m, nil
+
+	case queryResultMsg, queryCountMsg, queryTickMsg, exportTickMsg, exportDoneMsg, completionsLoadedMsg:
+		if m.queryEditor != nil {
+			var cmd tea.Cmd
+			m.queryEditor, cmd = m.queryEditor.Update(msg)
+			return m, cmd
 		}
 		return m, nil
 
@@ -103,8 +161,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Handle completion of text input
 			if msg.Type == tea.KeyEnter {
 				// Store the input value
-				m.customServiceName = m.textInput.textInput.Value()
-				m.selectedService = m.cursorPosition
+				if m.stage == 2 {
+					primaryKey := parseColumnList(m.textInput.textInput.Value())
+					if len(primaryKey) == 0 {
+						// Every run of an upsert pipeline without a key would fail
+						m.textInput.err = fmt.Errorf("an upsert needs at least one primary key column")
+						return m, nil
+					}
+					m.primaryKey = primaryKey
+					m.selectedDataType = m.cursorPosition
+				} else {
+					m.customServiceName = m.textInput.textInput.Value()
+					m.selectedService = m.cursorPosition
+				}
 				m.textInputActive = false
 				m.stage++
 				m.cursorPosition = 0
@@ -157,9 +226,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.stage = 0
 				m.currentScreen = ""
 			case "e":
-				m.inDataLakeSelect = true
 				m.selectedDataLake = 0
-				return m, nil
+				return m, m.showLakeSelect()
 
 			default:
 				// This causes any other key that is pressed to exit the screen
@@ -169,8 +237,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		if m.inPipelinesTab {
-			// Handle 'q' specially - if not in logs/scheduler, exit to welcome
-			if msg.String() == "q" && !m.pipelinesModel.showLogs && !m.pipelinesModel.showScheduler {
+			// Handle 'q' specially - if not in logs/scheduler/details, exit to welcome
+			if msg.String() == "q" && !m.pipelinesModel.inSubView() {
 				m.currentScreen = ""
 				m.state = "welcome"
 				m.inPipelinesTab = false
@@ -182,8 +250,28 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.pipelinesModel, cmd = m.pipelinesModel.Update(msg)
 			return m, cmd
 		}
+		// Handle the lake catalog browser
+		if m.inLakeBrowser {
+			if (msg.String() == "q" || msg.Type == tea.KeyEsc) && !m.lakeBrowser.inSubView() {
+				m.inLakeBrowser = false
+				return m, m.showLakeSelect()
+			}
+			var cmd tea.Cmd
+			m.lakeBrowser, cmd = m.lakeBrowser.Update(msg)
+			return m, cmd
+		}
 		// Handle data lake selection
 		if m.inDataLakeSelect {
+			if m.lakeAction != "" {
+				return m.updateLakeAction(msg)
+			}
+			if m.savedQueries != nil {
+				closed, cmd := m.savedQueries.Update(msg)
+				if closed {
+					m.savedQueries = nil
+				}
+				return m, cmd
+			}
 			switch msg.String() {
 			case "up":
 				if m.selectedDataLake > 0 {
@@ -194,24 +282,51 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.selectedDataLake++
 				}
 			case "enter":
+				if len(m.dataLakes) == 0 {
+					return m, nil
+				}
 				m.inDataLakeSelect = false
 				m.inQueryEditor = true
 				//m.queryResult = ""
 				m.queryEditor = NewQueryEditor(m.dataLakes[m.selectedDataLake], m.width, m.height)
 				return m, m.queryEditor.textarea.Cursor.BlinkCmd()
+			case "b":
+				if len(m.dataLakes) > 0 {
+					m.inDataLakeSelect = false
+					m.inLakeBrowser = true
+					m.lakeBrowser = NewLakeBrowser(m.dataLakes[m.selectedDataLake], m.maintenance, m.width, m.height)
+				}
+				return m, nil
+			case "s":
+				if len(m.dataLakes) > 0 {
+					m.savedQueries = newSavedQueriesScreen(m.dataLakes[m.selectedDataLake])
+				}
+			case "n":
+				m.lakeAction = "create"
+				m.lakeInput = ""
+			case "r":
+				if len(m.dataLakes) > 0 {
+					m.lakeAction = "rename"
+					m.lakeTarget = m.dataLakes[m.selectedDataLake]
+					m.lakeInput = m.lakeTarget
+				}
+			case "d":
+				if len(m.dataLakes) > 0 {
+					m.lakeAction = "delete"
+					m.lakeTarget = m.dataLakes[m.selectedDataLake]
+				}
 			case "q":
 				m.inDataLakeSelect = false
-
+				m.lakeStatus = ""
 			}
 			return m, nil
 		}
 		// Handle key messages when in query editor
 		if m.inQueryEditor {
-			if msg.Type == tea.KeyEsc {
-
+			if msg.Type == tea.KeyEsc && !m.queryEditor.HasOverlay() {
+				m.queryEditor.Close()
 				m.inQueryEditor = false
-				m.inDataLakeSelect = true
-				return m, tea.ClearScreen
+				return m, tea.Batch(tea.ClearScreen, m.showLakeSelect())
 			}
 			var cmd tea.Cmd
 			var qe *QueryEditor
@@ -265,8 +380,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 
 		case "e":
-			m.inDataLakeSelect = true
-			return m, nil
+			return m, m.showLakeSelect()
 		}
 
 		// Handle input based on the current state
@@ -313,7 +427,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.cursorPosition++
 					}
 				case "enter":
+					if loadModes[m.cursorPosition] == lake.Upsert {
+						// Upserts need the primary key columns to merge on
+						m.textInputActive = true
+						m.textInput = newTextInput()
+						m.textInput.textInput.Placeholder = "primary key columns, comma separated..."
+						return m, nil
+					}
 					m.selectedDataType = m.cursorPosition
+					m.primaryKey = nil
 					m.stage++
 				}
 			case 3:
@@ -324,12 +446,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					// Create a context to cancel the script if needed
 					var ctx context.Context
 					ctx, m.scriptCancel = context.WithCancel(context.Background())
-					var cmd tea.Cmd
-					if m.selectedService == 3 {
-						cmd = runByodScriptCmd(ctx, m.customServiceName)
-					} else {
-						cmd = runScriptCmd(ctx)
-					}
+					cmd := runScriptCmd(ctx, m.newPipeline())
 					// Start the script and progress bar
 					return m, tea.Batch(cmd, incrementProgressCmd())
 				}
@@ -340,6 +457,34 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// newPipeline builds the pipeline described by the creation wizard
+func (m Model) newPipeline() Pipeline {
+	return Pipeline{
+		Name:       m.inputs[0],
+		Status:     "Idle",
+		Healthy:    true,
+		Running:    false,
+		CronExpr:   "",
+		animation:  []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
+		animIndex:  0,
+		ScriptType: getScriptType(m.selectedService),
+		ScriptPath: m.customServiceName,
+		LoadMode:   string(loadModes[m.selectedDataType]),
+		PrimaryKey: m.primaryKey,
+	}
+}
+
+// parseColumnList splits a comma separated list of column names
+func parseColumnList(s string) []string {
+	var columns []string
+	for _, column := range strings.Split(s, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
 func getScriptType(serviceIndex int) string {
 	if serviceIndex == 3 { // BYOD index
 		return "byod"
@@ -0,0 +1,141 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brfloyd/senior-project-brett-cli-data-project/lake"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestUpsertWizardNeedsPrimaryKey(t *testing.T) {
+	newTestLake(t)
+	var m tea.Model = InitialModel()
+	model := m.(Model)
+	model.state = "create_pipeline"
+	model.stage = 2
+	model.cursorPosition = 2 // Upsert
+	m = model
+
+	press := func(msg tea.KeyMsg) Model {
+		m, _ = m.Update(msg)
+		return m.(Model)
+	}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	if model = press(enter); !model.textInputActive {
+		t.Fatal("choosing upsert did not ask for the primary key")
+	}
+	for _, key := range []string{" ", ",", " "} {
+		press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+	model = press(enter)
+	if model.stage != 2 || !model.textInputActive || model.textInput.err == nil {
+		t.Fatalf("an empty primary key moved the wizard to stage %d", model.stage)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("id, region")})
+	model = press(enter)
+	if model.stage != 3 || model.textInputActive {
+		t.Fatalf("a primary key left the wizard at stage %d", model.stage)
+	}
+	if want := []string{"id", "region"}; !reflect.DeepEqual(model.primaryKey, want) {
+		t.Errorf("primary key = %v, want %v", model.primaryKey, want)
+	}
+}
+
+func TestLakeScanOnlyWatchesOpenSelector(t *testing.T) {
+	newTestLake(t)
+	m := InitialModel()
+	m.showLakeSelect()
+	visit := m.lakeWatch
+
+	next, cmd := m.Update(lakesScannedMsg{lakes: []string{"test"}, watch: visit})
+	if cmd == nil {
+		t.Fatal("scan stopped while the selector is open")
+	}
+	m = next.(Model)
+
+	m.inDataLakeSelect = false
+	if _, cmd := m.Update(lakesScannedMsg{lakes: []string{"test"}, watch: visit}); cmd != nil {
+		t.Error("scan continued after the selector closed")
+	}
+
+	m.showLakeSelect()
+	if _, cmd := m.Update(lakesScannedMsg{lakes: []string{"test"}, watch: visit}); cmd != nil {
+		t.Error("scan of an earlier visit continued")
+	}
+}
+
+func TestLakeActionKeepsTargetAcrossScans(t *testing.T) {
+	newTestLake(t)
+	m := InitialModel()
+	m.showLakeSelect()
+	m.setDataLakes([]string{"a", "b", "c"}, nil, nil)
+	m.selectedDataLake = 1
+
+	press := func(key string) {
+		next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = next.(Model)
+	}
+
+	press("d")
+	m.setDataLakes([]string{"a", "b"}, nil, nil)
+	m.selectedDataLake = 0
+	if m.lakeAction != "delete" || m.lakeTarget != "b" {
+		t.Fatalf("delete prompt targets %q after a scan, want b", m.lakeTarget)
+	}
+
+	m.setDataLakes([]string{"a"}, nil, nil)
+	if m.lakeAction != "" {
+		t.Fatal("delete prompt stayed open after its lake disappeared")
+	}
+	press("y")
+	if m.lakeAction != "" || m.lakeTarget != "" {
+		t.Error("'y' after a cancelled prompt started an action")
+	}
+
+	press("r")
+	m.setDataLakes(nil, nil, nil)
+	if m.lakeAction != "" {
+		t.Fatal("rename prompt stayed open after every lake disappeared")
+	}
+	_ = m.View()
+}
+
+func TestLegacyAdoptionIsReported(t *testing.T) {
+	newTestLake(t)
+	l, err := lake.OpenExisting("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(l.Dir, "orders.csv"), []byte("id\n1\n2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	broken, err := lake.Create("broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(broken.Dir, "_manifest.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := adoptLegacyFilesCmd()().(legacyAdoptedMsg)
+	if msg.adopted != 1 {
+		t.Errorf("adopted %d files, want 1", msg.adopted)
+	}
+	if msg.err == nil || !strings.Contains(msg.err.Error(), "lake broken") {
+		t.Errorf("adoption error = %v, want one naming lake broken", msg.err)
+	}
+
+	next, _ := InitialModel().Update(msg)
+	status := next.(Model).lakeStatus
+	for _, want := range []string{"Adopted 1 legacy files", "Error adopting legacy files"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("selector status %q does not mention %q", status, want)
+		}
+	}
+}
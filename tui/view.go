@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/common-nighthawk/go-figure"
@@ -22,31 +23,18 @@ func (m Model) View() string {
 	s += actionBar + "\n\n"
 
 	if m.inDataLakeSelect {
-		unslectedLineStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF"))
-		selectedLineStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF7F00"))
-		s += selectedLineStyle.Render("Select a Data Lake:\n")
-		s += "\n"
-		for i, lake := range m.dataLakes {
-			cursor := "  "
-			lineStyle := unslectedLineStyle
-			if m.selectedDataLake == i {
-				cursor = "> "
-				lineStyle = selectedLineStyle
-			}
-			line := cursor + lake
-			s += lineStyle.Render(line) + "\n"
-		}
-		s += "\nUse Up/Down arrows to navigate, 'Enter' to select."
+		s += m.lakeSelectView()
+		return s
+	}
+
+	if m.inLakeBrowser {
+		s += m.lakeBrowser.View()
 		return s
 	}
 
 	if m.inQueryEditor {
 		s += m.queryEditor.View()
-		s += "\n\nPress 'esc' to return to the data lake selection.\n\n"
-		if m.queryResult != "" {
-			s += m.queryResult
-		}
-
+		s += "\n\n" + m.queryEditor.Help()
 		return s
 	}
 
@@ -188,8 +176,14 @@ func (m Model) View() string {
 					lineStyle = selectedLineStyle
 				}
 				line := cursor + dataType
+				if m.textInputActive && m.cursorPosition == i {
+					line += " " + m.textInput.View()
+				}
 				s += lineStyle.Render(line) + "\n"
 			}
+			if m.textInputActive && m.textInput.err != nil {
+				s += "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Render(m.textInput.err.Error()) + "\n"
+			}
 			s += "\nUse Up/Down arrows to navigate, 'Enter' to select."
 			s += "\nPress 'Esc' at any time to return to the welcome screen."
 		case 3:
@@ -201,6 +195,9 @@ func (m Model) View() string {
 			s += fmt.Sprintf("Pipeline Name: %s\n", m.inputs[0])
 			s += fmt.Sprintf("Service: %s\n", m.services[m.selectedService])
 			s += fmt.Sprintf("Data Loading Type: %s\n", m.dataTypes[m.selectedDataType])
+			if len(m.primaryKey) > 0 {
+				s += fmt.Sprintf("Primary Key: %s\n", strings.Join(m.primaryKey, ", "))
+			}
 			s += "\nPress 'Enter' to confirm, or 'Esc' to return to the welcome screen."
 		}
 	}
@@ -1,9 +1,9 @@
 import importlib.util
 import os
 import sys
-from datetime import datetime
 
 import pandas as pd
+from lake_writer import write_table
 
 script_path = sys.argv[1]
 
@@ -45,18 +45,11 @@ def ingest_data(user_script):
 
 
 def save_data(df, output_dir):
-    home_dir = os.path.expanduser("~")
-    output_dir = os.path.join(home_dir, ".local", "share", "pipeterm_lake")
-    os.makedirs(output_dir, exist_ok=True)
-
-    timestamp = datetime.now().strftime("%Y-%m-%d_%H-%M-%S")
     script_name = os.path.splitext(os.path.basename(script_path))[0]
-    output_file = f"{script_name}_{timestamp}.csv"
-    output_path = os.path.join(output_dir, output_file)
 
     try:
-        df.to_csv(output_path, index=False)
-        print(f"Data saved to: {output_path}")
+        output_path = write_table(df, script_name)
+        print(f"Data staged to: {output_path}")
     except Exception as e:
         print(f"Error saving data: {e}")
         sys.exit(1)
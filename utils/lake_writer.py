@@ -0,0 +1,21 @@
+import os
+
+import pyarrow as pa
+import pyarrow.parquet as pq
+
+
+def get_staging_dir():
+    # pipeterm hands every run a private staging directory and lands whatever
+    # is left there once the script exits successfully
+    staging_dir = os.getenv("PIPETERM_STAGING_DIR")
+    if not staging_dir:
+        raise RuntimeError("PIPETERM_STAGING_DIR is not set, run this script from pipeterm")
+    return staging_dir
+
+
+def write_table(df, table):
+    output_path = os.path.join(get_staging_dir(), f"{table}.parquet")
+
+    arrow_table = pa.Table.from_pandas(df, preserve_index=False)
+    pq.write_table(arrow_table, output_path, compression="zstd")
+    return output_path
@@ -1,12 +1,10 @@
-import csv
 import os
 import time
 
 import pandas as pd
-import pyarrow as pa
-import pyarrow.parquet as pq
 import requests
 from dotenv import load_dotenv
+from lake_writer import write_table
 from simple_salesforce import Salesforce
 
 
@@ -46,18 +44,14 @@ def connect_to_salesforce():
     ]
     rows = report_data["factMap"]["T!T"]["rows"]
 
-    csv_path = os.path.expanduser(
-        f"~/.local/share/pipeterm_lake/salesforce/salesforce_report_{time.strftime('%Y%m%d%H%M%S')}.csv"
+    df = pd.DataFrame(
+        [
+            [row["dataCells"][i]["label"] for i in range(len(column_names))]
+            for row in rows
+        ],
+        columns=column_names,
     )
-
-    with open(csv_path, "w", newline="", encoding="UTF-8") as csvfile:
-        writer = csv.writer(csvfile)
-        writer.writerow(column_names)
-
-        for row in rows:
-            writer.writerow(
-                [row["dataCells"][i]["label"] for i in range(len(column_names))]
-            )
+    write_table(df, "salesforce_report")
 
 
 if __name__ == "__main__":